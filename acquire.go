@@ -0,0 +1,35 @@
+package pgxtls
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	pool "github.com/jackc/pgx/v4/pgxpool"
+)
+
+// ErrPoolExhausted is returned by AcquireWithTimeout when every connection
+// of the pool stayed in use for the whole timeout
+var ErrPoolExhausted = errors.New("pool exhausted: all connections in use")
+
+// AcquireWithTimeout acquires a connection from p, waiting at most d.
+// When the wait times out while p is at its maximum size with none idle
+// it returns ErrPoolExhausted, telling saturation apart from ctx being
+// cancelled or the database being unreachable
+func AcquireWithTimeout(ctx context.Context, p Pool, d time.Duration) (*pool.Conn, error) {
+	actx, cancel := context.WithTimeout(ctx, d)
+	defer cancel()
+
+	conn, err := p.Acquire(actx)
+	if err == nil {
+		return conn, nil
+	}
+
+	if ctx.Err() == nil && actx.Err() != nil {
+		s := p.Stat()
+		if s.TotalConns() >= s.MaxConns() && s.IdleConns() == 0 {
+			return nil, ErrPoolExhausted
+		}
+	}
+	return nil, err
+}
@@ -0,0 +1,71 @@
+package pgxtls
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	pool "github.com/jackc/pgx/v4/pgxpool"
+)
+
+func TestAcquireWithTimeout(t *testing.T) {
+	tests := []struct {
+		name     string
+		held     int
+		cancel   bool
+		down     bool
+		wantErr  error
+		wantConn bool
+	}{
+		{name: "idle connection", wantConn: true},
+		{name: "exhausted", held: 2, wantErr: ErrPoolExhausted},
+		{name: "cancelled", held: 2, cancel: true, wantErr: context.Canceled},
+		{name: "unreachable", down: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newFakeServer(t)
+			cfg := srv.poolConfig(t)
+			cfg.MaxConns = 2
+			cfg.LazyConnect = true
+			p, err := pool.ConnectConfig(context.Background(), cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer p.Close()
+
+			for i := 0; i < tt.held; i++ {
+				conn, err := p.Acquire(context.Background())
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer conn.Release()
+			}
+			if tt.down {
+				srv.close()
+			}
+			ctx, cancel := context.WithCancel(context.Background())
+			if tt.cancel {
+				cancel()
+			}
+			defer cancel()
+
+			conn, err := AcquireWithTimeout(ctx, p, 50*time.Millisecond)
+			if conn != nil {
+				conn.Release()
+			}
+			if (conn != nil) != tt.wantConn {
+				t.Fatalf("AcquireWithTimeout() = %v, %v", conn, err)
+			}
+			switch {
+			case tt.wantErr != nil && !errors.Is(err, tt.wantErr):
+				t.Fatalf("AcquireWithTimeout() error = %v, want %v", err, tt.wantErr)
+			case tt.wantConn && err != nil:
+				t.Fatal(err)
+			case !tt.wantConn && tt.wantErr == nil && (err == nil || errors.Is(err, ErrPoolExhausted)):
+				t.Fatalf("AcquireWithTimeout() error = %v, want the connection error", err)
+			}
+		})
+	}
+}
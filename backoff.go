@@ -0,0 +1,87 @@
+package pgxtls
+
+import (
+	"context"
+	"math/rand"
+	"time"
+)
+
+// BackoffStrategy decides how long to wait between retries
+type BackoffStrategy interface {
+	// Delay returns how long to wait before retry attempt, counting from 0
+	Delay(attempt int) time.Duration
+}
+
+// WithBackoff sets the strategy used to space out reconnection attempts,
+// by default FullJitterBackoff(100*time.Millisecond, 30*time.Second)
+func WithBackoff(b BackoffStrategy) Option {
+	return func(o *options) {
+		o.backoff = b
+	}
+}
+
+// ConstantBackoff waits d before every retry
+func ConstantBackoff(d time.Duration) BackoffStrategy {
+	return constantBackoff(d)
+}
+
+type constantBackoff time.Duration
+
+func (c constantBackoff) Delay(int) time.Duration { return time.Duration(c) }
+
+// ExponentialBackoff doubles the wait from base on every retry, up to max
+func ExponentialBackoff(base, max time.Duration) BackoffStrategy {
+	return &exponentialBackoff{base: base, max: max}
+}
+
+type exponentialBackoff struct {
+	base, max time.Duration
+}
+
+func (e *exponentialBackoff) Delay(attempt int) time.Duration {
+	d := e.base
+	for i := 0; i < attempt && d < e.max; i++ {
+		d *= 2
+	}
+	if d > e.max {
+		d = e.max
+	}
+	return d
+}
+
+// FullJitterBackoff waits a random duration between zero and
+// what ExponentialBackoff would, so that clients retrying at
+// the same time spread out
+func FullJitterBackoff(base, max time.Duration) BackoffStrategy {
+	return &fullJitterBackoff{exponentialBackoff{base: base, max: max}}
+}
+
+type fullJitterBackoff struct {
+	exponentialBackoff
+}
+
+func (f *fullJitterBackoff) Delay(attempt int) time.Duration {
+	d := f.exponentialBackoff.Delay(attempt)
+	if d <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(d) + 1))
+}
+
+// sleep waits d, it returns ctx's error early if ctx is done
+// or its deadline would pass before d is up
+func sleep(ctx context.Context, d time.Duration) error {
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < d {
+		return context.DeadlineExceeded
+	}
+
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
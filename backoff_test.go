@@ -0,0 +1,91 @@
+package pgxtls
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestBackoffDelay(t *testing.T) {
+	tests := []struct {
+		name    string
+		backoff BackoffStrategy
+		attempt int
+		want    time.Duration
+	}{
+		{"constant first", ConstantBackoff(time.Second), 0, time.Second},
+		{"constant later", ConstantBackoff(time.Second), 7, time.Second},
+		{"exponential first", ExponentialBackoff(100*time.Millisecond, 30*time.Second), 0, 100 * time.Millisecond},
+		{"exponential doubles", ExponentialBackoff(100*time.Millisecond, 30*time.Second), 3, 800 * time.Millisecond},
+		{"exponential capped", ExponentialBackoff(100*time.Millisecond, 30*time.Second), 10, 30 * time.Second},
+		{"exponential many attempts", ExponentialBackoff(100*time.Millisecond, 30*time.Second), 1000, 30 * time.Second},
+		{"exponential base above max", ExponentialBackoff(time.Minute, 30*time.Second), 0, 30 * time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.backoff.Delay(tt.attempt); got != tt.want {
+				t.Fatalf("Delay(%d) = %v, want %v", tt.attempt, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFullJitterBackoffBounds(t *testing.T) {
+	tests := []struct {
+		attempt int
+		max     time.Duration
+	}{
+		{0, 100 * time.Millisecond},
+		{3, 800 * time.Millisecond},
+		{20, 30 * time.Second},
+	}
+	b := FullJitterBackoff(100*time.Millisecond, 30*time.Second)
+	for _, tt := range tests {
+		spread := false
+		for i := 0; i < 100; i++ {
+			d := b.Delay(tt.attempt)
+			if d < 0 || d > tt.max {
+				t.Fatalf("Delay(%d) = %v, want between 0 and %v", tt.attempt, d, tt.max)
+			}
+			if d != tt.max && d != 0 {
+				spread = true
+			}
+		}
+		if !spread {
+			t.Fatalf("Delay(%d) isn't randomized", tt.attempt)
+		}
+	}
+
+	if d := FullJitterBackoff(0, 0).Delay(3); d != 0 {
+		t.Fatalf("zero backoff waited %v", d)
+	}
+}
+
+func TestSleep(t *testing.T) {
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	short, cancelShort := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancelShort()
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+		d    time.Duration
+		want error
+	}{
+		{"elapsed", context.Background(), time.Millisecond, nil},
+		{"canceled", canceled, time.Hour, context.Canceled},
+		{"deadline before delay", short, time.Hour, context.DeadlineExceeded},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start := time.Now()
+			if err := sleep(tt.ctx, tt.d); err != tt.want {
+				t.Fatalf("sleep() = %v, want %v", err, tt.want)
+			}
+			if tt.want != nil && time.Since(start) > time.Second {
+				t.Fatal("sleep() didn't return early")
+			}
+		})
+	}
+}
@@ -0,0 +1,47 @@
+package pgxtls
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgconn"
+	pool "github.com/jackc/pgx/v4/pgxpool"
+)
+
+// BatchError reports which statement of ExecBatch failed
+type BatchError struct {
+	Index int // position of the failed statement in stmts
+	Err   error
+}
+
+func (e *BatchError) Error() string {
+	return fmt.Sprintf("statement %d failed: %v", e.Index, e.Err)
+}
+
+func (e *BatchError) Unwrap() error { return e.Err }
+
+// ExecBatch runs stmts in order on a single connection of p, as for
+// migrations or seed scripts, sharing ctx's deadline across them. It
+// stops at the first statement that fails, returning a *BatchError
+func ExecBatch(ctx context.Context, p *pool.Pool, stmts []string) error {
+	conn, err := p.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	return execAll(ctx, conn, stmts)
+}
+
+type execer interface {
+	Exec(ctx context.Context, sql string, arguments ...interface{}) (pgconn.CommandTag, error)
+}
+
+func execAll(ctx context.Context, e execer, stmts []string) error {
+	for i, stmt := range stmts {
+		if _, err := e.Exec(ctx, stmt); err != nil {
+			return &BatchError{Index: i, Err: err}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package pgxtls
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgproto3/v2"
+	pool "github.com/jackc/pgx/v4/pgxpool"
+)
+
+func TestExecBatch(t *testing.T) {
+	ctx := context.Background()
+	migration := []string{"CREATE TABLE jobs (id int)", "ALTER TABLE jobs ADD done bool", "CREATE INDEX ON jobs (done)"}
+
+	tests := []struct {
+		name      string
+		stmts     []string
+		fail      string
+		wantIndex int
+		wantRun   []string
+	}{
+		{name: "all", stmts: migration, wantIndex: -1, wantRun: migration},
+		{name: "none", wantIndex: -1},
+		{name: "stops at failure", stmts: migration, fail: migration[1], wantIndex: 1, wantRun: migration[:2]},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newFakeServer(t)
+			if tt.fail != "" {
+				srv.fail[tt.fail] = &pgproto3.ErrorResponse{Severity: "ERROR", Code: "42701", Message: "column already exists"}
+			}
+			p, err := pool.ConnectConfig(ctx, srv.poolConfig(t))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer p.Close()
+
+			err = ExecBatch(ctx, p, tt.stmts)
+			var batchErr *BatchError
+			var pgErr *pgconn.PgError
+			switch {
+			case tt.wantIndex < 0 && err != nil:
+				t.Fatal(err)
+			case tt.wantIndex >= 0 && (!errors.As(err, &batchErr) || batchErr.Index != tt.wantIndex || !errors.As(err, &pgErr) || pgErr.Code != "42701"):
+				t.Fatalf("ExecBatch() = %v, want statement %d to fail", err, tt.wantIndex)
+			}
+
+			var run []string
+			for _, q := range srv.queries() {
+				if q != ";" {
+					run = append(run, q)
+				}
+			}
+			if fmt.Sprint(run) != fmt.Sprint(tt.wantRun) {
+				t.Fatalf("ran %q, want %q", run, tt.wantRun)
+			}
+		})
+	}
+}
@@ -0,0 +1,67 @@
+package pgxtls
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+)
+
+// RetryCachedPlan returns a Querier running queries on q and running a query
+// once more when it failed because a schema change such as ALTER TABLE
+// invalidated its cached plan. pgx drops the stale statement from the cache
+// on such errors, so the second run prepares it afresh. A failed query
+// aborts a transaction, so q should be a pool or connection, not a pgx.Tx.
+// Query is only retried when the error is returned before any rows are read
+func RetryCachedPlan(q Querier) Querier {
+	return &cachedPlanRetrier{q: q}
+}
+
+type cachedPlanRetrier struct {
+	q Querier
+}
+
+func (r *cachedPlanRetrier) Exec(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error) {
+	tag, err := r.q.Exec(ctx, sql, args...)
+	if isCachedPlanError(err) {
+		return r.q.Exec(ctx, sql, args...)
+	}
+	return tag, err
+}
+
+func (r *cachedPlanRetrier) Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error) {
+	rows, err := r.q.Query(ctx, sql, args...)
+	if isCachedPlanError(err) {
+		return r.q.Query(ctx, sql, args...)
+	}
+	return rows, err
+}
+
+func (r *cachedPlanRetrier) QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row {
+	return &cachedPlanRow{ctx: ctx, q: r.q, sql: sql, args: args}
+}
+
+// cachedPlanRow runs its query when scanned, so it can be run again
+type cachedPlanRow struct {
+	ctx  context.Context
+	q    Querier
+	sql  string
+	args []interface{}
+}
+
+func (r *cachedPlanRow) Scan(dest ...interface{}) error {
+	err := r.q.QueryRow(r.ctx, r.sql, r.args...).Scan(dest...)
+	if isCachedPlanError(err) {
+		return r.q.QueryRow(r.ctx, r.sql, r.args...).Scan(dest...)
+	}
+	return err
+}
+
+// isCachedPlanError reports whether err is the server refusing a prepared
+// statement whose result type changed. The message may be localized, so
+// the routine raising it is checked along with the code
+func isCachedPlanError(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "0A000" && pgErr.Routine == "RevalidateCachedQuery"
+}
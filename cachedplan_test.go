@@ -0,0 +1,89 @@
+package pgxtls
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+)
+
+// failingQuerier fails its first fails queries with err
+type failingQuerier struct {
+	err   error
+	fails int
+	runs  int
+}
+
+func (q *failingQuerier) result() error {
+	q.runs++
+	if q.runs <= q.fails {
+		return q.err
+	}
+	return nil
+}
+
+func (q *failingQuerier) Query(context.Context, string, ...interface{}) (pgx.Rows, error) {
+	if err := q.result(); err != nil {
+		return nil, err
+	}
+	return &countRows{}, nil
+}
+
+func (q *failingQuerier) QueryRow(context.Context, string, ...interface{}) pgx.Row {
+	return errRow{q.result()}
+}
+
+func (q *failingQuerier) Exec(context.Context, string, ...interface{}) (pgconn.CommandTag, error) {
+	return nil, q.result()
+}
+
+func TestRetryCachedPlan(t *testing.T) {
+	cachedPlan := &pgconn.PgError{Code: "0A000", Message: "cached plan must not change result type", Routine: "RevalidateCachedQuery"}
+	ctx := context.Background()
+
+	methods := map[string]func(q Querier) error{
+		"Exec": func(q Querier) error {
+			_, err := q.Exec(ctx, "UPDATE jobs SET done = true")
+			return err
+		},
+		"Query": func(q Querier) error {
+			_, err := q.Query(ctx, "SELECT * FROM jobs")
+			return err
+		},
+		"QueryRow": func(q Querier) error {
+			return q.QueryRow(ctx, "SELECT * FROM jobs").Scan()
+		},
+	}
+
+	tests := []struct {
+		name     string
+		err      error
+		fails    int
+		wantRuns int
+		wantErr  bool
+	}{
+		{name: "succeeds", wantRuns: 1},
+		{name: "stale plan", err: cachedPlan, fails: 1, wantRuns: 2},
+		{name: "wrapped stale plan", err: fmt.Errorf("query failed: %w", cachedPlan), fails: 1, wantRuns: 2},
+		{name: "still stale", err: cachedPlan, fails: 2, wantRuns: 2, wantErr: true},
+		{name: "other feature error", err: &pgconn.PgError{Code: "0A000", Routine: "transformLockingClause"}, fails: 1, wantRuns: 1, wantErr: true},
+		{name: "other error", err: errors.New("conn closed"), fails: 1, wantRuns: 1, wantErr: true},
+	}
+	for name, run := range methods {
+		for _, tt := range tests {
+			t.Run(name+" "+tt.name, func(t *testing.T) {
+				q := &failingQuerier{err: tt.err, fails: tt.fails}
+				err := run(RetryCachedPlan(q))
+				if (err != nil) != tt.wantErr {
+					t.Fatalf("error = %v, wantErr %v", err, tt.wantErr)
+				}
+				if q.runs != tt.wantRuns {
+					t.Fatalf("ran %d times, want %d", q.runs, tt.wantRuns)
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package pgxtls
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/jackc/pgx/v4"
+)
+
+// cancelRequestCode identifies a CancelRequest message
+const cancelRequestCode = 80877102
+
+// CancelQuery asks the server to cancel the query conn is running, from a
+// new connection to the same server. Unlike pgconn's CancelRequest the
+// request goes over TLS when conn does, verified with conn's tls.Config,
+// for servers refusing unencrypted connections. Pools created WithTLSUpgrade
+// negotiate TLS as they dial, so the request goes over the connection their
+// upgrade encrypted with its tls.Config. Server side the query fails with a
+// query_canceled error, if it is still running
+func CancelQuery(ctx context.Context, conn *pgx.Conn) error {
+	pgConn := conn.PgConn()
+	addr := pgConn.Conn().RemoteAddr()
+
+	netConn, err := conn.Config().DialFunc(ctx, addr.Network(), addr.String())
+	if err != nil {
+		return fmt.Errorf("unable to dial for cancel request: %v", err)
+	}
+	defer netConn.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		netConn.SetDeadline(deadline)
+	}
+
+	// the DialFunc of upgraded pools returns encrypted connections already
+	_, upgraded := netConn.(interface{ ConnectionState() tls.ConnectionState })
+	if tlsConn, ok := pgConn.Conn().(*tls.Conn); ok && !upgraded {
+		tlsCfg := cancelTLSConfig(conn.Config())
+		if tlsCfg == nil {
+			return errors.New("connection uses tls but has no tls config to cancel with")
+		}
+		if name := tlsConn.ConnectionState().ServerName; name != "" {
+			tlsCfg.ServerName = name
+		}
+
+		if err := requestSSL(netConn); err != nil {
+			return fmt.Errorf("unable to start tls for cancel request: %v", err)
+		}
+		netConn = tls.Client(netConn, tlsCfg)
+	}
+
+	msg := make([]byte, 16)
+	binary.BigEndian.PutUint32(msg[0:4], 16)
+	binary.BigEndian.PutUint32(msg[4:8], cancelRequestCode)
+	binary.BigEndian.PutUint32(msg[8:12], pgConn.PID())
+	binary.BigEndian.PutUint32(msg[12:16], pgConn.SecretKey())
+	if _, err := netConn.Write(msg); err != nil {
+		return fmt.Errorf("unable to send cancel request: %v", err)
+	}
+
+	// the server closes the connection once it has read the request
+	if _, err := netConn.Read(make([]byte, 1)); err != io.EOF && err != io.ErrUnexpectedEOF {
+		return fmt.Errorf("unable to send cancel request: %v", err)
+	}
+	return nil
+}
+
+// cancelTLSConfig returns a copy of the first tls.Config cc connects with
+func cancelTLSConfig(cc *pgx.ConnConfig) *tls.Config {
+	if cc.TLSConfig != nil {
+		return cc.TLSConfig.Clone()
+	}
+	for _, fb := range cc.Fallbacks {
+		if fb.TLSConfig != nil {
+			return fb.TLSConfig.Clone()
+		}
+	}
+	return nil
+}
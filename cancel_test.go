@@ -0,0 +1,95 @@
+package pgxtls
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/danvixent/pgxtls/config"
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+)
+
+func TestCancelQuery(t *testing.T) {
+	tests := []struct {
+		name    string
+		useTLS  bool
+		opts    []Option
+		wantTLS bool
+	}{
+		{name: "plaintext"},
+		{name: "tls", useTLS: true, wantTLS: true},
+		{name: "tls upgrade", useTLS: true, opts: []Option{WithTLSUpgrade(DefaultTLSUpgrade)}, wantTLS: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newFakeServer(t)
+			var c *config.ConfigMap
+			if tt.useTLS {
+				c = tlsConfigMap(t, srv)
+			} else {
+				c = srv.configMap(t)
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			p, err := NewFromCfgMap(ctx, c, nil, tt.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer p.Close()
+			conn, err := p.Acquire(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Release()
+			if _, ok := conn.Conn().PgConn().Conn().(*tls.Conn); ok != tt.wantTLS {
+				t.Fatalf("connected with tls %v", ok)
+			}
+
+			if err := CancelQuery(ctx, conn.Conn()); err != nil {
+				t.Fatal(err)
+			}
+			waitFor(t, func() bool { return len(srv.cancels()) > 0 })
+			if got, want := fmt.Sprint(srv.cancels()), fmt.Sprint([]uint32{conn.Conn().PgConn().PID()}); got != want {
+				t.Fatalf("canceled %s, want %s", got, want)
+			}
+		})
+	}
+}
+
+func TestCancelTLSConfig(t *testing.T) {
+	tlsFor := func(name string) *tls.Config { return &tls.Config{ServerName: name} }
+	tests := []struct {
+		name      string
+		primary   *tls.Config
+		fallbacks []*pgconn.FallbackConfig
+		want      string
+	}{
+		{"primary", tlsFor("a.example.com"), []*pgconn.FallbackConfig{{TLSConfig: tlsFor("b.example.com")}}, "a.example.com"},
+		{"fallback", nil, []*pgconn.FallbackConfig{{}, {TLSConfig: tlsFor("b.example.com")}}, "b.example.com"},
+		{"none", nil, []*pgconn.FallbackConfig{{}}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cc := &pgx.ConnConfig{}
+			cc.TLSConfig, cc.Fallbacks = tt.primary, tt.fallbacks
+
+			got := cancelTLSConfig(cc)
+			if tt.want == "" {
+				if got != nil {
+					t.Fatalf("cancelTLSConfig() = %v, want nil", got)
+				}
+				return
+			}
+			if got == nil || got.ServerName != tt.want {
+				t.Fatalf("cancelTLSConfig() = %v, want ServerName %s", got, tt.want)
+			}
+			if got == tt.primary {
+				t.Fatal("cancelTLSConfig() returned the connection's own tls.Config")
+			}
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package pgxtls
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/danvixent/pgxtls/config"
+	pool "github.com/jackc/pgx/v4/pgxpool"
+)
+
+// CloudSQLDialFunc dials a Cloud SQL instance by its connection name,
+// cloudsqlconn.Dialer's Dial method satisfies it:
+//
+//	d, err := cloudsqlconn.NewDialer(ctx)
+//	...
+//	opt := WithCloudSQLDialer("project:region:instance", func(ctx context.Context, icn string) (net.Conn, error) {
+//		return d.Dial(ctx, icn)
+//	})
+type CloudSQLDialFunc func(ctx context.Context, instanceConnectionName string) (net.Conn, error)
+
+type cloudSQL struct {
+	instance string
+	dial     CloudSQLDialFunc
+}
+
+// WithCloudSQLDialer connects to instanceConnectionName through the
+// Cloud SQL connector's dial. The connector encrypts and authenticates
+// the connection itself, so the ssl files in the ConfigMap are not used,
+// and dials the instance by name, so DbHost needn't resolve. It replaces
+// the pool's dialing, pools aren't created with settings changing how
+// or where to dial too
+func WithCloudSQLDialer(instanceConnectionName string, dial CloudSQLDialFunc) Option {
+	return func(o *options) {
+		o.cloudSQL = &cloudSQL{instance: instanceConnectionName, dial: dial}
+	}
+}
+
+// checkCloudSQL returns an error if config or o set up dialing that the
+// Cloud SQL connector would silently replace
+func checkCloudSQL(config *config.ConfigMap, o *options) error {
+	var conflicts []string
+	if config.UseDefaultPgxDialer {
+		conflicts = append(conflicts, "UseDefaultPgxDialer")
+	}
+	if config.LocalAddr != "" {
+		conflicts = append(conflicts, "LocalAddr")
+	}
+	if config.DialNetwork != "" {
+		conflicts = append(conflicts, "DialNetwork")
+	}
+	if strings.Contains(config.DbHost, ",") {
+		conflicts = append(conflicts, "several hosts in DbHost")
+	}
+	if o.proxyProtocol != 0 {
+		conflicts = append(conflicts, "WithProxyProtocol")
+	}
+	if o.hostSelector != nil {
+		conflicts = append(conflicts, "WithHostSelector")
+	}
+
+	if len(conflicts) > 0 {
+		return fmt.Errorf("WithCloudSQLDialer dials the instance itself and can't be combined with %s", strings.Join(conflicts, ", "))
+	}
+	return nil
+}
+
+// useCloudSQL routes every connection of cfg through the connector
+// and disables pgx's own tls negotiation on top of it
+func useCloudSQL(cfg *pool.Config, c *cloudSQL) {
+	cfg.ConnConfig.LookupFunc = func(_ context.Context, host string) ([]string, error) {
+		return []string{host}, nil
+	}
+	cfg.ConnConfig.DialFunc = func(ctx context.Context, _, _ string) (net.Conn, error) {
+		return c.dial(ctx, c.instance)
+	}
+
+	cfg.ConnConfig.TLSConfig = nil
+	for _, fb := range cfg.ConnConfig.Fallbacks {
+		fb.TLSConfig = nil
+	}
+}
@@ -0,0 +1,107 @@
+package pgxtls
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/danvixent/pgxtls/config"
+	"github.com/jackc/pgconn"
+)
+
+func TestUseCloudSQL(t *testing.T) {
+	cfg := newFakeServer(t).poolConfig(t)
+	cfg.ConnConfig.TLSConfig = &tls.Config{ServerName: "db.example.com"}
+	cfg.ConnConfig.Fallbacks = []*pgconn.FallbackConfig{{Host: "db.example.com", Port: 5432, TLSConfig: &tls.Config{}}}
+
+	var dialed []string
+	useCloudSQL(cfg, &cloudSQL{instance: "project:region:instance", dial: func(_ context.Context, icn string) (net.Conn, error) {
+		dialed = append(dialed, icn)
+		return nil, nil
+	}})
+
+	if cfg.ConnConfig.TLSConfig != nil || cfg.ConnConfig.Fallbacks[0].TLSConfig != nil {
+		t.Fatal("pgx still negotiates tls over the connector")
+	}
+	for _, addr := range []string{"127.0.0.1:5432", "db.example.com:5432"} {
+		if _, err := cfg.ConnConfig.DialFunc(context.Background(), "tcp", addr); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(dialed) != 2 || dialed[0] != "project:region:instance" || dialed[1] != "project:region:instance" {
+		t.Fatalf("dialed %v, want the instance for every address", dialed)
+	}
+}
+
+func TestWithCloudSQLDialer(t *testing.T) {
+	srv := newFakeServer(t)
+	c := srv.configMap(t)
+	// the connector encrypts, so the ssl settings are ignored
+	c.SSLMode, c.DbHost = config.SSLModeVerifyFull, "unused.example.com"
+
+	dialer := &net.Dialer{}
+	ctx := context.Background()
+	p, err := NewFromCfgMap(ctx, c, nil, WithCloudSQLDialer("project:region:instance", func(ctx context.Context, icn string) (net.Conn, error) {
+		if icn != "project:region:instance" {
+			t.Errorf("dialed instance %q", icn)
+		}
+		return dialer.DialContext(ctx, "tcp", srv.addr())
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	if err := p.Ping(ctx); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWithCloudSQLDialerConflicts(t *testing.T) {
+	tests := []struct {
+		name    string
+		setup   func(c *config.ConfigMap)
+		opts    []Option
+		wantErr string
+	}{
+		{name: "alone"},
+		{name: "local addr", setup: func(c *config.ConfigMap) { c.LocalAddr = "127.0.0.1" }, wantErr: "LocalAddr"},
+		{name: "dial network", setup: func(c *config.ConfigMap) { c.DialNetwork = "tcp4" }, wantErr: "DialNetwork"},
+		{name: "default pgx dialer", setup: func(c *config.ConfigMap) { c.UseDefaultPgxDialer = true }, wantErr: "UseDefaultPgxDialer"},
+		{name: "several hosts", setup: func(c *config.ConfigMap) { c.DbHost = "db1.example.com,db2.example.com" }, wantErr: "several hosts"},
+		{name: "proxy protocol", opts: []Option{WithProxyProtocol(2)}, wantErr: "WithProxyProtocol"},
+		{name: "host selector", opts: []Option{WithHostSelector(RoundRobin())}, wantErr: "WithHostSelector"},
+		{
+			name: "several conflicts",
+			setup: func(c *config.ConfigMap) {
+				c.LocalAddr, c.DialNetwork = "127.0.0.1", "tcp4"
+			},
+			wantErr: "LocalAddr, DialNetwork",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newFakeServer(t).configMap(t)
+			if tt.setup != nil {
+				tt.setup(c)
+			}
+			o := newOptions(append(tt.opts, WithCloudSQLDialer("project:region:instance", func(context.Context, string) (net.Conn, error) {
+				return nil, nil
+			})))
+
+			_, err := newPoolConfig(context.Background(), c, nil, o)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("newPoolConfig() = %v, want an error naming %s", err, tt.wantErr)
+			}
+		})
+	}
+}
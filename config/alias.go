@@ -0,0 +1,54 @@
+package config
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+//fieldAliases maps former or alternative config keys to the ConfigMap
+//field they now populate, so older config files keep loading
+var fieldAliases = map[string]string{
+	"maxconnections": "MaxConns",
+	"sslcert":        "SSLCertFile",
+	"sslkey":         "SSLKeyFile",
+	"sslrootcert":    "SSLCAFile",
+	"sslpassword":    "SSLKeyFilePassPhrase",
+}
+
+//canonicalKey returns the field key names an alias of, or key itself
+func canonicalKey(key string) string {
+	if field, ok := fieldAliases[strings.ToLower(key)]; ok {
+		return field
+	}
+	return key
+}
+
+//UnmarshalJSON decodes c like encoding/json would,
+//also accepting the aliases in fieldAliases
+func (c *ConfigMap) UnmarshalJSON(data []byte) error {
+	fields := map[string]json.RawMessage{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+
+	for key, raw := range fields {
+		field := canonicalKey(key)
+		if field == key {
+			continue
+		}
+
+		delete(fields, key)
+		// the current key wins over an alias
+		if _, ok := fields[field]; !ok {
+			fields[field] = raw
+		}
+	}
+
+	resolved, err := json.Marshal(fields)
+	if err != nil {
+		return err
+	}
+
+	type plain ConfigMap
+	return json.Unmarshal(resolved, (*plain)(c))
+}
@@ -0,0 +1,68 @@
+package config
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestCanonicalKey(t *testing.T) {
+	tests := []struct {
+		key, want string
+	}{
+		{"maxconnections", "MaxConns"},
+		{"MaxConnections", "MaxConns"},
+		{"sslrootcert", "SSLCAFile"},
+		{"sslpassword", "SSLKeyFilePassPhrase"},
+		{"MaxConns", "MaxConns"},
+		{"DbHost", "DbHost"},
+	}
+	for _, tt := range tests {
+		if got := canonicalKey(tt.key); got != tt.want {
+			t.Errorf("canonicalKey(%q) = %q, want %q", tt.key, got, tt.want)
+		}
+	}
+}
+
+func TestUnmarshalJSONAliases(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want ConfigMap
+	}{
+		{
+			name: "current keys",
+			data: `{"MaxConns": 5, "SSLCertFile": "client.crt"}`,
+			want: ConfigMap{MaxConns: 5, SSLCertFile: "client.crt"},
+		},
+		{
+			name: "aliases",
+			data: `{"maxconnections": 5, "sslcert": "client.crt", "sslkey": "client.key", "sslrootcert": "ca.crt", "sslpassword": "secret"}`,
+			want: ConfigMap{MaxConns: 5, SSLCertFile: "client.crt", SSLKeyFile: "client.key", SSLCAFile: "ca.crt", SSLKeyFilePassPhrase: "secret"},
+		},
+		{
+			name: "current key wins",
+			data: `{"sslcert": "old.crt", "SSLCertFile": "client.crt", "MaxConns": 5, "maxconnections": 9}`,
+			want: ConfigMap{MaxConns: 5, SSLCertFile: "client.crt"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got ConfigMap
+			if err := json.Unmarshal([]byte(tt.data), &got); err != nil {
+				t.Fatal(err)
+			}
+			if got.MaxConns != tt.want.MaxConns || got.SSLCertFile != tt.want.SSLCertFile ||
+				got.SSLKeyFile != tt.want.SSLKeyFile || got.SSLCAFile != tt.want.SSLCAFile ||
+				got.SSLKeyFilePassPhrase != tt.want.SSLKeyFilePassPhrase {
+				t.Fatalf("decoded %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnmarshalJSONInvalid(t *testing.T) {
+	var c ConfigMap
+	if err := json.Unmarshal([]byte(`{"sslcert": 5}`), &c); err == nil {
+		t.Fatal("number accepted for SSLCertFile")
+	}
+}
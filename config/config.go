@@ -3,50 +3,140 @@ package config
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
+	"math/big"
 	"os"
+	"reflect"
 	"strconv"
+	"strings"
+	"time"
+
+	// TimeZone is validated against this zone database on hosts without
+	// their own, such as scratch or distroless containers
+	_ "time/tzdata"
 
 	"github.com/asaskevich/govalidator"
 )
 
 //ConfigMap holds configuration data
 type ConfigMap struct {
-	DbName               string `validate:"required"` // name of database to connect to
-	DbHost               string `validate:"required"` // database server hostname
-	DbUser               string `validate:"required"` // database user to connect as
-	Password             string `validate:"required"` // password of database user
-	SSLMode              string `validate:"required"` // ssl mode to use when connecting to database
-	SSLCertFile          string `validate:"required"` // .crt file to use for ssl
-	SSLKeyFile           string `validate:"required"` // .key file to use for ssl
-	SSLKeyFilePassPhrase string `validate:"required"` // passphrase for .key file
-	SSLCAFile            string `validate:"required"` // CA authority to trust
-	SSLHostname          string `validate:"required"` // expected hostname on certificate the database server will present
-	ServerPort           uint16 `validate:"required"` // port on which to serve the gRPC server on
-	DbPort               uint16 `validate:"required"` // port on which to connect to database server on
-	MaxConns             uint8  `validate:"required"` // max connections to the database
+	DbName                    string             `validate:"required"` // name of database to connect to
+	DbHost                    string             `validate:"required"` // database server hostname
+	DbUser                    string             `validate:"required"` // database user to connect as
+	Password                  string             `validate:"required"` // password of database user
+	SSLMode                   SSLMode            `validate:"required"` // ssl mode to use when connecting to database
+	SSLCertFile               string             // .crt file to use for ssl, required without SSLCertPEM
+	SSLKeyFile                string             // .key file to use for ssl, required without SSLKeyPEM
+	SSLKeyFilePassPhrase      string             // passphrase for .key file, empty when it isn't encrypted
+	SSLCAFile                 string             // CA authority to trust, with sslmode require the server goes unverified without one
+	SSLHostname               string             // hostname sent as SNI and expected on the server certificate in every sslmode, independent of the DbHost dialed, DbHost with verify-full when empty
+	ServerPort                uint16             `validate:"required"` // port on which to serve the gRPC server on
+	DbPort                    uint16             `validate:"required"` // port on which to connect to database server on
+	MaxConns                  uint8              // max connections to the database, sized from GOMAXPROCS when zero
+	SSLDebug                  bool               // log the negotiated tls parameters of every connection
+	MaxConnLifetimeJitter     time.Duration      // random extra lifetime given to each connection so they don't all expire together
+	ConnectRole               string             // role every connection switches to with SET ROLE after connecting
+	SSLRequireSCT             bool               // reject server certificates without embedded signed certificate timestamps
+	UseDefaultPgxDialer       bool               // dial with pgx's own dialer, which honours dial settings such as connect_timeout
+	MaxConnsPercent           float64            // when set, size the pool to this percentage of the server's max_connections instead of MaxConns
+	QueryExecMode             QueryExecMode      // how queries are sent to the server, simple_protocol when empty
+	Options                   map[string]string  // server settings sent at startup as -c key=value in the options parameter
+	SSLRequireServerAuthEKU   bool               // reject server certificates without the serverAuth extended key usage
+	MinServerVersion          string             // oldest server version to accept, such as "14"
+	DSNScheme                 string             // url scheme of the connection string, postgres or postgresql, postgres when empty
+	SSLInsecureSkipVerify     bool               // skip verifying the server certificate chain, not with verify-ca or verify-full, checks on the leaf such as SSLPinnedSPKI still apply
+	SSLPinnedSPKI             []string           // base64 sha256 digests of the public keys the server certificate may have
+	DevExplain                bool               // log the EXPLAIN ANALYZE plan of every SELECT without arguments, for development only
+	SSLCertChainFile          string             // intermediate certificates sent after the one in SSLCertFile, optional
+	SSLCAFilePassPhrase       string             // passphrase for SSLCAFile, empty when it isn't encrypted
+	StatementCacheCapacity    int                // statements each connection caches when caching, 512 when zero
+	StatementCacheMode        StatementCacheMode // prepare or describe, alternative to the cache_* QueryExecModes
+	DialNetwork               string             // network to dial the database on, tcp4 or tcp6 to force IPv4 or IPv6, tcp when empty
+	SSLCRLFile                string             // certificate revocation list signed by the ca in SSLCAFile, server certificates it revokes are rejected
+	SSLHandshakeTimeout       time.Duration      // longest the tls handshake of a connection may take, only bounded by the connect timeout when zero
+	SSLPinnedCertFingerprints []string           // hex sha256 fingerprints of the certificates the server may present
+	PromptPassphraseIfEmpty   bool               // ask on the terminal for the passphrase of an encrypted SSLKeyFile when SSLKeyFilePassPhrase is empty
+	NoConnectTimeout          bool               // connect without the one minute timeout, bounded only by the context
+	ClientEncoding            string             // character set the server converts text to, such as LATIN1, UTF8 when empty. All but UTF8 need a QueryExecMode other than simple_protocol
+	TimeZone                  string             // session time zone such as Europe/Berlin, the server's when empty
+	SSLCertPEM                string             // PEM encoded client certificate, alternative to SSLCertFile
+	SSLKeyPEM                 string             // PEM encoded client key, alternative to SSLKeyFile
+	SSLCAPEM                  string             // PEM encoded CA authority to trust, alternative to SSLCAFile
+	SSLExpectedServerSerial   string             // hex serial the server certificate must have, as openssl x509 -serial prints it
+	MinConns                  uint8              // connections the pool keeps open even when idle
+	MaxConnLifetime           time.Duration      // age after which a connection is closed, an hour when zero
+	MaxConnIdleTime           time.Duration      // idle time after which a connection is closed, 30 minutes when zero
+	HealthCheckPeriod         time.Duration      // how often idle connections are checked, a minute when zero
+	PrepareStatements         map[string]string  // statements prepared on every new connection, keyed by name
+	RawDSNValues              bool               // put DbUser, Password and DbName in the DSN verbatim, for values that are already url escaped
+	LocalAddr                 string             // source IP address, optionally with a port, outgoing connections are bound to
+}
+
+//LoadOption configures how a config file is loaded
+type LoadOption func(*loadOptions)
+
+type loadOptions struct {
+	noExpandEnv bool
+}
+
+//WithoutEnvExpansion disables expansion of ${VAR} and $VAR
+//sequences in string fields, for values containing a literal '$'
+func WithoutEnvExpansion() LoadOption {
+	return func(o *loadOptions) {
+		o.noExpandEnv = true
+	}
 }
 
-//FromFile returns a New ConfigMap with values parsed from file
-func FromFile(file string) (*ConfigMap, error) {
-	return parse(&file)
+//FromFile returns a New ConfigMap with values parsed from file.
+//${VAR} and $VAR sequences in string fields are replaced with
+//the value of the environment variable unless WithoutEnvExpansion is given
+func FromFile(file string, opts ...LoadOption) (*ConfigMap, error) {
+	return parse(&file, opts...)
 }
 
-func parse(cfile *string) (*ConfigMap, error) {
+func parse(cfile *string, opts ...LoadOption) (*ConfigMap, error) {
 	file, err := os.Open(*cfile)
 	if err != nil {
 		return nil, err
 	}
-	config := &ConfigMap{}
+	defer file.Close()
+
+	config, err := decode(file)
+	if err != nil {
+		return nil, err
+	}
+	return config.load(newLoadOptions(opts))
+}
 
-	if err := json.NewDecoder(file).Decode(config); err != nil {
+//decode reads the ConfigMap in r, every config file is decoded with it
+func decode(r io.Reader) (*ConfigMap, error) {
+	config := &ConfigMap{}
+	if err := json.NewDecoder(r).Decode(config); err != nil {
 		return nil, errors.New("can't parse config file: " + err.Error())
 	}
+	return config, nil
+}
+
+func newLoadOptions(opts []LoadOption) *loadOptions {
+	lo := &loadOptions{}
+	for _, opt := range opts {
+		opt(lo)
+	}
+	return lo
+}
+
+//load finishes loading a freshly decoded config
+func (c *ConfigMap) load(lo *loadOptions) (*ConfigMap, error) {
+	if !lo.noExpandEnv {
+		c.expandEnv()
+	}
 
-	if err = config.validate(); err != nil {
+	if err := c.validate(); err != nil {
 		return nil, err
 	}
 
-	return config, nil
+	return c, nil
 }
 
 //FromEnv fetches configuration data from environment variables
@@ -61,9 +151,11 @@ func FromEnv() (*ConfigMap, error) {
 		return nil, err
 	}
 
-	maxConns, err := strconv.Atoi(os.Getenv("MAX_CONNS"))
-	if err != nil {
-		return nil, err
+	var maxConns int
+	if v := os.Getenv("MAX_CONNS"); v != "" {
+		if maxConns, err = strconv.Atoi(v); err != nil {
+			return nil, err
+		}
 	}
 
 	config := &ConfigMap{
@@ -71,19 +163,167 @@ func FromEnv() (*ConfigMap, error) {
 		DbHost:     os.Getenv("DB_HOST"),
 		DbUser:     os.Getenv("DB_USER"),
 		Password:   os.Getenv("DB_PASSWORD"),
-		SSLMode:    os.Getenv("SSL_MODE"),
+		SSLMode:    SSLMode(os.Getenv("SSL_MODE")),
 		ServerPort: uint16(srvPort),
 		DbPort:     uint16(dbport),
 		MaxConns:   uint8(maxConns),
 	}
 
+	for field, env := range envB64Vars {
+		pem, _, err := lookupEnvB64(env)
+		if err != nil {
+			return nil, err
+		}
+		reflect.ValueOf(config).Elem().FieldByName(field).SetString(pem)
+	}
+
 	if err := config.validate(); err != nil {
 		return nil, err
 	}
 	return config, nil
 }
 
+//expandEnv replaces environment variable references
+//in every string field of c
+func (c *ConfigMap) expandEnv() {
+	v := reflect.ValueOf(c).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		if f := v.Field(i); f.Kind() == reflect.String {
+			f.SetString(os.Expand(f.String(), os.Getenv))
+		}
+	}
+}
+
+//Validate checks c for errors that prevent connecting and returns
+//them in err. Settings that work but are unsafe are returned as warnings
+func (c *ConfigMap) Validate() (warnings []string, err error) {
+	if err := c.validate(); err != nil {
+		return nil, err
+	}
+
+	switch c.SSLMode {
+	case SSLModeDisable, SSLModeAllow, SSLModePrefer:
+		warnings = append(warnings, fmt.Sprintf("sslmode %s allows unencrypted connections", c.SSLMode))
+	}
+
+	if c.SSLMode == SSLModeRequire && c.SSLCAFile == "" && c.SSLCAPEM == "" {
+		warnings = append(warnings, "sslmode require without SSLCAFile encrypts connections but doesn't verify the server")
+	}
+
+	if c.SSLInsecureSkipVerify && len(c.SSLPinnedSPKI) == 0 && len(c.SSLPinnedCertFingerprints) == 0 {
+		warnings = append(warnings, "SSLInsecureSkipVerify is set without SSLPinnedSPKI or SSLPinnedCertFingerprints, any server certificate is accepted")
+	}
+
+	if info, err := os.Stat(c.SSLKeyFile); err == nil && info.Mode().Perm()&0o004 != 0 {
+		warnings = append(warnings, fmt.Sprintf("key file %s is world readable", c.SSLKeyFile))
+	}
+
+	return warnings, nil
+}
+
 func (c *ConfigMap) validate() error {
-	_, err := govalidator.ValidateStruct(c)
-	return err
+	// govalidator only reads valid tags, so the validate:"required"
+	// tags of ConfigMap aren't enforced and the ports are checked here
+	if err := validatePort("DbPort", c.DbPort); err != nil {
+		return err
+	}
+	if err := validatePort("ServerPort", c.ServerPort); err != nil {
+		return err
+	}
+
+	if _, err := govalidator.ValidateStruct(c); err != nil {
+		return err
+	}
+
+	if c.SSLCertFile == "" && c.SSLCertPEM == "" {
+		return errors.New("SSLCertFile or SSLCertPEM is required")
+	}
+	if c.SSLKeyFile == "" && c.SSLKeyPEM == "" {
+		return errors.New("SSLKeyFile or SSLKeyPEM is required")
+	}
+
+	if _, err := ParseSSLMode(string(c.SSLMode)); err != nil {
+		return err
+	}
+
+	if err := c.validateExclusive(); err != nil {
+		return err
+	}
+
+	if c.QueryExecMode != "" {
+		if _, err := ParseQueryExecMode(string(c.QueryExecMode)); err != nil {
+			return err
+		}
+	}
+
+	if c.ClientEncoding != "" {
+		if err := c.validateClientEncoding(); err != nil {
+			return err
+		}
+	}
+
+	if c.TimeZone != "" {
+		// the server has its own zone database, but one unknown here is most likely a typo
+		if _, err := time.LoadLocation(c.TimeZone); err != nil {
+			return fmt.Errorf("invalid TimeZone %q: %v", c.TimeZone, err)
+		}
+	}
+
+	if c.SSLHostname != "" {
+		if err := validateHostname(c.SSLHostname); err != nil {
+			return err
+		}
+	}
+
+	if c.SSLExpectedServerSerial != "" {
+		if _, ok := new(big.Int).SetString(strings.ReplaceAll(c.SSLExpectedServerSerial, ":", ""), 16); !ok {
+			return fmt.Errorf("invalid SSLExpectedServerSerial %q, must be hex", c.SSLExpectedServerSerial)
+		}
+	}
+
+	if err := c.validateStatementCache(); err != nil {
+		return err
+	}
+
+	if c.MinServerVersion != "" {
+		if _, err := ServerVersionNum(c.MinServerVersion); err != nil {
+			return err
+		}
+	}
+
+	switch c.DSNScheme {
+	case "", "postgres", "postgresql":
+	default:
+		return fmt.Errorf("invalid DSNScheme %q, must be postgres or postgresql", c.DSNScheme)
+	}
+
+	if c.LocalAddr != "" {
+		if err := validateLocalAddr(c.LocalAddr); err != nil {
+			return err
+		}
+	}
+
+	switch c.DialNetwork {
+	case "", "tcp", "tcp4", "tcp6":
+	default:
+		return fmt.Errorf("invalid DialNetwork %q, must be tcp, tcp4 or tcp6", c.DialNetwork)
+	}
+
+	if c.MaxConnsPercent < 0 || c.MaxConnsPercent > 100 {
+		return fmt.Errorf("MaxConnsPercent must be between 0 and 100, got %v", c.MaxConnsPercent)
+	}
+
+	// a zero MaxConns is sized when the pool is created, and checked then
+	if c.MaxConns > 0 && c.MinConns > c.MaxConns {
+		return fmt.Errorf("MinConns (%d) must not exceed MaxConns (%d)", c.MinConns, c.MaxConns)
+	}
+	return nil
+}
+
+//validatePort rejects port 0, the one uint16 value that isn't a usable port
+func validatePort(field string, port uint16) error {
+	if port == 0 {
+		return fmt.Errorf("%s must be between 1 and 65535, got 0", field)
+	}
+	return nil
 }
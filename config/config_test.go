@@ -0,0 +1,340 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+//testConfig returns a config that passes validation
+func testConfig() *ConfigMap {
+	return &ConfigMap{
+		DbName:      "db",
+		DbHost:      "db.example.com",
+		DbUser:      "user",
+		Password:    "secret",
+		SSLMode:     SSLModeVerifyFull,
+		SSLCertFile: "client.crt",
+		SSLKeyFile:  "client.key",
+		ServerPort:  8080,
+		DbPort:      5432,
+	}
+}
+
+func TestValidate(t *testing.T) {
+	if err := testConfig().validate(); err != nil {
+		t.Fatalf("test config is invalid: %v", err)
+	}
+}
+
+func TestValidateMinConns(t *testing.T) {
+	tests := []struct {
+		name               string
+		minConns, maxConns uint8
+		wantErr            bool
+	}{
+		{name: "below", minConns: 2, maxConns: 10},
+		{name: "equal", minConns: 10, maxConns: 10},
+		{name: "inverted", minConns: 11, maxConns: 10, wantErr: true},
+		{name: "auto sized", minConns: 11},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := testConfig()
+			c.MinConns, c.MaxConns = tt.minConns, tt.maxConns
+			if _, err := c.Validate(); (err != nil) != tt.wantErr {
+				t.Fatalf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidatePort(t *testing.T) {
+	tests := []struct {
+		name            string
+		dbPort, srvPort uint16
+		wantErr         string
+	}{
+		{name: "valid", dbPort: 5432, srvPort: 8080},
+		{name: "highest", dbPort: 65535, srvPort: 1},
+		{name: "no db port", dbPort: 0, srvPort: 8080, wantErr: "DbPort must be between 1 and 65535"},
+		{name: "no server port", dbPort: 5432, srvPort: 0, wantErr: "ServerPort must be between 1 and 65535"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := testConfig()
+			c.DbPort, c.ServerPort = tt.dbPort, tt.srvPort
+			err := c.validate()
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Fatalf("validate() = %v, want no error", err)
+			case tt.wantErr != "" && (err == nil || err.Error() != tt.wantErr+", got 0"):
+				t.Fatalf("validate() = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateTimeZone(t *testing.T) {
+	tests := []struct {
+		zone    string
+		wantErr bool
+	}{
+		{zone: "UTC"},
+		{zone: "Europe/Berlin"},
+		{zone: "America/Argentina/Buenos_Aires"},
+		{zone: "EST5EDT"},
+		{zone: "Mars/Olympus_Mons", wantErr: true},
+		{zone: "Europe/Berlin; DROP TABLE users", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.zone, func(t *testing.T) {
+			c := testConfig()
+			c.TimeZone = tt.zone
+			if err := c.validate(); (err != nil) != tt.wantErr {
+				t.Fatalf("validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateSSLMaterial(t *testing.T) {
+	tests := []struct {
+		name              string
+		certFile, certPEM string
+		keyFile, keyPEM   string
+		wantErr           string
+	}{
+		{name: "files", certFile: "client.crt", keyFile: "client.key"},
+		{name: "pem", certPEM: "cert", keyPEM: "key"},
+		{name: "mixed", certPEM: "cert", keyFile: "client.key"},
+		{name: "no cert", keyFile: "client.key", wantErr: "SSLCertFile or SSLCertPEM is required"},
+		{name: "no key", certPEM: "cert", wantErr: "SSLKeyFile or SSLKeyPEM is required"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := testConfig()
+			c.SSLCertFile, c.SSLCertPEM = tt.certFile, tt.certPEM
+			c.SSLKeyFile, c.SSLKeyPEM = tt.keyFile, tt.keyPEM
+			err := c.validate()
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Fatalf("validate() = %v, want no error", err)
+			case tt.wantErr != "" && (err == nil || err.Error() != tt.wantErr):
+				t.Fatalf("validate() = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateWarnsUnverifiedRequire(t *testing.T) {
+	tests := []struct {
+		name        string
+		caFile      string
+		caPEM       string
+		wantWarning bool
+	}{
+		{name: "no ca", wantWarning: true},
+		{name: "ca file", caFile: "ca.crt"},
+		{name: "ca pem", caPEM: "ca"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := testConfig()
+			c.SSLMode, c.SSLCAFile, c.SSLCAPEM = SSLModeRequire, tt.caFile, tt.caPEM
+			warnings, err := c.Validate()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := len(warnings) > 0; got != tt.wantWarning {
+				t.Fatalf("Validate() warnings = %q, want a warning %v", warnings, tt.wantWarning)
+			}
+		})
+	}
+}
+
+func TestFromFileExpandsEnv(t *testing.T) {
+	t.Setenv("PGXTLS_TEST_PASSWORD", "from env")
+	t.Setenv("PGXTLS_TEST_DIR", "/etc/ssl")
+	os.Unsetenv("PGXTLS_TEST_UNSET")
+
+	tests := []struct {
+		name         string
+		password     string
+		opts         []LoadOption
+		wantPassword string
+		wantCert     string
+	}{
+		{name: "braces", password: "${PGXTLS_TEST_PASSWORD}", wantPassword: "from env", wantCert: "/etc/ssl/client.crt"},
+		{name: "bare", password: "$PGXTLS_TEST_PASSWORD", wantPassword: "from env", wantCert: "/etc/ssl/client.crt"},
+		{name: "embedded", password: "pre-${PGXTLS_TEST_PASSWORD}-post", wantPassword: "pre-from env-post", wantCert: "/etc/ssl/client.crt"},
+		{name: "unset", password: "x${PGXTLS_TEST_UNSET}", wantPassword: "x", wantCert: "/etc/ssl/client.crt"},
+		{name: "disabled", password: "pa$$word", opts: []LoadOption{WithoutEnvExpansion()}, wantPassword: "pa$$word", wantCert: "${PGXTLS_TEST_DIR}/client.crt"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := testConfig()
+			c.Password, c.SSLCertFile = tt.password, "${PGXTLS_TEST_DIR}/client.crt"
+			data, err := c.MarshalJSONUnredacted()
+			if err != nil {
+				t.Fatal(err)
+			}
+			file := filepath.Join(t.TempDir(), "config.json")
+			if err := os.WriteFile(file, data, 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := FromFile(file, tt.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Password != tt.wantPassword || got.SSLCertFile != tt.wantCert {
+				t.Fatalf("Password = %q, SSLCertFile = %q, want %q, %q", got.Password, got.SSLCertFile, tt.wantPassword, tt.wantCert)
+			}
+		})
+	}
+}
+
+func TestValidateWarnings(t *testing.T) {
+	dir := t.TempDir()
+	private, public := filepath.Join(dir, "private.key"), filepath.Join(dir, "public.key")
+	for file, mode := range map[string]os.FileMode{private: 0o600, public: 0o644} {
+		if err := os.WriteFile(file, []byte("key"), mode); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chmod(file, mode); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name         string
+		change       func(*ConfigMap)
+		wantErr      bool
+		wantWarnings []string
+	}{
+		{name: "verify-full", change: func(c *ConfigMap) {}},
+		{name: "disable", change: func(c *ConfigMap) { c.SSLMode = SSLModeDisable }, wantWarnings: []string{"sslmode disable allows unencrypted connections"}},
+		{name: "prefer", change: func(c *ConfigMap) { c.SSLMode = SSLModePrefer }, wantWarnings: []string{"sslmode prefer allows unencrypted connections"}},
+		{name: "require without ca", change: func(c *ConfigMap) { c.SSLMode = SSLModeRequire }, wantWarnings: []string{"sslmode require without SSLCAFile encrypts connections but doesn't verify the server"}},
+		{name: "skip verify", change: func(c *ConfigMap) {
+			c.SSLMode, c.SSLCAFile, c.SSLInsecureSkipVerify = SSLModeRequire, "ca.crt", true
+		}, wantWarnings: []string{"SSLInsecureSkipVerify is set without SSLPinnedSPKI or SSLPinnedCertFingerprints, any server certificate is accepted"}},
+		{name: "skip verify pinned", change: func(c *ConfigMap) {
+			c.SSLMode, c.SSLCAFile, c.SSLInsecureSkipVerify = SSLModeRequire, "ca.crt", true
+			c.SSLPinnedSPKI = []string{"AAAA"}
+		}},
+		{name: "skip verify fingerprint pinned", change: func(c *ConfigMap) {
+			c.SSLMode, c.SSLCAFile, c.SSLInsecureSkipVerify = SSLModeRequire, "ca.crt", true
+			c.SSLPinnedCertFingerprints = []string{"00"}
+		}},
+		{name: "private key file", change: func(c *ConfigMap) { c.SSLKeyFile = private }},
+		{name: "world readable key file", change: func(c *ConfigMap) { c.SSLKeyFile = public }, wantWarnings: []string{"key file " + public + " is world readable"}},
+		{name: "several", change: func(c *ConfigMap) { c.SSLMode, c.SSLKeyFile = SSLModeAllow, public }, wantWarnings: []string{"sslmode allow allows unencrypted connections", "key file " + public + " is world readable"}},
+		{name: "invalid", change: func(c *ConfigMap) { c.SSLMode, c.DbPort = SSLModeDisable, 0 }, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := testConfig()
+			tt.change(c)
+			warnings, err := c.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if strings.Join(warnings, "\n") != strings.Join(tt.wantWarnings, "\n") {
+				t.Fatalf("Validate() warnings = %q, want %q", warnings, tt.wantWarnings)
+			}
+		})
+	}
+}
+
+func TestValidateMaxConnsPercent(t *testing.T) {
+	tests := []struct {
+		percent float64
+		wantErr bool
+	}{
+		{percent: 0},
+		{percent: 0.5},
+		{percent: 100},
+		{percent: -1, wantErr: true},
+		{percent: 100.5, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.percent), func(t *testing.T) {
+			c := testConfig()
+			c.MaxConns, c.MaxConnsPercent = 0, tt.percent
+			if err := c.validate(); (err != nil) != tt.wantErr {
+				t.Fatalf("validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateDSNScheme(t *testing.T) {
+	tests := []struct {
+		scheme  string
+		wantErr bool
+	}{
+		{scheme: ""},
+		{scheme: "postgres"},
+		{scheme: "postgresql"},
+		{scheme: "mysql", wantErr: true},
+		{scheme: "postgres://", wantErr: true},
+		{scheme: "POSTGRES", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.scheme, func(t *testing.T) {
+			c := testConfig()
+			c.DSNScheme = tt.scheme
+			if err := c.validate(); (err != nil) != tt.wantErr {
+				t.Fatalf("validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateDialNetwork(t *testing.T) {
+	tests := []struct {
+		network string
+		wantErr bool
+	}{
+		{network: ""},
+		{network: "tcp"},
+		{network: "tcp4"},
+		{network: "tcp6"},
+		{network: "udp", wantErr: true},
+		{network: "unix", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.network, func(t *testing.T) {
+			c := testConfig()
+			c.DialNetwork = tt.network
+			if err := c.validate(); (err != nil) != tt.wantErr {
+				t.Fatalf("validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateExpectedServerSerial(t *testing.T) {
+	tests := []struct {
+		serial  string
+		wantErr bool
+	}{
+		{serial: ""},
+		{serial: "1A2B"},
+		{serial: "1a:2b"},
+		{serial: "not hex", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.serial, func(t *testing.T) {
+			c := testConfig()
+			c.SSLExpectedServerSerial = tt.serial
+			if err := c.validate(); (err != nil) != tt.wantErr {
+				t.Fatalf("validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,19 @@
+package config
+
+import "reflect"
+
+//Diff returns the names of the fields whose values differ between c and
+//other, in declaration order, to decide what a reload has to redo. Only
+//names are returned, so secrets that changed aren't revealed
+func (c *ConfigMap) Diff(other *ConfigMap) []string {
+	a, b := reflect.ValueOf(c).Elem(), reflect.ValueOf(other).Elem()
+	t := a.Type()
+
+	var changed []string
+	for i := 0; i < t.NumField(); i++ {
+		if !reflect.DeepEqual(a.Field(i).Interface(), b.Field(i).Interface()) {
+			changed = append(changed, t.Field(i).Name)
+		}
+	}
+	return changed
+}
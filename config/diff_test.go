@@ -0,0 +1,42 @@
+package config
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(c *ConfigMap)
+		want   []string
+	}{
+		{name: "unchanged", change: func(*ConfigMap) {}},
+		{name: "one field", change: func(c *ConfigMap) { c.MaxConns = 20 }, want: []string{"MaxConns"}},
+		{
+			name:   "declaration order",
+			change: func(c *ConfigMap) { c.MinConns, c.Password, c.DbHost = 1, "rotated", "replica.example.com" },
+			want:   []string{"DbHost", "Password", "MinConns"},
+		},
+		{name: "map", change: func(c *ConfigMap) { c.Options = map[string]string{"work_mem": "64MB"} }, want: []string{"Options"}},
+		{name: "slice", change: func(c *ConfigMap) { c.SSLPinnedSPKI = []string{"pin"} }, want: []string{"SSLPinnedSPKI"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			other := testConfig()
+			tt.change(other)
+			if got := testConfig().Diff(other); fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Fatalf("Diff() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDiffComparesValues(t *testing.T) {
+	a, b := testConfig(), testConfig()
+	a.Options = map[string]string{"work_mem": "64MB"}
+	b.Options = map[string]string{"work_mem": "64MB"}
+	if got := a.Diff(b); len(got) != 0 {
+		t.Fatalf("equal options reported as changed: %v", got)
+	}
+}
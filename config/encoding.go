@@ -0,0 +1,74 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+//clientEncodings are the character sets the server can convert to for clients
+var clientEncodings = []string{
+	"BIG5", "EUC_CN", "EUC_JP", "EUC_JIS_2004", "EUC_KR", "EUC_TW",
+	"GB18030", "GBK", "ISO_8859_5", "ISO_8859_6", "ISO_8859_7", "ISO_8859_8",
+	"JOHAB", "KOI8R", "KOI8U", "LATIN1", "LATIN2", "LATIN3", "LATIN4",
+	"LATIN5", "LATIN6", "LATIN7", "LATIN8", "LATIN9", "LATIN10",
+	"MULE_INTERNAL", "SJIS", "SHIFT_JIS_2004", "SQL_ASCII", "UHC", "UTF8",
+	"WIN866", "WIN874", "WIN1250", "WIN1251", "WIN1252", "WIN1253",
+	"WIN1254", "WIN1255", "WIN1256", "WIN1257", "WIN1258",
+}
+
+//clientEncodingAliases are other names the server accepts, keyed as normalized by encodingKey
+var clientEncodingAliases = map[string]string{
+	"unicode":     "UTF8",
+	"iso88591":    "LATIN1",
+	"iso88592":    "LATIN2",
+	"iso88599":    "LATIN5",
+	"iso885915":   "LATIN9",
+	"windows1252": "WIN1252",
+	"windows1251": "WIN1251",
+	"shiftjis":    "SJIS",
+	"koi8":        "KOI8R",
+}
+
+//encodingKey normalizes an encoding name the way the server
+//does, dropping punctuation and ignoring case
+func encodingKey(s string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+		return -1
+	}, s)
+}
+
+//ParseClientEncoding returns the canonical name of the client encoding s,
+//such as UTF8 for utf-8, or an error if the server doesn't know it
+func ParseClientEncoding(s string) (string, error) {
+	key := encodingKey(s)
+	if name, ok := clientEncodingAliases[key]; ok {
+		return name, nil
+	}
+	for _, name := range clientEncodings {
+		if encodingKey(name) == key {
+			return name, nil
+		}
+	}
+	return "", fmt.Errorf("invalid client encoding %q, must be one of %v", s, clientEncodings)
+}
+
+//validateClientEncoding checks c's ClientEncoding is known, and that
+//unless it is UTF8 queries aren't sent with the simple protocol, as pgx
+//refuses to run queries with arguments that way in other encodings
+func (c *ConfigMap) validateClientEncoding() error {
+	name, err := ParseClientEncoding(c.ClientEncoding)
+	if err != nil {
+		return err
+	}
+
+	simple := c.QueryExecMode == QueryExecModeSimpleProtocol ||
+		c.QueryExecMode == "" && c.StatementCacheMode == ""
+	if name != "UTF8" && simple {
+		return fmt.Errorf("ClientEncoding %s needs a QueryExecMode other than simple_protocol, pgx only sends queries with arguments over the simple protocol in UTF8", name)
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package config
+
+import "testing"
+
+func TestParseClientEncoding(t *testing.T) {
+	tests := []struct {
+		in, want string
+		wantErr  bool
+	}{
+		{in: "UTF8", want: "UTF8"},
+		{in: "utf-8", want: "UTF8"},
+		{in: "unicode", want: "UTF8"},
+		{in: "latin1", want: "LATIN1"},
+		{in: "ISO-8859-1", want: "LATIN1"},
+		{in: "windows-1252", want: "WIN1252"},
+		{in: "Shift_JIS", want: "SJIS"},
+		{in: "utf16", wantErr: true},
+		{in: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseClientEncoding(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseClientEncoding(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Fatalf("ParseClientEncoding(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateClientEncoding(t *testing.T) {
+	tests := []struct {
+		name     string
+		encoding string
+		exec     QueryExecMode
+		cache    StatementCacheMode
+		wantErr  bool
+	}{
+		{name: "utf8 simple", encoding: "utf8"},
+		{name: "latin1 default exec mode", encoding: "LATIN1", wantErr: true},
+		{name: "latin1 simple", encoding: "LATIN1", exec: QueryExecModeSimpleProtocol, wantErr: true},
+		{name: "latin1 cache statement", encoding: "LATIN1", exec: QueryExecModeCacheStatement},
+		{name: "latin1 describe exec", encoding: "LATIN1", exec: QueryExecModeDescribeExec},
+		{name: "latin1 statement cache", encoding: "LATIN1", cache: StatementCacheModeDescribe},
+		{name: "unknown", encoding: "EBCDIC", exec: QueryExecModeCacheStatement, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := testConfig()
+			c.ClientEncoding = tt.encoding
+			c.QueryExecMode = tt.exec
+			c.StatementCacheMode = tt.cache
+			if err := c.validate(); (err != nil) != tt.wantErr {
+				t.Fatalf("validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,30 @@
+package config
+
+import "fmt"
+
+//validateExclusive rejects c when it sets fields that exclude each other,
+//where one would otherwise silently win over the other
+func (c *ConfigMap) validateExclusive() error {
+	pairs := []struct {
+		a, b string
+		both bool
+	}{
+		{"SSLCertFile", "SSLCertPEM", c.SSLCertFile != "" && c.SSLCertPEM != ""},
+		{"SSLKeyFile", "SSLKeyPEM", c.SSLKeyFile != "" && c.SSLKeyPEM != ""},
+		{"SSLCAFile", "SSLCAPEM", c.SSLCAFile != "" && c.SSLCAPEM != ""},
+		// both replace pgx's dialer with one of their own
+		{"UseDefaultPgxDialer", "LocalAddr", c.UseDefaultPgxDialer && c.LocalAddr != ""},
+		{"UseDefaultPgxDialer", "DialNetwork", c.UseDefaultPgxDialer && c.DialNetwork != ""},
+		{"MaxConns", "MaxConnsPercent", c.MaxConns > 0 && c.MaxConnsPercent > 0},
+	}
+	for _, p := range pairs {
+		if p.both {
+			return fmt.Errorf("%s and %s are mutually exclusive, set only one", p.a, p.b)
+		}
+	}
+
+	if c.SSLInsecureSkipVerify && (c.SSLMode == SSLModeVerifyCA || c.SSLMode == SSLModeVerifyFull) {
+		return fmt.Errorf("SSLInsecureSkipVerify conflicts with sslmode %s, which verifies the server certificate", c.SSLMode)
+	}
+	return nil
+}
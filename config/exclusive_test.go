@@ -0,0 +1,50 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateExclusive(t *testing.T) {
+	tests := []struct {
+		name    string
+		set     func(c *ConfigMap)
+		wantErr string
+	}{
+		{"none", func(c *ConfigMap) {}, ""},
+		{"cert file and pem", func(c *ConfigMap) { c.SSLCertPEM = "pem" }, "SSLCertFile and SSLCertPEM"},
+		{"key file and pem", func(c *ConfigMap) { c.SSLKeyPEM = "pem" }, "SSLKeyFile and SSLKeyPEM"},
+		{"ca file and pem", func(c *ConfigMap) { c.SSLCAFile, c.SSLCAPEM = "ca.crt", "pem" }, "SSLCAFile and SSLCAPEM"},
+		{"pgx dialer and local addr", func(c *ConfigMap) { c.UseDefaultPgxDialer, c.LocalAddr = true, "10.0.0.1" }, "UseDefaultPgxDialer and LocalAddr"},
+		{"pgx dialer and network", func(c *ConfigMap) { c.UseDefaultPgxDialer, c.DialNetwork = true, "tcp4" }, "UseDefaultPgxDialer and DialNetwork"},
+		{"max conns and percent", func(c *ConfigMap) { c.MaxConns, c.MaxConnsPercent = 10, 50 }, "MaxConns and MaxConnsPercent"},
+		{"skip verify with verify-full", func(c *ConfigMap) { c.SSLInsecureSkipVerify = true }, "sslmode verify-full"},
+		{"skip verify with verify-ca", func(c *ConfigMap) { c.SSLInsecureSkipVerify, c.SSLMode = true, SSLModeVerifyCA }, "sslmode verify-ca"},
+		{"skip verify with require", func(c *ConfigMap) { c.SSLInsecureSkipVerify, c.SSLMode = true, SSLModeRequire }, ""},
+		{"pgx dialer alone", func(c *ConfigMap) { c.UseDefaultPgxDialer = true }, ""},
+		{"percent alone", func(c *ConfigMap) { c.MaxConnsPercent = 50 }, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &ConfigMap{
+				DbName:      "db",
+				DbHost:      "db.example.com",
+				DbUser:      "user",
+				Password:    "secret",
+				SSLMode:     SSLModeVerifyFull,
+				SSLCertFile: "client.crt",
+				SSLKeyFile:  "client.key",
+				ServerPort:  8080,
+				DbPort:      5432,
+			}
+			tt.set(c)
+			_, err := c.Validate()
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Fatalf("Validate() = %v, want no error", err)
+			case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+				t.Fatalf("Validate() = %v, want an error naming %s", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,78 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+)
+
+//QueryExecMode is how queries are sent to the server, named after pgx v5's exec modes.
+//v5's exec mode, sending queries with arguments without describing them first,
+//has no pgx v4 equivalent and is rejected
+type QueryExecMode string
+
+//Supported query exec modes
+const (
+	//QueryExecModeSimpleProtocol sends queries with the simple protocol, the default
+	QueryExecModeSimpleProtocol QueryExecMode = "simple_protocol"
+	//QueryExecModeCacheStatement prepares and caches a statement for each query
+	QueryExecModeCacheStatement QueryExecMode = "cache_statement"
+	//QueryExecModeCacheDescribe caches the description of each query, but not a prepared statement
+	QueryExecModeCacheDescribe QueryExecMode = "cache_describe"
+	//QueryExecModeDescribeExec describes each query before executing it, without caching
+	QueryExecModeDescribeExec QueryExecMode = "describe_exec"
+)
+
+var queryExecModes = []QueryExecMode{
+	QueryExecModeSimpleProtocol,
+	QueryExecModeCacheStatement,
+	QueryExecModeCacheDescribe,
+	QueryExecModeDescribeExec,
+}
+
+//ParseQueryExecMode returns the QueryExecMode named by s or an error if s isn't a known mode
+func ParseQueryExecMode(s string) (QueryExecMode, error) {
+	if s == "exec" {
+		return "", errors.New("query exec mode exec isn't supported, pgx v4 describes every query with arguments before executing it, use describe_exec")
+	}
+	for _, mode := range queryExecModes {
+		if string(mode) == s {
+			return mode, nil
+		}
+	}
+	return "", fmt.Errorf("invalid query exec mode %q, must be one of %v", s, queryExecModes)
+}
+
+//StatementCacheMode is what a statement cache keeps for each query, as in pgx's statement_cache_mode
+type StatementCacheMode string
+
+//Supported statement cache modes
+const (
+	//StatementCacheModePrepare keeps a prepared statement, like QueryExecModeCacheStatement
+	StatementCacheModePrepare StatementCacheMode = "prepare"
+	//StatementCacheModeDescribe keeps the statement description, like QueryExecModeCacheDescribe
+	StatementCacheModeDescribe StatementCacheMode = "describe"
+)
+
+//validateStatementCache checks the statement cache settings of c agree with its QueryExecMode
+func (c *ConfigMap) validateStatementCache() error {
+	if c.StatementCacheCapacity < 0 {
+		return fmt.Errorf("StatementCacheCapacity must not be negative, got %d", c.StatementCacheCapacity)
+	}
+
+	var implied QueryExecMode
+	switch c.StatementCacheMode {
+	case "":
+		return nil
+	case StatementCacheModePrepare:
+		implied = QueryExecModeCacheStatement
+	case StatementCacheModeDescribe:
+		implied = QueryExecModeCacheDescribe
+	default:
+		return fmt.Errorf("invalid StatementCacheMode %q, must be prepare or describe", c.StatementCacheMode)
+	}
+
+	if c.QueryExecMode != "" && c.QueryExecMode != implied {
+		return fmt.Errorf("StatementCacheMode %s conflicts with QueryExecMode %s", c.StatementCacheMode, c.QueryExecMode)
+	}
+	return nil
+}
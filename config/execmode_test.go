@@ -0,0 +1,62 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseQueryExecMode(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    QueryExecMode
+		wantErr string
+	}{
+		{in: "simple_protocol", want: QueryExecModeSimpleProtocol},
+		{in: "cache_statement", want: QueryExecModeCacheStatement},
+		{in: "cache_describe", want: QueryExecModeCacheDescribe},
+		{in: "describe_exec", want: QueryExecModeDescribeExec},
+		{in: "exec", wantErr: "pgx v4"},
+		{in: "prepared", wantErr: "invalid query exec mode"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseQueryExecMode(tt.in)
+			if got != tt.want {
+				t.Fatalf("ParseQueryExecMode(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("ParseQueryExecMode(%q) error = %v, want one with %q", tt.in, err, tt.wantErr)
+			}
+			if tt.wantErr == "" && err != nil {
+				t.Fatalf("ParseQueryExecMode(%q) error = %v", tt.in, err)
+			}
+		})
+	}
+}
+
+func TestValidateStatementCache(t *testing.T) {
+	tests := []struct {
+		name     string
+		exec     QueryExecMode
+		cache    StatementCacheMode
+		capacity int
+		wantErr  bool
+	}{
+		{name: "unset"},
+		{name: "prepare", cache: StatementCacheModePrepare, capacity: 100},
+		{name: "prepare agreeing", exec: QueryExecModeCacheStatement, cache: StatementCacheModePrepare},
+		{name: "describe agreeing", exec: QueryExecModeCacheDescribe, cache: StatementCacheModeDescribe},
+		{name: "conflicting", exec: QueryExecModeSimpleProtocol, cache: StatementCacheModePrepare, wantErr: true},
+		{name: "unknown", cache: "everything", wantErr: true},
+		{name: "negative capacity", capacity: -1, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := testConfig()
+			c.QueryExecMode, c.StatementCacheMode, c.StatementCacheCapacity = tt.exec, tt.cache, tt.capacity
+			if err := c.validateStatementCache(); (err != nil) != tt.wantErr {
+				t.Fatalf("validateStatementCache() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
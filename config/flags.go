@@ -0,0 +1,140 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+//FromFlags registers a flag on fs for every ConfigMap field, named after
+//it in kebab case such as -db-host and -ssl-mode, parses args with fs and
+//returns the validated ConfigMap. List and map flags may be repeated, each
+//adding to the field: a list flag takes comma separated items, and a map
+//flag a single key=value pair split at its first =, so values such as
+//search_path=a,b and statements with commas are kept whole
+func FromFlags(fs *flag.FlagSet, args []string) (*ConfigMap, error) {
+	config := &ConfigMap{}
+
+	v := reflect.ValueOf(config).Elem()
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		fs.Var(&fieldFlag{v: v.Field(i)}, flagName(field.Name), "sets "+field.Name)
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	if err := config.validate(); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+//flagName returns the kebab case flag name of the field name, with
+//the SSL prefix, the PEM suffix and runs of capitals kept as one word:
+//SSLCAFile becomes ssl-ca-file, SSLCAPEM ssl-ca-pem and MaxConnsPercent
+//max-conns-percent
+func flagName(name string) string {
+	var words []string
+	if strings.HasPrefix(name, "SSL") && len(name) > 3 {
+		words = append(words, "ssl")
+		name = name[3:]
+	}
+
+	var suffix []string
+	if strings.HasSuffix(name, "PEM") && len(name) > 3 {
+		suffix = []string{"pem"}
+		name = name[:len(name)-3]
+	}
+
+	runes := []rune(name)
+	start := 0
+	for i := 1; i < len(runes); i++ {
+		lowerToUpper := unicode.IsLower(runes[i-1]) && unicode.IsUpper(runes[i])
+		acronymEnd := unicode.IsUpper(runes[i-1]) && unicode.IsUpper(runes[i]) && i+1 < len(runes) && unicode.IsLower(runes[i+1])
+		if lowerToUpper || acronymEnd {
+			words = append(words, strings.ToLower(string(runes[start:i])))
+			start = i
+		}
+	}
+	words = append(words, strings.ToLower(string(runes[start:])))
+	return strings.Join(append(words, suffix...), "-")
+}
+
+//fieldFlag is a flag.Value setting a ConfigMap field
+type fieldFlag struct {
+	v reflect.Value
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+func (f *fieldFlag) String() string {
+	if !f.v.IsValid() {
+		return ""
+	}
+	return fmt.Sprint(f.v.Interface())
+}
+
+func (f *fieldFlag) IsBoolFlag() bool {
+	return f.v.IsValid() && f.v.Kind() == reflect.Bool
+}
+
+func (f *fieldFlag) Set(s string) error {
+	if f.v.Type() == durationType {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		f.v.SetInt(int64(d))
+		return nil
+	}
+
+	switch f.v.Kind() {
+	case reflect.String:
+		f.v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		f.v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, f.v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		f.v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, f.v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		f.v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(s, f.v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		f.v.SetFloat(n)
+	case reflect.Slice:
+		f.v.Set(reflect.AppendSlice(f.v, reflect.ValueOf(strings.Split(s, ","))))
+	case reflect.Map:
+		kv := strings.SplitN(s, "=", 2)
+		if len(kv) != 2 {
+			return fmt.Errorf("%q isn't a key=value pair", s)
+		}
+		if f.v.IsNil() {
+			f.v.Set(reflect.ValueOf(make(map[string]string)))
+		}
+		f.v.SetMapIndex(reflect.ValueOf(kv[0]), reflect.ValueOf(kv[1]))
+	default:
+		return fmt.Errorf("unsupported field type %s", f.v.Type())
+	}
+	return nil
+}
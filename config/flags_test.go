@@ -0,0 +1,94 @@
+package config
+
+import (
+	"flag"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFlagName(t *testing.T) {
+	tests := []struct {
+		field, want string
+	}{
+		{"DbHost", "db-host"},
+		{"Password", "password"},
+		{"SSLMode", "ssl-mode"},
+		{"SSLCAFile", "ssl-ca-file"},
+		{"SSLCRLFile", "ssl-crl-file"},
+		{"SSLCAPEM", "ssl-ca-pem"},
+		{"SSLCertPEM", "ssl-cert-pem"},
+		{"SSLPinnedSPKI", "ssl-pinned-spki"},
+		{"SSLRequireServerAuthEKU", "ssl-require-server-auth-eku"},
+		{"DSNScheme", "dsn-scheme"},
+		{"MaxConnsPercent", "max-conns-percent"},
+		{"RawDSNValues", "raw-dsn-values"},
+	}
+	for _, tt := range tests {
+		if got := flagName(tt.field); got != tt.want {
+			t.Errorf("flagName(%q) = %q, want %q", tt.field, got, tt.want)
+		}
+	}
+}
+
+func TestFlagNamesUnique(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	// registering the same name twice panics
+	if _, err := FromFlags(fs, []string{"-h"}); err != flag.ErrHelp {
+		t.Fatalf("FromFlags(-h) = %v, want flag.ErrHelp", err)
+	}
+}
+
+func TestFromFlags(t *testing.T) {
+	required := []string{
+		"-db-name", "db", "-db-host", "db.example.com", "-db-user", "user", "-password", "secret",
+		"-ssl-mode", "verify-full", "-ssl-cert-file", "client.crt", "-ssl-key-file", "client.key",
+		"-server-port", "8080", "-db-port", "5432",
+	}
+
+	tests := []struct {
+		name    string
+		args    []string
+		check   func(c *ConfigMap) bool
+		wantErr string
+	}{
+		{name: "required", check: func(c *ConfigMap) bool { return c.DbHost == "db.example.com" && c.DbPort == 5432 }},
+		{name: "bool", args: []string{"-ssl-debug"}, check: func(c *ConfigMap) bool { return c.SSLDebug }},
+		{name: "duration", args: []string{"-max-conn-lifetime", "90m"}, check: func(c *ConfigMap) bool { return c.MaxConnLifetime == 90*time.Minute }},
+		{name: "float", args: []string{"-max-conns-percent", "12.5"}, check: func(c *ConfigMap) bool { return c.MaxConnsPercent == 12.5 }},
+		{name: "list", args: []string{"-ssl-pinned-spki", "a,b"}, check: func(c *ConfigMap) bool { return len(c.SSLPinnedSPKI) == 2 && c.SSLPinnedSPKI[1] == "b" }},
+		{name: "repeated list", args: []string{"-ssl-pinned-spki", "a,b", "-ssl-pinned-spki", "c"}, check: func(c *ConfigMap) bool { return strings.Join(c.SSLPinnedSPKI, " ") == "a b c" }},
+		{name: "map", args: []string{"-options", "work_mem=64MB", "-options", "search_path=app"}, check: func(c *ConfigMap) bool {
+			return len(c.Options) == 2 && c.Options["work_mem"] == "64MB" && c.Options["search_path"] == "app"
+		}},
+		{name: "map value with commas", args: []string{"-options", "search_path=a,b"}, check: func(c *ConfigMap) bool { return len(c.Options) == 1 && c.Options["search_path"] == "a,b" }},
+		{name: "map value with equals", args: []string{"-prepare-statements", "active=SELECT id FROM users WHERE state IN (1,2) AND kind = $1"}, check: func(c *ConfigMap) bool {
+			return c.PrepareStatements["active"] == "SELECT id FROM users WHERE state IN (1,2) AND kind = $1"
+		}},
+		{name: "out of range", args: []string{"-max-conns", "300"}, wantErr: "max-conns"},
+		{name: "bad pair", args: []string{"-options", "work_mem"}, wantErr: "isn't a key=value pair"},
+		{name: "invalid config", args: []string{"-db-port", "0"}, wantErr: "DbPort"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fs := flag.NewFlagSet("test", flag.ContinueOnError)
+			fs.SetOutput(ioutil.Discard)
+
+			c, err := FromFlags(fs, append(append([]string{}, required...), tt.args...))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("FromFlags() error = %v, want it to mention %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !tt.check(c) {
+				t.Fatalf("parsed %+v", c)
+			}
+		})
+	}
+}
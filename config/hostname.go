@@ -0,0 +1,57 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+//validateHostname checks h is an IP address or a DNS name the server
+//certificate can be verified against, which may start with a wildcard
+//label as in *.db.example.com
+func validateHostname(h string) error {
+	if net.ParseIP(h) != nil {
+		return nil
+	}
+
+	labels := strings.Split(strings.TrimSuffix(h, "."), ".")
+	for i, label := range labels {
+		if label == "*" && i == 0 && len(labels) > 2 {
+			continue
+		}
+		if !validLabel(label) {
+			return fmt.Errorf("invalid SSLHostname %q, must be a hostname such as db.example.com without scheme or port", h)
+		}
+	}
+	return nil
+}
+
+//validLabel reports whether label is a valid DNS label
+func validLabel(label string) bool {
+	if len(label) == 0 || len(label) > 63 || label[0] == '-' || label[len(label)-1] == '-' {
+		return false
+	}
+	for _, r := range label {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+//validateLocalAddr checks addr is an IP address, optionally with a port
+func validateLocalAddr(addr string) error {
+	if net.ParseIP(addr) != nil {
+		return nil
+	}
+	host, port, err := net.SplitHostPort(addr)
+	if err == nil && net.ParseIP(host) != nil {
+		if _, err = strconv.ParseUint(port, 10, 16); err == nil {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid LocalAddr %q, must be an IP address optionally with a port", addr)
+}
@@ -0,0 +1,74 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateHostname(t *testing.T) {
+	tests := []struct {
+		host    string
+		wantErr bool
+	}{
+		{host: "db.example.com"},
+		{host: "db.example.com."},
+		{host: "db"},
+		{host: "db-1.internal_zone.example.com"},
+		{host: "*.db.example.com"},
+		{host: "10.0.0.5"},
+		{host: "::1"},
+		{host: "*.com", wantErr: true},
+		{host: "db.*.example.com", wantErr: true},
+		{host: "db.example.com:5432", wantErr: true},
+		{host: "postgres://db.example.com", wantErr: true},
+		{host: "-db.example.com", wantErr: true},
+		{host: "db..example.com", wantErr: true},
+		{host: strings.Repeat("a", 64) + ".example.com", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.host, func(t *testing.T) {
+			if err := validateHostname(tt.host); (err != nil) != tt.wantErr {
+				t.Fatalf("validateHostname(%q) error = %v, wantErr %v", tt.host, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateSSLHostname(t *testing.T) {
+	c := testConfig()
+	c.SSLHostname = "db.example.com:5432"
+	if _, err := c.Validate(); err == nil || !strings.Contains(err.Error(), "invalid SSLHostname") {
+		t.Fatalf("Validate() error = %v, want the invalid SSLHostname", err)
+	}
+}
+
+func TestValidateLocalAddr(t *testing.T) {
+	tests := []struct {
+		addr    string
+		wantErr bool
+	}{
+		{addr: "10.0.0.5"},
+		{addr: "10.0.0.5:6000"},
+		{addr: "::1"},
+		{addr: "[::1]:6000"},
+		{addr: "10.0.0.5:0"},
+		{addr: "app.example.com", wantErr: true},
+		{addr: "app.example.com:6000", wantErr: true},
+		{addr: "10.0.0.5:65536", wantErr: true},
+		{addr: "10.0.0.5:http", wantErr: true},
+		{addr: "10.0.0.256", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.addr, func(t *testing.T) {
+			err := validateLocalAddr(tt.addr)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("validateLocalAddr(%q) error = %v, wantErr %v", tt.addr, err, tt.wantErr)
+			}
+			c := testConfig()
+			c.LocalAddr = tt.addr
+			if err := c.validate(); (err != nil) != tt.wantErr {
+				t.Fatalf("validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,58 @@
+package config
+
+import (
+	"bytes"
+	"io/ioutil"
+)
+
+//FromJSONCFile is like FromFile but accepts // and /* */ comments in file
+func FromJSONCFile(file string, opts ...LoadOption) (*ConfigMap, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	config, err := decode(bytes.NewReader(stripComments(data)))
+	if err != nil {
+		return nil, err
+	}
+	return config.load(newLoadOptions(opts))
+}
+
+//stripComments replaces the comments outside string literals in data with
+//spaces, keeping newlines so decode errors still point at the right line
+func stripComments(data []byte) []byte {
+	out := make([]byte, len(data))
+	copy(out, data)
+
+	inString := false
+	for i := 0; i < len(out); i++ {
+		switch {
+		case inString:
+			if out[i] == '\\' {
+				i++
+			} else if out[i] == '"' {
+				inString = false
+			}
+		case out[i] == '"':
+			inString = true
+		case out[i] == '/' && i+1 < len(out) && out[i+1] == '/':
+			for ; i < len(out) && out[i] != '\n'; i++ {
+				out[i] = ' '
+			}
+		case out[i] == '/' && i+1 < len(out) && out[i+1] == '*':
+			out[i], out[i+1] = ' ', ' '
+			for i += 2; i < len(out); i++ {
+				if out[i] == '*' && i+1 < len(out) && out[i+1] == '/' {
+					out[i], out[i+1] = ' ', ' '
+					i++
+					break
+				}
+				if out[i] != '\n' {
+					out[i] = ' '
+				}
+			}
+		}
+	}
+	return out
+}
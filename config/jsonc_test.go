@@ -0,0 +1,107 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestStripComments(t *testing.T) {
+	tests := []struct {
+		name, in, want string
+	}{
+		{"none", `{"a": 1}`, `{"a": 1}`},
+		{"line", "{\"a\": 1} // one\n", "{\"a\": 1}       \n"},
+		{"block", `{/* one */"a": 1}`, `{         "a": 1}`},
+		{"multiline block", "{/* one\ntwo */\"a\": 1}", "{      \n      \"a\": 1}"},
+		{"in string", `{"url": "http://example.com/*x*/"}`, `{"url": "http://example.com/*x*/"}`},
+		{"escaped quote", `{"a": "\" // x"}`, `{"a": "\" // x"}`},
+		{"unterminated block", `{"a": 1} /* x`, `{"a": 1}     `},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := string(stripComments([]byte(tt.in))); got != tt.want {
+				t.Fatalf("stripComments(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFromJSONCFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		wantErr string
+	}{
+		{
+			name: "valid",
+			data: `{
+				// connection
+				"DbName": "db", "DbHost": "db.example.com", "DbUser": "user",
+				"Password": "secret", /* rotated monthly */
+				"SSLMode": "verify-full", "SSLCertFile": "client.crt", "SSLKeyFile": "client.key",
+				"ServerPort": 8080, "DbPort": 5432
+			}`,
+		},
+		{name: "syntax error", data: "{\n// x\n\"DbName\": }", wantErr: "can't parse config file"},
+		{name: "invalid config", data: `{"DbName": "db" /* no port */}`, wantErr: "DbPort"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := filepath.Join(t.TempDir(), "config.jsonc")
+			if err := os.WriteFile(file, []byte(tt.data), 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			c, err := FromJSONCFile(file)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("FromJSONCFile() error = %v, want it to mention %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if c.Password != "secret" || c.DbPort != 5432 {
+				t.Fatalf("decoded %+v", c)
+			}
+		})
+	}
+}
+
+func TestFromJSONCFileDecodesLikeFromFile(t *testing.T) {
+	const valid = `{"DbName": "db", "DbHost": "db.example.com", "DbUser": "user", "Password": "secret",
+		"SSLMode": "verify-full", "SSLCertFile": "client.crt", "SSLKeyFile": "client.key",
+		"ServerPort": 8080, "DbPort": 5432}`
+
+	tests := []struct {
+		name, data string
+	}{
+		{"valid", valid},
+		{"trailing value", valid + "\n{}"},
+		{"syntax error", `{"DbName": }`},
+		{"wrong type", `{"DbPort": "5432"}`},
+		{"invalid config", `{"DbName": "db"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := filepath.Join(t.TempDir(), "config.json")
+			if err := os.WriteFile(file, []byte(tt.data), 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			want, wantErr := FromFile(file)
+			got, err := FromJSONCFile(file)
+			if fmt.Sprint(err) != fmt.Sprint(wantErr) {
+				t.Fatalf("FromJSONCFile() error = %v, FromFile() error = %v", err, wantErr)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Fatalf("FromJSONCFile() = %+v, FromFile() = %+v", got, want)
+			}
+		})
+	}
+}
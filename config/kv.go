@@ -0,0 +1,56 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+)
+
+//KVSource reads a JSON config blob, as FromFile expects in a file,
+//from a key of a key-value store such as Consul or etcd. Get fetches
+//the value of key, e.g. with the Consul api
+//
+//	Get: func(key string) ([]byte, error) {
+//		pair, _, err := client.KV().Get(key, nil)
+//		if err != nil || pair == nil {
+//			return nil, err
+//		}
+//		return pair.Value, nil
+//	}
+//
+//or with etcd's clientv3
+//
+//	Get: func(key string) ([]byte, error) {
+//		resp, err := client.Get(ctx, key)
+//		if err != nil || len(resp.Kvs) == 0 {
+//			return nil, err
+//		}
+//		return resp.Kvs[0].Value, nil
+//	}
+type KVSource struct {
+	Key string
+	Get func(key string) ([]byte, error)
+}
+
+func (s KVSource) Name() string { return "kv " + s.Key }
+
+func (s KVSource) Fields() (map[string]json.RawMessage, error) {
+	data, err := s.Get(s.Key)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return nil, errors.New("key " + s.Key + " is empty or missing")
+	}
+
+	fields := map[string]json.RawMessage{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	return fields, nil
+}
+
+//FromSource returns a New ConfigMap with values from src
+func FromSource(src ConfigSource) (*ConfigMap, error) {
+	config, _, err := LoadWithProvenance(src)
+	return config, err
+}
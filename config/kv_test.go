@@ -0,0 +1,63 @@
+package config
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+//kvStore is an in-memory key-value store
+type kvStore map[string]string
+
+func (s kvStore) get(key string) ([]byte, error) {
+	if key == "down" {
+		return nil, errors.New("connection refused")
+	}
+	v, ok := s[key]
+	if !ok {
+		return nil, nil
+	}
+	return []byte(v), nil
+}
+
+func TestKVSource(t *testing.T) {
+	store := kvStore{
+		"pgxtls/config": `{
+			"DbName": "db", "DbHost": "db.example.com", "DbUser": "user", "Password": "secret",
+			"SSLMode": "verify-full", "SSLCertFile": "client.crt", "SSLKeyFile": "client.key",
+			"ServerPort": 8080, "DbPort": 5432, "maxconnections": 5
+		}`,
+		"pgxtls/empty":   "",
+		"pgxtls/invalid": `{"DbName": `,
+		"pgxtls/partial": `{"DbName": "db"}`,
+	}
+
+	tests := []struct {
+		key     string
+		wantErr string
+	}{
+		{key: "pgxtls/config"},
+		{key: "pgxtls/missing", wantErr: "key pgxtls/missing is empty or missing"},
+		{key: "pgxtls/empty", wantErr: "key pgxtls/empty is empty or missing"},
+		{key: "pgxtls/invalid", wantErr: "can't load config from kv pgxtls/invalid"},
+		{key: "pgxtls/partial", wantErr: "DbPort"},
+		{key: "down", wantErr: "connection refused"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			c, err := FromSource(KVSource{Key: tt.key, Get: store.get})
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("FromSource() error = %v, want it to mention %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if c.DbHost != "db.example.com" || c.MaxConns != 5 {
+				t.Fatalf("loaded %+v", c)
+			}
+		})
+	}
+}
@@ -0,0 +1,24 @@
+package config
+
+import "encoding/json"
+
+//redacted replaces secrets when a ConfigMap is marshaled
+const redacted = "xxxxx"
+
+//MarshalJSON encodes c with its password, passphrases and key masked,
+//so configs can be logged or served safely. Use MarshalJSONUnredacted
+//for JSON that is loaded again
+func (c ConfigMap) MarshalJSON() ([]byte, error) {
+	for _, secret := range []*string{&c.Password, &c.SSLKeyFilePassPhrase, &c.SSLCAFilePassPhrase, &c.SSLKeyPEM} {
+		if *secret != "" {
+			*secret = redacted
+		}
+	}
+	return c.MarshalJSONUnredacted()
+}
+
+//MarshalJSONUnredacted encodes c including its secrets
+func (c ConfigMap) MarshalJSONUnredacted() ([]byte, error) {
+	type plain ConfigMap
+	return json.Marshal(plain(c))
+}
@@ -0,0 +1,36 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+)
+
+//FromFileProfile returns a New ConfigMap with values parsed from the
+//profile named profile in file. file holds a JSON object mapping
+//profile names to configs, e.g. {"dev": {...}, "prod": {...}}
+func FromFileProfile(file, profile string, opts ...LoadOption) (*ConfigMap, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	profiles := map[string]json.RawMessage{}
+	if err := json.NewDecoder(f).Decode(&profiles); err != nil {
+		return nil, errors.New("can't parse config file: " + err.Error())
+	}
+
+	raw, ok := profiles[profile]
+	if !ok {
+		return nil, fmt.Errorf("profile %q not found in %s", profile, file)
+	}
+
+	config := &ConfigMap{}
+	if err := json.Unmarshal(raw, config); err != nil {
+		return nil, fmt.Errorf("can't parse profile %q: %v", profile, err)
+	}
+
+	return config.load(newLoadOptions(opts))
+}
@@ -0,0 +1,64 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFromFileProfile(t *testing.T) {
+	data := `{
+		"dev": {"DbName": "dev", "DbHost": "localhost", "DbUser": "user", "Password": "secret",
+			"SSLMode": "require", "SSLCertFile": "client.crt", "SSLKeyFile": "client.key",
+			"ServerPort": 8080, "DbPort": 5432},
+		"prod": {"DbName": "prod", "DbHost": "db.example.com", "DbUser": "user", "Password": "secret",
+			"SSLMode": "verify-full", "sslcert": "client.crt", "SSLKeyFile": "client.key",
+			"ServerPort": 8080, "DbPort": 5432},
+		"broken": {"DbName": 5},
+		"incomplete": {"DbName": "db"}
+	}`
+	file := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(file, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		profile  string
+		wantHost string
+		wantErr  string
+	}{
+		{profile: "dev", wantHost: "localhost"},
+		{profile: "prod", wantHost: "db.example.com"},
+		{profile: "staging", wantErr: `profile "staging" not found`},
+		{profile: "broken", wantErr: `can't parse profile "broken"`},
+		{profile: "incomplete", wantErr: "DbPort"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.profile, func(t *testing.T) {
+			c, err := FromFileProfile(file, tt.profile)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("FromFileProfile() error = %v, want it to mention %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if c.DbHost != tt.wantHost || c.SSLCertFile != "client.crt" {
+				t.Fatalf("loaded %+v", c)
+			}
+		})
+	}
+}
+
+func TestFromFileProfileInvalidFile(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(file, []byte(`["dev"]`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := FromFileProfile(file, "dev"); err == nil || !strings.Contains(err.Error(), "can't parse config file") {
+		t.Fatalf("got %v, want the parse error", err)
+	}
+}
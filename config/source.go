@@ -0,0 +1,171 @@
+package config
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strings"
+)
+
+//ConfigSource supplies some or all of the fields of a ConfigMap
+type ConfigSource interface {
+	//Name identifies the source in a Provenance
+	Name() string
+	//Fields returns the JSON encoded value of each field the source sets,
+	//keyed by ConfigMap field name
+	Fields() (map[string]json.RawMessage, error)
+}
+
+//Provenance records the name of the source each field's value came from
+type Provenance map[string]string
+
+//LoadWithProvenance returns a New ConfigMap merged from sources, where
+//each source overrides the fields set by the sources before it, along
+//with which source supplied every field that was set
+func LoadWithProvenance(sources ...ConfigSource) (*ConfigMap, Provenance, error) {
+	config := &ConfigMap{}
+	prov := Provenance{}
+	v := reflect.ValueOf(config).Elem()
+
+	for _, src := range sources {
+		fields, err := src.Fields()
+		if err != nil {
+			return nil, nil, fmt.Errorf("can't load config from %s: %v", src.Name(), err)
+		}
+
+		for name, raw := range fields {
+			name := canonicalKey(name)
+			f, ok := v.Type().FieldByNameFunc(func(field string) bool {
+				return strings.EqualFold(field, name)
+			})
+			if !ok {
+				continue
+			}
+
+			if err := json.Unmarshal(raw, v.FieldByIndex(f.Index).Addr().Interface()); err != nil {
+				return nil, nil, fmt.Errorf("can't parse %s from %s: %v", f.Name, src.Name(), err)
+			}
+			prov[f.Name] = src.Name()
+		}
+	}
+
+	if err := config.validate(); err != nil {
+		return nil, nil, err
+	}
+	return config, prov, nil
+}
+
+//FileSource reads fields from a JSON config file as FromFile does
+type FileSource string
+
+func (f FileSource) Name() string { return "file " + string(f) }
+
+func (f FileSource) Fields() (map[string]json.RawMessage, error) {
+	data, err := ioutil.ReadFile(string(f))
+	if err != nil {
+		return nil, err
+	}
+
+	fields := map[string]json.RawMessage{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	return fields, nil
+}
+
+//envVars names the environment variable FromEnv reads each field from
+var envVars = map[string]string{
+	"DbName":     "DB_NAME",
+	"DbHost":     "DB_HOST",
+	"DbUser":     "DB_USER",
+	"Password":   "DB_PASSWORD",
+	"SSLMode":    "SSL_MODE",
+	"ServerPort": "SERVER_PORT",
+	"DbPort":     "DB_PORT",
+	"MaxConns":   "MAX_CONNS",
+}
+
+//envB64Vars names the environment variables holding base64 encoded
+//PEM that FromEnv decodes into each field
+var envB64Vars = map[string]string{
+	"SSLCertPEM": "SSL_CERT_B64",
+	"SSLKeyPEM":  "SSL_KEY_B64",
+	"SSLCAPEM":   "SSL_CA_B64",
+}
+
+//lookupEnvB64 returns the decoded value of the base64 environment variable env
+func lookupEnvB64(env string) (string, bool, error) {
+	value, ok := os.LookupEnv(env)
+	if !ok {
+		return "", false, nil
+	}
+	decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(value))
+	if err != nil {
+		return "", false, fmt.Errorf("%s isn't valid base64: %v", env, err)
+	}
+	return string(decoded), true, nil
+}
+
+//EnvSource reads fields from the environment variables FromEnv uses,
+//only the variables that are set are used
+type EnvSource struct{}
+
+func (EnvSource) Name() string { return "env" }
+
+func (EnvSource) Fields() (map[string]json.RawMessage, error) {
+	t := reflect.TypeOf(ConfigMap{})
+	fields := map[string]json.RawMessage{}
+
+	for name, env := range envVars {
+		value, ok := os.LookupEnv(env)
+		if !ok {
+			continue
+		}
+
+		f, _ := t.FieldByName(name)
+		if f.Type.Kind() == reflect.String {
+			fields[name], _ = json.Marshal(value)
+		} else {
+			fields[name] = json.RawMessage(value)
+		}
+	}
+
+	for name, env := range envB64Vars {
+		pem, ok, err := lookupEnvB64(env)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			fields[name], _ = json.Marshal(pem)
+		}
+	}
+	return fields, nil
+}
+
+//DefaultSource supplies the non zero fields of a ConfigMap of defaults
+type DefaultSource struct {
+	Defaults *ConfigMap
+}
+
+func (DefaultSource) Name() string { return "defaults" }
+
+func (d DefaultSource) Fields() (map[string]json.RawMessage, error) {
+	v := reflect.ValueOf(d.Defaults).Elem()
+	fields := map[string]json.RawMessage{}
+
+	for i := 0; i < v.NumField(); i++ {
+		if v.Field(i).IsZero() {
+			continue
+		}
+
+		raw, err := json.Marshal(v.Field(i).Interface())
+		if err != nil {
+			return nil, err
+		}
+		fields[v.Type().Field(i).Name] = raw
+	}
+	return fields, nil
+}
@@ -0,0 +1,221 @@
+package config
+
+import (
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadWithProvenance(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "config.json")
+	data := `{"DbName": "db", "dbhost": "file.example.com", "DbUser": "user", "Password": "from file",
+		"SSLMode": "verify-full", "sslcert": "client.crt", "SSLKeyFile": "client.key", "ServerPort": 8080}`
+	if err := os.WriteFile(file, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	unsetEnv(t)
+	t.Setenv("DB_HOST", "env.example.com")
+	t.Setenv("DB_PORT", "6432")
+
+	defaults := DefaultSource{Defaults: &ConfigMap{DbHost: "localhost", DbPort: 5432, MaxConns: 8}}
+	c, prov, err := LoadWithProvenance(defaults, FileSource(file), EnvSource{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		field, source string
+		got, want     interface{}
+	}{
+		{"DbHost", "env", c.DbHost, "env.example.com"},
+		{"DbPort", "env", c.DbPort, uint16(6432)},
+		{"Password", "file " + file, c.Password, "from file"},
+		{"SSLCertFile", "file " + file, c.SSLCertFile, "client.crt"},
+		{"MaxConns", "defaults", c.MaxConns, uint8(8)},
+		{"SSLCAFile", "", c.SSLCAFile, ""},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s = %v, want %v", tt.field, tt.got, tt.want)
+		}
+		if prov[tt.field] != tt.source {
+			t.Errorf("%s came from %q, want %q", tt.field, prov[tt.field], tt.source)
+		}
+	}
+}
+
+func TestLoadWithProvenanceErrors(t *testing.T) {
+	dir := t.TempDir()
+	broken := filepath.Join(dir, "broken.json")
+	if err := os.WriteFile(broken, []byte(`{"DbPort": "5432"}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		sources []ConfigSource
+		wantErr string
+	}{
+		{"missing file", []ConfigSource{FileSource(filepath.Join(dir, "missing.json"))}, "can't load config from file"},
+		{"wrong type", []ConfigSource{FileSource(broken)}, "can't parse DbPort from file"},
+		{"invalid", []ConfigSource{DefaultSource{Defaults: &ConfigMap{DbName: "db"}}}, "DbPort"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := LoadWithProvenance(tt.sources...); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("LoadWithProvenance() error = %v, want it to mention %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestDefaultSourceSkipsZeroFields(t *testing.T) {
+	fields, err := DefaultSource{Defaults: &ConfigMap{DbPort: 5432, Options: map[string]string{"work_mem": "64MB"}}}.Fields()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fields) != 2 || string(fields["DbPort"]) != "5432" || string(fields["Options"]) != `{"work_mem":"64MB"}` {
+		t.Fatalf("Fields() = %s", fields)
+	}
+}
+
+func TestLookupEnvB64(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   *string
+		want    string
+		wantOK  bool
+		wantErr bool
+	}{
+		{name: "unset"},
+		{name: "encoded", value: strp(base64.StdEncoding.EncodeToString([]byte("-----BEGIN CERTIFICATE-----\n"))), want: "-----BEGIN CERTIFICATE-----\n", wantOK: true},
+		{name: "trailing newline", value: strp(base64.StdEncoding.EncodeToString([]byte("pem")) + "\n"), want: "pem", wantOK: true},
+		{name: "empty", value: strp(""), wantOK: true},
+		{name: "invalid", value: strp("not base64!"), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			unsetEnv(t)
+			if tt.value != nil {
+				t.Setenv("SSL_CERT_B64", *tt.value)
+			}
+			got, ok, err := lookupEnvB64("SSL_CERT_B64")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("lookupEnvB64() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr && !strings.Contains(err.Error(), "SSL_CERT_B64") {
+				t.Fatalf("lookupEnvB64() error = %v, want it to name the variable", err)
+			}
+			if got != tt.want || ok != tt.wantOK {
+				t.Fatalf("lookupEnvB64() = %q, %v, want %q, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestFromEnvB64(t *testing.T) {
+	b64 := func(s string) string { return base64.StdEncoding.EncodeToString([]byte(s)) }
+	setEnv := func(t *testing.T) {
+		unsetEnv(t)
+		for env, value := range map[string]string{
+			"DB_NAME": "db", "DB_HOST": "db.example.com", "DB_USER": "user", "DB_PASSWORD": "secret",
+			"SSL_MODE": "verify-full", "SERVER_PORT": "8080", "DB_PORT": "5432",
+			"SSL_CERT_B64": b64("cert"), "SSL_KEY_B64": b64("key"), "SSL_CA_B64": b64("ca"),
+		} {
+			t.Setenv(env, value)
+		}
+	}
+
+	t.Run("FromEnv", func(t *testing.T) {
+		setEnv(t)
+		c, err := FromEnv()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c.SSLCertPEM != "cert" || c.SSLKeyPEM != "key" || c.SSLCAPEM != "ca" {
+			t.Fatalf("FromEnv() = cert %q key %q ca %q", c.SSLCertPEM, c.SSLKeyPEM, c.SSLCAPEM)
+		}
+	})
+	t.Run("EnvSource", func(t *testing.T) {
+		setEnv(t)
+		os.Unsetenv("SSL_CA_B64")
+		fields, err := EnvSource{}.Fields()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(fields["SSLCertPEM"]) != `"cert"` || string(fields["SSLKeyPEM"]) != `"key"` {
+			t.Fatalf("Fields() = %s", fields)
+		}
+		if _, ok := fields["SSLCAPEM"]; ok {
+			t.Fatal("Fields() set SSLCAPEM from an unset variable")
+		}
+	})
+
+	tests := []struct {
+		name, env string
+		load      func() error
+	}{
+		{"FromEnv invalid cert", "SSL_CERT_B64", func() error { _, err := FromEnv(); return err }},
+		{"EnvSource invalid key", "SSL_KEY_B64", func() error { _, err := EnvSource{}.Fields(); return err }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setEnv(t)
+			t.Setenv(tt.env, "%%%")
+			if err := tt.load(); err == nil || !strings.Contains(err.Error(), tt.env) {
+				t.Fatalf("got %v, want an error naming %s", err, tt.env)
+			}
+		})
+	}
+}
+
+func strp(s string) *string { return &s }
+
+//unsetEnv unsets the variables EnvSource reads for the rest of the test
+func unsetEnv(t *testing.T) {
+	for _, vars := range []map[string]string{envVars, envB64Vars} {
+		for _, env := range vars {
+			t.Setenv(env, "")
+			os.Unsetenv(env)
+		}
+	}
+}
+
+func TestFromEnvMaxConns(t *testing.T) {
+	tests := []struct {
+		name, value string
+		want        uint8
+		wantErr     bool
+	}{
+		{name: "unset"},
+		{name: "set", value: "12", want: 12},
+		{name: "invalid", value: "many", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			unsetEnv(t)
+			for env, value := range map[string]string{
+				"DB_NAME": "db", "DB_HOST": "db.example.com", "DB_USER": "user", "DB_PASSWORD": "secret",
+				"SSL_MODE": "verify-full", "SERVER_PORT": "8080", "DB_PORT": "5432",
+				"SSL_CERT_B64": base64.StdEncoding.EncodeToString([]byte("cert")),
+				"SSL_KEY_B64":  base64.StdEncoding.EncodeToString([]byte("key")),
+			} {
+				t.Setenv(env, value)
+			}
+			if tt.value != "" {
+				t.Setenv("MAX_CONNS", tt.value)
+			}
+
+			c, err := FromEnv()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("FromEnv() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			// zero leaves the pool size to AutoMaxConns
+			if err == nil && c.MaxConns != tt.want {
+				t.Fatalf("MaxConns = %d, want %d", c.MaxConns, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,47 @@
+package config
+
+import "fmt"
+
+//SSLMode is the libpq sslmode to use when connecting to the database
+type SSLMode string
+
+//Supported sslmode values, see https://www.postgresql.org/docs/current/libpq-ssl.html
+const (
+	SSLModeDisable    SSLMode = "disable"
+	SSLModeAllow      SSLMode = "allow"
+	SSLModePrefer     SSLMode = "prefer"
+	SSLModeRequire    SSLMode = "require"
+	SSLModeVerifyCA   SSLMode = "verify-ca"
+	SSLModeVerifyFull SSLMode = "verify-full"
+)
+
+var sslModes = []SSLMode{
+	SSLModeDisable,
+	SSLModeAllow,
+	SSLModePrefer,
+	SSLModeRequire,
+	SSLModeVerifyCA,
+	SSLModeVerifyFull,
+}
+
+//ParseSSLMode returns the SSLMode named by s or an error if s isn't a known sslmode
+func ParseSSLMode(s string) (SSLMode, error) {
+	for _, mode := range sslModes {
+		if string(mode) == s {
+			return mode, nil
+		}
+	}
+	return "", fmt.Errorf("invalid sslmode %q, must be one of %v", s, sslModes)
+}
+
+//UsesTLS reports whether every connection made with c is encrypted.
+//disable never encrypts, while allow and prefer fall back to
+//plaintext connections so they don't guarantee encryption either
+func (c *ConfigMap) UsesTLS() bool {
+	switch c.SSLMode {
+	case SSLModeRequire, SSLModeVerifyCA, SSLModeVerifyFull:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,52 @@
+package config
+
+import "testing"
+
+func TestParseSSLMode(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    SSLMode
+		wantErr bool
+	}{
+		{in: "disable", want: SSLModeDisable},
+		{in: "allow", want: SSLModeAllow},
+		{in: "prefer", want: SSLModePrefer},
+		{in: "require", want: SSLModeRequire},
+		{in: "verify-ca", want: SSLModeVerifyCA},
+		{in: "verify-full", want: SSLModeVerifyFull},
+		{in: "VERIFY-FULL", wantErr: true},
+		{in: "verify_full", wantErr: true},
+		{in: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseSSLMode(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseSSLMode(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Fatalf("ParseSSLMode(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUsesTLS(t *testing.T) {
+	tests := []struct {
+		mode SSLMode
+		want bool
+	}{
+		{SSLModeDisable, false},
+		{SSLModeAllow, false},
+		{SSLModePrefer, false},
+		{SSLModeRequire, true},
+		{SSLModeVerifyCA, true},
+		{SSLModeVerifyFull, true},
+	}
+	for _, tt := range tests {
+		c := &ConfigMap{SSLMode: tt.mode}
+		if got := c.UsesTLS(); got != tt.want {
+			t.Errorf("UsesTLS() with sslmode %s = %v, want %v", tt.mode, got, tt.want)
+		}
+	}
+}
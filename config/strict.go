@@ -0,0 +1,53 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+//FromFileStrict is like FromFile but rejects keys in file that name no
+//ConfigMap field or alias, such as a misspelled DbHots, instead of
+//ignoring them. ConfigMap decodes itself to resolve aliases, which the
+//decoder's DisallowUnknownFields doesn't reach into, so keys are checked first
+func FromFileStrict(file string, opts ...LoadOption) (*ConfigMap, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	fields := map[string]json.RawMessage{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, errors.New("can't parse config file: " + err.Error())
+	}
+	if unknown := unknownKeys(fields); len(unknown) > 0 {
+		return nil, fmt.Errorf("unknown field %q in config file %s", unknown[0], file)
+	}
+
+	config, err := decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	return config.load(newLoadOptions(opts))
+}
+
+//unknownKeys returns the sorted keys of fields that encoding/json
+//wouldn't decode into a ConfigMap field
+func unknownKeys(fields map[string]json.RawMessage) []string {
+	t := reflect.TypeOf(ConfigMap{})
+
+	var unknown []string
+	for key := range fields {
+		name := canonicalKey(key)
+		if _, ok := t.FieldByNameFunc(func(field string) bool { return strings.EqualFold(field, name) }); !ok {
+			unknown = append(unknown, key)
+		}
+	}
+	sort.Strings(unknown)
+	return unknown
+}
@@ -0,0 +1,76 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestUnknownKeys(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want []string
+	}{
+		{name: "known", data: `{"DbHost": "", "dbport": 1}`},
+		{name: "alias", data: `{"sslrootcert": "", "MaxConnections": 1}`},
+		{name: "misspelled", data: `{"DbHots": "", "DbPort": 1}`, want: []string{"DbHots"}},
+		{name: "sorted", data: `{"Zone": "", "Area": "", "DbPort": 1}`, want: []string{"Area", "Zone"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := filepath.Join(t.TempDir(), "config.json")
+			if err := os.WriteFile(file, []byte(tt.data), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			fields, err := FileSource(file).Fields()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := unknownKeys(fields); fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Fatalf("unknownKeys() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFromFileStrict(t *testing.T) {
+	valid := `"DbName": "db", "DbHost": "db.example.com", "DbUser": "user", "Password": "secret",
+		"SSLMode": "verify-full", "sslcert": "client.crt", "SSLKeyFile": "client.key",
+		"ServerPort": 8080, "DbPort": 5432`
+
+	tests := []struct {
+		name    string
+		data    string
+		wantErr string
+	}{
+		{name: "valid", data: "{" + valid + "}"},
+		{name: "unknown key", data: `{` + valid + `, "DbHots": "x"}`, wantErr: `unknown field "DbHots"`},
+		{name: "syntax error", data: `{"DbName": }`, wantErr: "can't parse config file"},
+		{name: "invalid config", data: `{"DbName": "db"}`, wantErr: "DbPort"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := filepath.Join(t.TempDir(), "config.json")
+			if err := os.WriteFile(file, []byte(tt.data), 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			c, err := FromFileStrict(file)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("FromFileStrict() error = %v, want it to mention %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if c.SSLCertFile != "client.crt" {
+				t.Fatalf("alias not decoded: %+v", c)
+			}
+		})
+	}
+}
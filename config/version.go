@@ -0,0 +1,34 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+//ServerVersionNum converts a postgres version such as "14", "14.2" or "9.6"
+//into the integer form the server reports as server_version_num
+func ServerVersionNum(version string) (int, error) {
+	parts := strings.Split(version, ".")
+	if len(parts) > 3 {
+		return 0, fmt.Errorf("invalid server version %q", version)
+	}
+
+	nums := make([]int, 3)
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid server version %q", version)
+		}
+		nums[i] = n
+	}
+
+	// from 10 onwards versions have two parts, major and minor
+	if nums[0] >= 10 {
+		if len(parts) > 2 {
+			return 0, fmt.Errorf("invalid server version %q", version)
+		}
+		return nums[0]*10000 + nums[1], nil
+	}
+	return nums[0]*10000 + nums[1]*100 + nums[2], nil
+}
@@ -0,0 +1,33 @@
+package config
+
+import "testing"
+
+func TestServerVersionNum(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    int
+		wantErr bool
+	}{
+		{in: "14", want: 140000},
+		{in: "14.2", want: 140002},
+		{in: "10.23", want: 100023},
+		{in: "9.6", want: 90600},
+		{in: "9.6.24", want: 90624},
+		{in: "14.2.1", wantErr: true},
+		{in: "1.2.3.4", wantErr: true},
+		{in: "14beta1", wantErr: true},
+		{in: "-1", wantErr: true},
+		{in: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ServerVersionNum(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ServerVersionNum(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Fatalf("ServerVersionNum(%q) = %d, want %d", tt.in, got, tt.want)
+			}
+		})
+	}
+}
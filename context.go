@@ -0,0 +1,47 @@
+package pgxtls
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v4"
+	pool "github.com/jackc/pgx/v4/pgxpool"
+)
+
+type contextKey int
+
+const (
+	databaseKey contextKey = iota
+	applicationKey
+)
+
+// DatabaseFromContext returns the name of the database
+// a connection passed to an AfterConnectFunc is to
+func DatabaseFromContext(ctx context.Context) (string, bool) {
+	name, ok := ctx.Value(databaseKey).(string)
+	return name, ok
+}
+
+// ApplicationFromContext returns the application_name of the connection
+// passed to an AfterConnectFunc, if it was set
+func ApplicationFromContext(ctx context.Context) (string, bool) {
+	name, ok := ctx.Value(applicationKey).(string)
+	return name, ok
+}
+
+// annotateAfterConnect makes the database and application names of each
+// connection available to cfg's AfterConnect hook through its context
+func annotateAfterConnect(cfg *pool.Config) {
+	next := cfg.AfterConnect
+	if next == nil {
+		return
+	}
+
+	cfg.AfterConnect = func(ctx context.Context, conn *pgx.Conn) error {
+		cc := conn.Config()
+		ctx = context.WithValue(ctx, databaseKey, cc.Database)
+		if app, ok := cc.RuntimeParams["application_name"]; ok {
+			ctx = context.WithValue(ctx, applicationKey, app)
+		}
+		return next(ctx, conn)
+	}
+}
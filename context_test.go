@@ -0,0 +1,65 @@
+package pgxtls
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jackc/pgx/v4"
+	pool "github.com/jackc/pgx/v4/pgxpool"
+)
+
+func TestAnnotateAfterConnect(t *testing.T) {
+	srv := newFakeServer(t)
+	ctx := context.Background()
+
+	tests := []struct {
+		name    string
+		app     string
+		wantApp bool
+	}{
+		{name: "with application_name", app: "billing", wantApp: true},
+		{name: "without application_name"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := srv.poolConfig(t)
+			if tt.app != "" {
+				cfg.ConnConfig.RuntimeParams["application_name"] = tt.app
+			}
+
+			var db, app string
+			var dbOK, appOK bool
+			cfg.AfterConnect = func(ctx context.Context, _ *pgx.Conn) error {
+				db, dbOK = DatabaseFromContext(ctx)
+				app, appOK = ApplicationFromContext(ctx)
+				return nil
+			}
+			annotateAfterConnect(cfg)
+
+			p, err := pool.ConnectConfig(ctx, cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer p.Close()
+
+			if !dbOK || db != "db" {
+				t.Fatalf("DatabaseFromContext() = %q, %v", db, dbOK)
+			}
+			if appOK != tt.wantApp || app != tt.app {
+				t.Fatalf("ApplicationFromContext() = %q, %v", app, appOK)
+			}
+		})
+	}
+}
+
+func TestAnnotateAfterConnectWithoutHook(t *testing.T) {
+	cfg := &pool.Config{}
+	annotateAfterConnect(cfg)
+	if cfg.AfterConnect != nil {
+		t.Fatal("AfterConnect installed where there was none")
+	}
+
+	if _, ok := DatabaseFromContext(context.Background()); ok {
+		t.Fatal("database found in a bare context")
+	}
+}
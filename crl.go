@@ -0,0 +1,104 @@
+package pgxtls
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"time"
+)
+
+// loadCRL returns a check rejecting server certificates revoked by the
+// certificate revocation list in file. The list must be signed by one of
+// issuers and not be past its next update, so a forged or stale list
+// isn't trusted
+func loadCRL(file string, issuers []*x509.Certificate) (leafCheck, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read crl file: %v", err)
+	}
+
+	// both PEM and DER encoded lists are accepted
+	if block, _ := pem.Decode(data); block != nil {
+		if block.Type != "X509 CRL" {
+			return nil, fmt.Errorf("unable to parse crl file: PEM block is %s, not X509 CRL", block.Type)
+		}
+		data = block.Bytes
+	}
+
+	crl, err := x509.ParseRevocationList(data)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse crl file: %v", err)
+	}
+
+	if err := checkCRLIssuer(crl, issuers); err != nil {
+		return nil, err
+	}
+
+	if !crl.NextUpdate.IsZero() && time.Now().After(crl.NextUpdate) {
+		return nil, fmt.Errorf("crl file is stale, its next update was due %s", crl.NextUpdate.Format(time.RFC3339))
+	}
+
+	revoked := make(map[string]bool, len(crl.RevokedCertificateEntries))
+	for _, rc := range crl.RevokedCertificateEntries {
+		revoked[rc.SerialNumber.String()] = true
+	}
+
+	return func(leaf *x509.Certificate) error {
+		if revoked[leaf.SerialNumber.String()] {
+			return fmt.Errorf("server certificate %q with serial %s is revoked", leaf.Subject, leaf.SerialNumber)
+		}
+		return nil
+	}, nil
+}
+
+// checkCRLIssuer returns an error unless crl is signed by the one of
+// issuers it names as its issuer
+func checkCRLIssuer(crl *x509.RevocationList, issuers []*x509.Certificate) error {
+	for _, issuer := range issuers {
+		if !bytes.Equal(issuer.RawSubject, crl.RawIssuer) {
+			continue
+		}
+		if err := crl.CheckSignatureFrom(issuer); err != nil {
+			return fmt.Errorf("crl file signature doesn't verify against ca %q: %v", issuer.Subject, err)
+		}
+		return nil
+	}
+	return fmt.Errorf("crl file issuer %q isn't a configured ca", crl.Issuer)
+}
+
+// crlIssuers returns the certificates of the CA in src, which
+// the certificate revocation list must be signed by
+func crlIssuers(ctx context.Context, src SecretSource) ([]*x509.Certificate, error) {
+	CAcert, err := src.GetCA(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(CAcert) == 0 {
+		return nil, errors.New("SSLCRLFile needs SSLCAFile, the crl is checked against its ca")
+	}
+
+	if CAcert, err = decryptCA(ctx, src, CAcert); err != nil {
+		return nil, err
+	}
+
+	var issuers []*x509.Certificate
+	for rest := CAcert; ; {
+		var block *pem.Block
+		if block, rest = pem.Decode(rest); block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse ca cert: %v", err)
+		}
+		issuers = append(issuers, cert)
+	}
+	return issuers, nil
+}
@@ -0,0 +1,175 @@
+package pgxtls
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/danvixent/pgxtls/config"
+)
+
+// writeCRL writes a crl from ca revoking serials to dir and returns its path
+func writeCRL(t *testing.T, ca *testCA, dir string, serials ...*big.Int) string {
+	t.Helper()
+	return writeCRLDue(t, ca, dir, time.Now().Add(time.Hour), serials...)
+}
+
+// writeCRLDue writes a crl from ca with its next update at next
+func writeCRLDue(t *testing.T, ca *testCA, dir string, next time.Time, serials ...*big.Int) string {
+	t.Helper()
+	var revoked []pkix.RevokedCertificate
+	for _, serial := range serials {
+		revoked = append(revoked, pkix.RevokedCertificate{SerialNumber: serial, RevocationTime: time.Now()})
+	}
+	der, err := x509.CreateRevocationList(rand.Reader, &x509.RevocationList{
+		Number:              big.NewInt(1),
+		ThisUpdate:          next.Add(-2 * time.Hour),
+		NextUpdate:          next,
+		RevokedCertificates: revoked,
+	}, ca.cert, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return writeFile(t, dir, "crl.pem", pem.EncodeToMemory(&pem.Block{Type: "X509 CRL", Bytes: der}))
+}
+
+func TestLoadCRL(t *testing.T) {
+	ca := newTestCA(t)
+	dir := t.TempDir()
+
+	pemFile := writeCRL(t, ca, dir, big.NewInt(7))
+	data, err := os.ReadFile(pemFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, _ := pem.Decode(data)
+	derFile := writeFile(t, dir, "crl.der", block.Bytes)
+
+	revoked := &x509.Certificate{SerialNumber: big.NewInt(7)}
+	valid := &x509.Certificate{SerialNumber: big.NewInt(8)}
+
+	for _, file := range []string{pemFile, derFile} {
+		check, err := loadCRL(file, []*x509.Certificate{ca.cert})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := check(revoked); err == nil || !strings.Contains(err.Error(), "is revoked") {
+			t.Fatalf("%s: revoked certificate got %v", file, err)
+		}
+		if err := check(valid); err != nil {
+			t.Fatalf("%s: valid certificate rejected: %v", file, err)
+		}
+	}
+}
+
+func TestLoadCRLInvalid(t *testing.T) {
+	ca := newTestCA(t)
+	dir := t.TempDir()
+	tests := []struct {
+		name, file, wantErr string
+		issuers             []*x509.Certificate
+	}{
+		{"missing", dir + "/missing.pem", "unable to read crl file", nil},
+		{"garbage", writeFile(t, dir, "garbage.pem", []byte("not a crl")), "unable to parse crl file", nil},
+		{"certificate", writeFile(t, dir, "ca.pem", ca.pem), "unable to parse crl file", nil},
+		// a ca of the same name with another key
+		{"forged", writeCRL(t, newTestCA(t), t.TempDir()), "signature doesn't verify", []*x509.Certificate{ca.cert}},
+		{"unknown issuer", writeCRL(t, ca, t.TempDir()), "isn't a configured ca", nil},
+		{"stale", writeCRLDue(t, ca, t.TempDir(), time.Now().Add(-time.Minute)), "crl file is stale", []*x509.Certificate{ca.cert}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := loadCRL(tt.file, tt.issuers); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("loadCRL() error = %v, want it to mention %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCRLIssuers(t *testing.T) {
+	ca, other := newTestCA(t), newTestCA(t)
+	ctx := context.Background()
+
+	tests := []struct {
+		name    string
+		src     SecretSource
+		want    int
+		wantErr string
+	}{
+		{name: "ca", src: memSecrets{ca: ca.pem}, want: 1},
+		{name: "bundle", src: memSecrets{ca: append(append([]byte{}, ca.pem...), other.pem...)}, want: 2},
+		{name: "encrypted", src: FileSecretSource{CAPEM: encryptPEM(t, ca.pem, "hunter2"), CAPassphrase: "hunter2"}, want: 1},
+		{name: "no ca", src: memSecrets{}, wantErr: "SSLCRLFile needs SSLCAFile"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			issuers, err := crlIssuers(ctx, tt.src)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("crlIssuers() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(issuers) != tt.want {
+				t.Fatalf("crlIssuers() returned %d certificates, want %d", len(issuers), tt.want)
+			}
+		})
+	}
+}
+
+func TestServerCertificateRevoked(t *testing.T) {
+	ca := newTestCA(t)
+	revokedPEM, revokedKey := ca.issue(t, serverTemplate("db.example.com"))
+	validPEM, validKey := ca.issue(t, serverTemplate("db.example.com"))
+	clientCertPEM, clientKeyPEM := ca.issue(t, clientTemplate("user"))
+
+	block, _ := pem.Decode(revokedPEM)
+	revoked, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	c := dsnConfig()
+	c.SSLCertFile = writeFile(t, dir, "client.crt", clientCertPEM)
+	c.SSLKeyFile = writeFile(t, dir, "client.key", clientKeyPEM)
+	c.SSLCAFile = writeFile(t, dir, "ca.crt", ca.pem)
+	c.SSLCRLFile = writeCRL(t, ca, dir, revoked.SerialNumber)
+
+	tests := []struct {
+		name      string
+		mode      config.SSLMode
+		certPEM   []byte
+		keyPEM    []byte
+		wantError bool
+	}{
+		{"verify-full revoked", config.SSLModeVerifyFull, revokedPEM, revokedKey, true},
+		{"verify-full valid", config.SSLModeVerifyFull, validPEM, validKey, false},
+		{"verify-ca revoked", config.SSLModeVerifyCA, revokedPEM, revokedKey, true},
+		{"require revoked", config.SSLModeRequire, revokedPEM, revokedKey, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c.SSLMode = tt.mode
+			tlsCfg, err := newTLSConfig(context.Background(), c, newOptions(nil))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = handshake(t, forHost(tlsCfg, "db.example.com"), tt.certPEM, tt.keyPEM)
+			if tt.wantError != (err != nil && strings.Contains(err.Error(), "is revoked")) {
+				t.Fatalf("handshake() = %v, wantError %v", err, tt.wantError)
+			}
+		})
+	}
+}
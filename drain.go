@@ -0,0 +1,60 @@
+package pgxtls
+
+import (
+	"errors"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	pool "github.com/jackc/pgx/v4/pgxpool"
+)
+
+// drainTimeout bounds how long a draining pool waits for in-flight work
+const drainTimeout = 30 * time.Second
+
+// ErrDrainTimeout is reported by AutoDrainOnSignal when in-flight
+// work still holds connections once the drain timeout has passed
+var ErrDrainTimeout = errors.New("pool drain timed out with connections still acquired")
+
+// AutoDrainOnSignal closes p when the process receives one of sig, by
+// default SIGTERM or an interrupt. Once closed p refuses new acquires,
+// and in-flight work is given up to 30 seconds to release its connections.
+// The returned channel then receives nil, or ErrDrainTimeout if work still
+// holds connections. pgxpool can't close connections that are acquired,
+// so that work isn't interrupted, callers should exit the process, which
+// closes its connections, once ErrDrainTimeout is received
+func AutoDrainOnSignal(p *pool.Pool, sig ...os.Signal) <-chan error {
+	if len(sig) == 0 {
+		sig = []os.Signal{syscall.SIGTERM, os.Interrupt}
+	}
+
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, sig...)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- drainOn(p, ch, drainTimeout)
+		signal.Stop(ch)
+	}()
+	return done
+}
+
+// drainOn closes p once ch delivers, returning nil when p is closed
+// or ErrDrainTimeout when timeout passes while p is still closing
+func drainOn(p interface{ Close() }, ch <-chan os.Signal, timeout time.Duration) error {
+	<-ch
+
+	closed := make(chan struct{})
+	go func() {
+		p.Close()
+		close(closed)
+	}()
+
+	select {
+	case <-closed:
+		return nil
+	case <-time.After(timeout):
+		return ErrDrainTimeout
+	}
+}
@@ -0,0 +1,53 @@
+package pgxtls
+
+import (
+	"context"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	pool "github.com/jackc/pgx/v4/pgxpool"
+)
+
+func TestDrainOn(t *testing.T) {
+	srv := newFakeServer(t)
+	ctx := context.Background()
+
+	tests := []struct {
+		name    string
+		hold    bool
+		wantErr error
+	}{
+		{name: "idle", wantErr: nil},
+		{name: "in-flight work", hold: true, wantErr: ErrDrainTimeout},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := pool.ConnectConfig(ctx, srv.poolConfig(t))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			conn, err := p.Acquire(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.hold {
+				defer conn.Release()
+			} else {
+				conn.Release()
+			}
+
+			ch := make(chan os.Signal, 1)
+			ch <- syscall.SIGTERM
+			if err := drainOn(p, ch, 100*time.Millisecond); err != tt.wantErr {
+				t.Fatalf("drainOn() = %v, want %v", err, tt.wantErr)
+			}
+
+			if _, err := p.Acquire(ctx); err == nil {
+				t.Fatal("draining pool handed out a connection")
+			}
+		})
+	}
+}
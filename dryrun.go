@@ -0,0 +1,96 @@
+package pgxtls
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net/url"
+	"time"
+
+	"github.com/danvixent/pgxtls/config"
+)
+
+// DryRunReport describes the pool NewFromCfgMap would create
+type DryRunReport struct {
+	DSN string // connection string with the password redacted
+
+	TLS                bool     // whether connections are made over TLS
+	ServerName         string   // expected hostname on the server certificate
+	InsecureSkipVerify bool     // whether the server certificate goes unverified
+	MinTLSVersion      string   // lowest accepted tls version
+	MaxTLSVersion      string   // highest accepted tls version
+	CipherSuites       []string // accepted cipher suites, empty for Go's defaults
+	ClientCertSubjects []string // subjects of the client certificate chain
+
+	MaxConns              int32
+	MinConns              int32
+	MaxConnLifetime       time.Duration
+	MaxConnLifetimeJitter time.Duration
+	MaxConnIdleTime       time.Duration
+	HealthCheckPeriod     time.Duration
+	ConnectTimeout        time.Duration
+}
+
+// DryRun assembles everything needed to connect with config,
+// including reading the ssl files, without connecting, and
+// reports the resulting settings
+func DryRun(config *config.ConfigMap, opts ...Option) (*DryRunReport, error) {
+	cfg, err := newPoolConfig(context.Background(), config, nil, newOptions(opts))
+	if err != nil {
+		return nil, err
+	}
+
+	report := &DryRunReport{
+		DSN:                   redactDSN(buildDSN(config)),
+		MaxConns:              cfg.MaxConns,
+		MinConns:              cfg.MinConns,
+		MaxConnLifetime:       cfg.MaxConnLifetime,
+		MaxConnLifetimeJitter: cfg.MaxConnLifetimeJitter,
+		MaxConnIdleTime:       cfg.MaxConnIdleTime,
+		HealthCheckPeriod:     cfg.HealthCheckPeriod,
+		ConnectTimeout:        cfg.ConnConfig.ConnectTimeout,
+	}
+
+	if tlsConfig := cfg.ConnConfig.TLSConfig; tlsConfig != nil {
+		report.TLS = true
+		report.ServerName = tlsConfig.ServerName
+		// verify-ca checks the chain itself with InsecureSkipVerify set
+		report.InsecureSkipVerify = config.SSLInsecureSkipVerify || encryptOnly(config.SSLMode, tlsConfig.RootCAs)
+		report.MinTLSVersion = tlsVersionOrDefault(tlsConfig.MinVersion)
+		report.MaxTLSVersion = tlsVersionOrDefault(tlsConfig.MaxVersion)
+
+		for _, id := range tlsConfig.CipherSuites {
+			report.CipherSuites = append(report.CipherSuites, tls.CipherSuiteName(id))
+		}
+
+		for _, cert := range tlsConfig.Certificates {
+			for _, der := range cert.Certificate {
+				if c, err := x509.ParseCertificate(der); err == nil {
+					report.ClientCertSubjects = append(report.ClientCertSubjects, c.Subject.String())
+				}
+			}
+		}
+	}
+
+	return report, nil
+}
+
+func tlsVersionOrDefault(v uint16) string {
+	if v == 0 {
+		return "default"
+	}
+	return tlsVersionName(v)
+}
+
+// redactDSN masks the password in dsn
+func redactDSN(dsn string) string {
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return "[unparseable dsn]"
+	}
+
+	if _, ok := u.User.Password(); ok {
+		u.User = url.UserPassword(u.User.Username(), "xxxxx")
+	}
+	return u.String()
+}
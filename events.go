@@ -0,0 +1,113 @@
+package pgxtls
+
+import (
+	"context"
+	"net"
+	"sync"
+	"sync/atomic"
+
+	"github.com/jackc/pgx/v4"
+	pool "github.com/jackc/pgx/v4/pgxpool"
+)
+
+// connEvents reports connections of a pool being opened and closed
+type connEvents struct {
+	onConnect    func(pid uint32)
+	onDisconnect func(pid uint32)
+}
+
+// WithOnConnect calls fn with the backend PID of every new connection
+// of the pool once it is established
+func WithOnConnect(fn func(pid uint32)) Option {
+	return func(o *options) {
+		o.events().onConnect = fn
+	}
+}
+
+// WithOnDisconnect calls fn with the backend PID of every connection
+// of the pool when it is closed, whether by the pool or the server
+func WithOnDisconnect(fn func(pid uint32)) Option {
+	return func(o *options) {
+		o.events().onDisconnect = fn
+	}
+}
+
+// events returns the connEvents of o, creating it on first use
+func (o *options) events() *connEvents {
+	if o.connEvents == nil {
+		o.connEvents = &connEvents{}
+	}
+	return o.connEvents
+}
+
+// install hooks e into cfg. pgxpool has no hook for closing connections,
+// so each connection is dialed through a net.Conn reporting its own close
+func (e *connEvents) install(cfg *pool.Config) {
+	beforeConnect, afterConnect := cfg.BeforeConnect, cfg.AfterConnect
+
+	cfg.BeforeConnect = func(ctx context.Context, cc *pgx.ConnConfig) error {
+		if beforeConnect != nil {
+			if err := beforeConnect(ctx, cc); err != nil {
+				return err
+			}
+		}
+
+		dial := cc.DialFunc
+		cc.DialFunc = func(ctx context.Context, network, addr string) (net.Conn, error) {
+			conn, err := dial(ctx, network, addr)
+			if err != nil {
+				return nil, err
+			}
+			return &eventConn{Conn: conn, events: e}, nil
+		}
+		return nil
+	}
+
+	cfg.AfterConnect = chainAfterConnect(afterConnect, func(_ context.Context, conn *pgx.Conn) error {
+		pid := conn.PgConn().PID()
+		if ec := findEventConn(conn.PgConn().Conn(), e); ec != nil {
+			atomic.StoreUint32(&ec.pid, pid)
+		}
+		if e.onConnect != nil {
+			e.onConnect(pid)
+		}
+		return nil
+	})
+}
+
+// eventConn is a net.Conn calling OnDisconnect when closed
+type eventConn struct {
+	net.Conn
+	events *connEvents
+	// pid is set once the connection is established
+	pid  uint32
+	once sync.Once
+}
+
+func (c *eventConn) Close() error {
+	c.once.Do(func() {
+		if pid := atomic.LoadUint32(&c.pid); pid != 0 && c.events.onDisconnect != nil {
+			c.events.onDisconnect(pid)
+		}
+	})
+	return c.Conn.Close()
+}
+
+// findEventConn returns the eventConn of e under conn, which
+// tls.Conn and the other wrappers of this package expose
+// through their NetConn method, or nil if there is none
+func findEventConn(conn net.Conn, e *connEvents) *eventConn {
+	for {
+		switch c := conn.(type) {
+		case *eventConn:
+			if c.events == e {
+				return c
+			}
+			conn = c.Conn
+		case interface{ NetConn() net.Conn }:
+			conn = c.NetConn()
+		default:
+			return nil
+		}
+	}
+}
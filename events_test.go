@@ -0,0 +1,90 @@
+package pgxtls
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"testing"
+	"time"
+
+	pool "github.com/jackc/pgx/v4/pgxpool"
+)
+
+func TestConnEvents(t *testing.T) {
+	srv := newFakeServer(t)
+
+	connected := make(chan uint32, 1)
+	disconnected := make(chan uint32, 1)
+	o := newOptions([]Option{
+		WithOnConnect(func(pid uint32) { connected <- pid }),
+		WithOnDisconnect(func(pid uint32) { disconnected <- pid }),
+	})
+
+	cfg := srv.poolConfig(t)
+	o.connEvents.install(cfg)
+
+	ctx := context.Background()
+	p, err := pool.ConnectConfig(ctx, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	conn, err := p.Acquire(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Release()
+
+	pid := conn.Conn().PgConn().PID()
+	select {
+	case got := <-connected:
+		if got != pid {
+			t.Fatalf("OnConnect got pid %d, want %d", got, pid)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("OnConnect not called")
+	}
+
+	srv.kill(pid)
+	if _, err := conn.Exec(ctx, "SELECT 1"); err == nil {
+		t.Fatal("query on a killed backend succeeded")
+	}
+
+	select {
+	case got := <-disconnected:
+		if got != pid {
+			t.Fatalf("OnDisconnect got pid %d, want %d", got, pid)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("OnDisconnect not called")
+	}
+}
+
+func TestFindEventConn(t *testing.T) {
+	plain, remote := net.Pipe()
+	defer plain.Close()
+	defer remote.Close()
+
+	e, other := &connEvents{}, &connEvents{}
+	ec := &eventConn{Conn: plain, events: e}
+	tests := []struct {
+		name string
+		conn net.Conn
+		want *eventConn
+	}{
+		{"direct", ec, ec},
+		{"tls", tls.Client(ec, &tls.Config{}), ec},
+		{"handshake timeout", tls.Client(&handshakeConn{Conn: ec}, &tls.Config{}), ec},
+		{"under another's", &eventConn{Conn: ec, events: other}, ec},
+		{"another's only", &eventConn{Conn: plain, events: other}, nil},
+		{"none", plain, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := findEventConn(tt.conn, e); got != tt.want {
+				t.Fatalf("findEventConn() = %p, want %p", got, tt.want)
+			}
+		})
+	}
+}
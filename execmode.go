@@ -0,0 +1,52 @@
+package pgxtls
+
+import (
+	"github.com/danvixent/pgxtls/config"
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgconn/stmtcache"
+	"github.com/jackc/pgx/v4"
+)
+
+// defaultStatementCacheCapacity matches pgx's own default
+const defaultStatementCacheCapacity = 512
+
+// setExecMode configures cc to send queries as the QueryExecMode of config
+// says, or as its StatementCacheMode does when that is empty. Without
+// either the simple protocol is kept
+func setExecMode(cc *pgx.ConnConfig, c *config.ConfigMap) {
+	mode := c.QueryExecMode
+	if mode == "" {
+		switch c.StatementCacheMode {
+		case config.StatementCacheModePrepare:
+			mode = config.QueryExecModeCacheStatement
+		case config.StatementCacheModeDescribe:
+			mode = config.QueryExecModeCacheDescribe
+		}
+	}
+
+	capacity := c.StatementCacheCapacity
+	if capacity == 0 {
+		capacity = defaultStatementCacheCapacity
+	}
+
+	switch mode {
+	case config.QueryExecModeSimpleProtocol, "":
+		cc.PreferSimpleProtocol = true
+		cc.BuildStatementCache = nil
+	case config.QueryExecModeCacheStatement:
+		cc.PreferSimpleProtocol = false
+		cc.BuildStatementCache = statementCache(stmtcache.ModePrepare, capacity)
+	case config.QueryExecModeCacheDescribe:
+		cc.PreferSimpleProtocol = false
+		cc.BuildStatementCache = statementCache(stmtcache.ModeDescribe, capacity)
+	case config.QueryExecModeDescribeExec:
+		cc.PreferSimpleProtocol = false
+		cc.BuildStatementCache = nil
+	}
+}
+
+func statementCache(mode, capacity int) pgx.BuildStatementCacheFunc {
+	return func(conn *pgconn.PgConn) stmtcache.Cache {
+		return stmtcache.New(conn, mode, capacity)
+	}
+}
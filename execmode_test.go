@@ -0,0 +1,46 @@
+package pgxtls
+
+import (
+	"testing"
+
+	"github.com/danvixent/pgxtls/config"
+	"github.com/jackc/pgx/v4"
+)
+
+func TestSetExecMode(t *testing.T) {
+	tests := []struct {
+		name       string
+		exec       config.QueryExecMode
+		cache      config.StatementCacheMode
+		capacity   int
+		wantSimple bool
+		wantCap    int // capacity of the statement cache, 0 for none
+	}{
+		{name: "default", wantSimple: true},
+		{name: "simple protocol", exec: config.QueryExecModeSimpleProtocol, wantSimple: true},
+		{name: "cache statement", exec: config.QueryExecModeCacheStatement, wantCap: defaultStatementCacheCapacity},
+		{name: "cache describe", exec: config.QueryExecModeCacheDescribe, wantCap: defaultStatementCacheCapacity},
+		{name: "describe exec", exec: config.QueryExecModeDescribeExec},
+		{name: "statement cache prepare", cache: config.StatementCacheModePrepare, wantCap: defaultStatementCacheCapacity},
+		{name: "statement cache describe", cache: config.StatementCacheModeDescribe, wantCap: defaultStatementCacheCapacity},
+		{name: "capacity", cache: config.StatementCacheModePrepare, capacity: 64, wantCap: 64},
+		{name: "capacity with exec mode", exec: config.QueryExecModeCacheDescribe, capacity: 16, wantCap: 16},
+		{name: "capacity without cache", capacity: 64, wantSimple: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cc := &pgx.ConnConfig{}
+			setExecMode(cc, &config.ConfigMap{QueryExecMode: tt.exec, StatementCacheMode: tt.cache, StatementCacheCapacity: tt.capacity})
+			if cc.PreferSimpleProtocol != tt.wantSimple {
+				t.Fatalf("PreferSimpleProtocol = %v, want %v", cc.PreferSimpleProtocol, tt.wantSimple)
+			}
+			gotCap := 0
+			if cc.BuildStatementCache != nil {
+				gotCap = cc.BuildStatementCache(nil).Cap()
+			}
+			if gotCap != tt.wantCap {
+				t.Fatalf("statement cache capacity = %d, want %d", gotCap, tt.wantCap)
+			}
+		})
+	}
+}
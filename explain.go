@@ -0,0 +1,69 @@
+package pgxtls
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+)
+
+// explainTimeout bounds each EXPLAIN a DevExplain pool runs
+const explainTimeout = 30 * time.Second
+
+type explainingKey struct{}
+
+// explainLogger runs EXPLAIN ANALYZE on a separate connection for each
+// SELECT pgx logs, and logs the plan. It is meant for development only,
+// as each SELECT is executed a second time
+type explainLogger struct {
+	logger pgx.Logger
+
+	mu sync.Mutex
+	q  queryRower // the pool once it has been created
+}
+
+func (l *explainLogger) setQuerier(q queryRower) {
+	l.mu.Lock()
+	l.q = q
+	l.mu.Unlock()
+}
+
+func (l *explainLogger) Log(ctx context.Context, level pgx.LogLevel, msg string, data map[string]interface{}) {
+	// the EXPLAIN itself is logged too
+	if msg != "Query" || level != pgx.LogLevelInfo || ctx.Value(explainingKey{}) != nil {
+		return
+	}
+
+	sql, _ := data["sql"].(string)
+	// the logged arguments are abbreviated so they can't be replayed
+	if args, _ := data["args"].([]interface{}); len(args) > 0 || operation(sql) != "SELECT" {
+		return
+	}
+
+	l.mu.Lock()
+	q := l.q
+	l.mu.Unlock()
+	if q == nil {
+		return
+	}
+
+	// explain from another goroutine, the connection that ran sql
+	// may still be held and could be the only one in the pool
+	go l.explain(q, sql)
+}
+
+func (l *explainLogger) explain(q queryRower, sql string) {
+	ctx, cancel := context.WithTimeout(context.WithValue(context.Background(), explainingKey{}, true), explainTimeout)
+	defer cancel()
+
+	var plan string
+	err := q.QueryRow(ctx, "EXPLAIN (ANALYZE, FORMAT JSON) "+strings.TrimRight(sql, "; \n")).Scan(&plan)
+	if err != nil {
+		l.logger.Log(ctx, pgx.LogLevelWarn, "explain failed", map[string]interface{}{"sql": sql, "err": err})
+		return
+	}
+
+	l.logger.Log(ctx, pgx.LogLevelInfo, "query plan", map[string]interface{}{"sql": sql, "plan": plan})
+}
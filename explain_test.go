@@ -0,0 +1,70 @@
+package pgxtls
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgproto3/v2"
+)
+
+func TestDevExplain(t *testing.T) {
+	srv := newFakeServer(t)
+	srv.rows["SELECT name FROM jobs"] = "nightly"
+	srv.rows["EXPLAIN (ANALYZE, FORMAT JSON) SELECT name FROM jobs"] = `[{"Plan": {"Node Type": "Seq Scan"}}]`
+	srv.rows["SELECT name FROM jobs WHERE id = 7"] = "nightly"
+	srv.fail["EXPLAIN (ANALYZE, FORMAT JSON) SELECT name FROM jobs WHERE id = 7"] = &pgproto3.ErrorResponse{Severity: "ERROR", Code: "42501", Message: "permission denied"}
+
+	c := srv.configMap(t)
+	c.DevExplain = true
+	c.MaxConns = 1
+	logs := &logRecorder{}
+	ctx := context.Background()
+	p, err := NewFromCfgMap(ctx, c, nil, WithLogger(logs))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	tests := []struct {
+		name    string
+		sql     string
+		args    []interface{}
+		wantMsg string
+	}{
+		{name: "select", sql: "SELECT name FROM jobs;", wantMsg: "query plan"},
+		{name: "explain fails", sql: "SELECT name FROM jobs WHERE id = 7", wantMsg: "explain failed"},
+		{name: "arguments", sql: "SELECT name FROM jobs WHERE id = $1", args: []interface{}{7}},
+		{name: "not a select", sql: "UPDATE jobs SET done = true"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := len(logs.find("query plan")) + len(logs.find("explain failed"))
+			rows, err := p.Query(ctx, tt.sql, tt.args...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			rows.Close()
+			if err := rows.Err(); err != nil {
+				t.Fatal(err)
+			}
+
+			if tt.wantMsg == "" {
+				time.Sleep(20 * time.Millisecond)
+				if after := len(logs.find("query plan")) + len(logs.find("explain failed")); after != before {
+					t.Fatalf("explained %q", tt.sql)
+				}
+				return
+			}
+			// explained on another goroutine, with the only connection released
+			waitFor(t, func() bool { return len(logs.find(tt.wantMsg)) > 0 })
+			entry := logs.find(tt.wantMsg)[0]
+			if entry.data["sql"] != tt.sql {
+				t.Fatalf("logged %v", entry.data)
+			}
+			if tt.wantMsg == "query plan" && entry.data["plan"] != srv.rows["EXPLAIN (ANALYZE, FORMAT JSON) SELECT name FROM jobs"] {
+				t.Fatalf("logged plan %v", entry.data["plan"])
+			}
+		})
+	}
+}
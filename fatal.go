@@ -0,0 +1,76 @@
+package pgxtls
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/jackc/pgx/v4"
+	pool "github.com/jackc/pgx/v4/pgxpool"
+)
+
+// fatalError is an AfterConnectFunc error retrying won't fix
+type fatalError struct {
+	err error
+}
+
+func (e *fatalError) Error() string { return e.err.Error() }
+
+func (e *fatalError) Unwrap() error { return e.err }
+
+// Fatal marks err, returned by an AfterConnectFunc, as one no new
+// connection will get past, such as a role that doesn't exist. Once a
+// connection fails with it the pool makes no more connections, failing
+// each attempt with err instead, and NewFromCfgMap returns it if it
+// happens on the first connection. Errors not marked only discard the
+// connection they happened on
+func Fatal(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &fatalError{err: err}
+}
+
+// IsFatal reports whether err was marked with Fatal
+func IsFatal(err error) bool {
+	var fe *fatalError
+	return errors.As(err, &fe)
+}
+
+// stopOnFatal makes cfg stop connecting once its AfterConnect hook
+// returns an error marked with Fatal
+func stopOnFatal(cfg *pool.Config) {
+	var (
+		mu    sync.Mutex
+		fatal error
+	)
+
+	before := cfg.BeforeConnect
+	cfg.BeforeConnect = func(ctx context.Context, cc *pgx.ConnConfig) error {
+		mu.Lock()
+		err := fatal
+		mu.Unlock()
+		if err != nil {
+			return err
+		}
+
+		if before != nil {
+			return before(ctx, cc)
+		}
+		return nil
+	}
+
+	after := cfg.AfterConnect
+	if after == nil {
+		return
+	}
+	cfg.AfterConnect = func(ctx context.Context, conn *pgx.Conn) error {
+		err := after(ctx, conn)
+		if IsFatal(err) {
+			mu.Lock()
+			fatal = err
+			mu.Unlock()
+		}
+		return err
+	}
+}
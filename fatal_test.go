@@ -0,0 +1,101 @@
+package pgxtls
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+)
+
+func TestIsFatal(t *testing.T) {
+	missing := errors.New(`role "tenant" does not exist`)
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"nil", nil, false},
+		{"plain", missing, false},
+		{"marked", Fatal(missing), true},
+		{"wrapped", fmt.Errorf("after connect: %w", Fatal(missing)), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsFatal(tt.err); got != tt.want {
+				t.Fatalf("IsFatal(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+			if tt.want && !errors.Is(tt.err, missing) {
+				t.Fatalf("%v doesn't wrap %v", tt.err, missing)
+			}
+		})
+	}
+	if Fatal(nil) != nil {
+		t.Fatal("Fatal(nil) isn't nil")
+	}
+}
+
+func TestStopOnFatal(t *testing.T) {
+	missing := errors.New(`role "tenant" does not exist`)
+	tests := []struct {
+		name      string
+		fatalFrom int // connection the AfterConnectFunc starts failing on, 0 for never
+		mark      bool
+		wantNew   bool
+		wantConns int
+	}{
+		{name: "first connection", fatalFrom: 1, mark: true, wantConns: 1},
+		{name: "later connection", fatalFrom: 2, mark: true, wantNew: true, wantConns: 2},
+		{name: "not marked", fatalFrom: 2, wantNew: true, wantConns: 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newFakeServer(t)
+			c := srv.configMap(t)
+			c.MaxConns = 4
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			n := 0
+			p, err := NewFromCfgMap(ctx, c, func(context.Context, *pgx.Conn) error {
+				n++
+				if n < tt.fatalFrom {
+					return nil
+				}
+				if tt.mark {
+					return Fatal(missing)
+				}
+				return missing
+			})
+			if !tt.wantNew {
+				if !errors.Is(err, missing) {
+					t.Fatalf("NewFromCfgMap() = %v, want %v", err, missing)
+				}
+				if got := srv.conns(); got != tt.wantConns {
+					t.Fatalf("server got %d connections, want %d", got, tt.wantConns)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer p.Close()
+
+			conn, err := p.Acquire(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Release()
+			for i := 0; i < 3; i++ {
+				if _, err := p.Acquire(ctx); !errors.Is(err, missing) {
+					t.Fatalf("acquire %d = %v, want %v", i, err, missing)
+				}
+			}
+			if got := srv.conns(); got != tt.wantConns {
+				t.Fatalf("server got %d connections, want %d", got, tt.wantConns)
+			}
+		})
+	}
+}
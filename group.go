@@ -0,0 +1,122 @@
+package pgxtls
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+)
+
+// PoolGroup holds the named pools of an application, checking all of
+// them from a single background loop rather than one per pool
+type PoolGroup struct {
+	logger pgx.Logger
+
+	mu        sync.Mutex
+	pools     map[string]Pool
+	unhealthy map[string]error
+	started   bool
+}
+
+// GroupStats are the statistics of the pools in a PoolGroup
+type GroupStats struct {
+	Total PoolStats            `json:"total"`
+	Pools map[string]PoolStats `json:"pools"`
+	// Unhealthy names the pools whose last check failed, sorted
+	Unhealthy []string `json:"unhealthy"`
+}
+
+// NewPoolGroup returns an empty PoolGroup. Failed checks are
+// logged to the logger set by WithLogger
+func NewPoolGroup(opts ...Option) *PoolGroup {
+	return &PoolGroup{
+		logger:    newOptions(opts).logger,
+		pools:     make(map[string]Pool),
+		unhealthy: make(map[string]error),
+	}
+}
+
+// Add adds p to g as name, replacing any pool already named so
+func (g *PoolGroup) Add(name string, p Pool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.pools[name] = p
+	delete(g.unhealthy, name)
+}
+
+// Remove removes the pool named name from g without closing it
+func (g *PoolGroup) Remove(name string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	delete(g.pools, name)
+	delete(g.unhealthy, name)
+}
+
+// Pool returns the pool named name, or nil if g has none
+func (g *PoolGroup) Pool(name string) Pool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.pools[name]
+}
+
+// Start checks every pool of g about every interval in the background
+// until ctx is cancelled, as StartKeepalive does for a single pool.
+// Only the first call starts the loop
+func (g *PoolGroup) Start(ctx context.Context, interval time.Duration) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.started {
+		return
+	}
+	g.started = true
+	go keepalive(ctx, g, interval, g.logger)
+}
+
+// Ping pings each pool of g in turn, recording which failed.
+// Failures are logged rather than returned, naming the pool
+func (g *PoolGroup) Ping(ctx context.Context) error {
+	g.mu.Lock()
+	pools := make(map[string]Pool, len(g.pools))
+	for name, p := range g.pools {
+		pools[name] = p
+	}
+	g.mu.Unlock()
+
+	for name, p := range pools {
+		err := p.Ping(ctx)
+		if err != nil && ctx.Err() == nil {
+			g.logger.Log(ctx, pgx.LogLevelWarn, "pool health check failed", map[string]interface{}{"pool": name, "err": err})
+		}
+
+		g.mu.Lock()
+		if _, ok := g.pools[name]; ok {
+			if err != nil {
+				g.unhealthy[name] = err
+			} else {
+				delete(g.unhealthy, name)
+			}
+		}
+		g.mu.Unlock()
+	}
+	return nil
+}
+
+// GroupStats returns the current statistics of each pool of g and their sum
+func (g *PoolGroup) GroupStats() GroupStats {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	stats := GroupStats{Pools: make(map[string]PoolStats, len(g.pools))}
+	for name, p := range g.pools {
+		s := snapshot(p.Stat())
+		stats.Pools[name] = s
+		stats.Total = stats.Total.add(s)
+	}
+	for name := range g.unhealthy {
+		stats.Unhealthy = append(stats.Unhealthy, name)
+	}
+	sort.Strings(stats.Unhealthy)
+	return stats
+}
@@ -0,0 +1,129 @@
+package pgxtls
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	pool "github.com/jackc/pgx/v4/pgxpool"
+)
+
+func TestPoolGroup(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	tests := []struct {
+		name          string
+		down          []string
+		remove        []string
+		wantPools     []string
+		wantUnhealthy []string
+	}{
+		{name: "healthy", wantPools: []string{"primary", "replica"}},
+		{name: "replica down", down: []string{"replica"}, wantPools: []string{"primary", "replica"}, wantUnhealthy: []string{"replica"}},
+		{name: "removed while down", down: []string{"replica"}, remove: []string{"replica"}, wantPools: []string{"primary"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logs := &logRecorder{}
+			g := NewPoolGroup(WithLogger(logs))
+			servers := map[string]*fakeServer{}
+			for _, name := range []string{"primary", "replica"} {
+				servers[name] = newFakeServer(t)
+				cfg := servers[name].poolConfig(t)
+				cfg.MaxConns = 2
+				p, err := pool.ConnectConfig(ctx, cfg)
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer p.Close()
+				g.Add(name, p)
+			}
+
+			for _, name := range tt.down {
+				servers[name].close()
+			}
+			g.Ping(ctx)
+			for _, name := range tt.remove {
+				g.Remove(name)
+			}
+
+			stats := g.GroupStats()
+			var pools []string
+			for _, name := range []string{"primary", "replica"} {
+				if _, ok := stats.Pools[name]; ok {
+					pools = append(pools, name)
+				}
+			}
+			if fmt.Sprint(pools) != fmt.Sprint(tt.wantPools) {
+				t.Fatalf("pools = %v, want %v", pools, tt.wantPools)
+			}
+			if fmt.Sprint(stats.Unhealthy) != fmt.Sprint(tt.wantUnhealthy) {
+				t.Fatalf("unhealthy = %v, want %v", stats.Unhealthy, tt.wantUnhealthy)
+			}
+			if stats.Total.MaxConns != int32(2*len(tt.wantPools)) {
+				t.Fatalf("total MaxConns = %d, want %d", stats.Total.MaxConns, 2*len(tt.wantPools))
+			}
+
+			warned := logs.find("pool health check failed")
+			if len(warned) != len(tt.down) {
+				t.Fatalf("logged %d failed checks, want %d", len(warned), len(tt.down))
+			}
+			for i, e := range warned {
+				if e.data["pool"] != tt.down[i] {
+					t.Fatalf("failed check logged for %v, want %s", e.data["pool"], tt.down[i])
+				}
+			}
+		})
+	}
+}
+
+func TestPoolGroupAddReplacesUnhealthy(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	g := NewPoolGroup()
+	for i := 0; i < 2; i++ {
+		srv := newFakeServer(t)
+		p, err := pool.ConnectConfig(ctx, srv.poolConfig(t))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer p.Close()
+		if i == 0 {
+			srv.close()
+		}
+		g.Add("primary", p)
+		g.Ping(ctx)
+		if got, want := len(g.GroupStats().Unhealthy), 1-i; got != want {
+			t.Fatalf("after pool %d, %d unhealthy pools, want %d", i, got, want)
+		}
+	}
+	if g.Pool("primary") == nil || g.Pool("replica") != nil {
+		t.Fatal("Pool() didn't return the pools added")
+	}
+}
+
+func TestPoolStatsAdd(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b PoolStats
+		want PoolStats
+	}{
+		{"zero", PoolStats{}, PoolStats{}, PoolStats{}},
+		{
+			"counts and durations",
+			PoolStats{AcquireCount: 1, AcquireDuration: time.Second, IdleConns: 2, MaxConns: 4, NewConnsCount: 3},
+			PoolStats{AcquireCount: 2, AcquireDuration: time.Millisecond, AcquiredConns: 1, MaxConns: 4, MaxIdleDestroyCount: 1},
+			PoolStats{AcquireCount: 3, AcquireDuration: time.Second + time.Millisecond, AcquiredConns: 1, IdleConns: 2, MaxConns: 8, NewConnsCount: 3, MaxIdleDestroyCount: 1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.a.add(tt.b); got != tt.want {
+				t.Fatalf("add() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,105 @@
+package pgxtls
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/danvixent/pgxtls/config"
+	"github.com/jackc/pgx/v4"
+)
+
+// sslRequestCode asks a postgres server to switch to TLS
+const sslRequestCode = 80877103
+
+// TestTLSHandshake dials the first host in config and performs only the
+// TLS handshake, without authenticating, to tell TLS problems apart from
+// authentication ones. The server is sent the SSLRequest that precedes
+// TLS in the postgres protocol and nothing else. The negotiated version,
+// cipher suite and the server's certificate chain are logged to the
+// logger set by WithLogger, a failed handshake or verification is returned.
+// The host is dialed as the pool would, so DialNetwork, LocalAddr and
+// WithProxyProtocol apply
+func TestTLSHandshake(ctx context.Context, config *config.ConfigMap, opts ...Option) error {
+	o := newOptions(opts)
+	if o.cloudSQL != nil {
+		return errors.New("TestTLSHandshake can't probe WithCloudSQLDialer, the connector negotiates tls itself")
+	}
+
+	cfg, err := newPoolConfig(ctx, config, nil, o)
+	if err != nil {
+		return err
+	}
+	// sslmode allow tries tls after a plaintext attempt to the same host
+	tlsConfig := cfg.ConnConfig.TLSConfig
+	for _, fb := range cfg.ConnConfig.Fallbacks {
+		if tlsConfig == nil && fb.Host == cfg.ConnConfig.Host {
+			tlsConfig = fb.TLSConfig
+		}
+	}
+	if tlsConfig == nil {
+		return fmt.Errorf("sslmode %s doesn't use tls", config.SSLMode)
+	}
+
+	host := strings.Split(config.DbHost, ",")[0]
+	addr := net.JoinHostPort(host, strconv.Itoa(int(config.DbPort)))
+
+	conn, err := cfg.ConnConfig.DialFunc(ctx, "tcp", addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	if err := requestSSL(conn); err != nil {
+		return err
+	}
+
+	tlsConn := tls.Client(conn, tlsConfig)
+	if err := tlsConn.Handshake(); err != nil {
+		return fmt.Errorf("tls handshake with %s failed: %v", addr, err)
+	}
+
+	cs := tlsConn.ConnectionState()
+	chain := make([]string, len(cs.PeerCertificates))
+	for i, cert := range cs.PeerCertificates {
+		chain[i] = cert.Subject.String()
+	}
+
+	o.logger.Log(ctx, pgx.LogLevelInfo, "tls handshake succeeded", map[string]interface{}{
+		"addr":         addr,
+		"version":      tlsVersionName(cs.Version),
+		"cipher_suite": tls.CipherSuiteName(cs.CipherSuite),
+		"peer_chain":   chain,
+	})
+	return nil
+}
+
+// requestSSL sends the SSLRequest message on conn
+// and checks the server agrees to TLS
+func requestSSL(conn net.Conn) error {
+	msg := make([]byte, 8)
+	binary.BigEndian.PutUint32(msg[0:4], 8)
+	binary.BigEndian.PutUint32(msg[4:8], sslRequestCode)
+	if _, err := conn.Write(msg); err != nil {
+		return err
+	}
+
+	resp := make([]byte, 1)
+	if _, err := io.ReadFull(conn, resp); err != nil {
+		return err
+	}
+	if resp[0] != 'S' {
+		return errors.New("server refused tls")
+	}
+	return nil
+}
@@ -0,0 +1,174 @@
+package pgxtls
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/danvixent/pgxtls/config"
+)
+
+// serveSSLRequest accepts connections on a new listener, answering
+// their SSLRequest with reply and, for 'S', a tls handshake with cert
+func serveSSLRequest(t *testing.T, reply byte, cert tls.Certificate) net.Listener {
+	ln, _ := serveSSLRequestLogged(t, reply, cert)
+	return ln
+}
+
+// acceptLog records the connections a serveSSLRequest listener accepted
+type acceptLog struct {
+	mu sync.Mutex
+	// remoteIPs holds each connection's source address and proxied
+	// whether it started with a PROXY protocol v1 header
+	remoteIPs []string
+	proxied   []bool
+}
+
+func (l *acceptLog) String() string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return fmt.Sprint(l.remoteIPs, l.proxied)
+}
+
+// serveSSLRequestLogged is serveSSLRequest logging the accepted connections,
+// it skips a PROXY protocol v1 header ahead of the SSLRequest
+func serveSSLRequestLogged(t *testing.T, reply byte, cert tls.Certificate) (net.Listener, *acceptLog) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	log := &acceptLog{}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				conn.SetDeadline(time.Now().Add(5 * time.Second))
+				r := bufio.NewReader(conn)
+
+				proxied := false
+				if head, err := r.Peek(6); err == nil && string(head) == "PROXY " {
+					if _, err := r.ReadString('\n'); err != nil {
+						return
+					}
+					proxied = true
+				}
+				ip, _, _ := net.SplitHostPort(conn.RemoteAddr().String())
+				log.mu.Lock()
+				log.remoteIPs, log.proxied = append(log.remoteIPs, ip), append(log.proxied, proxied)
+				log.mu.Unlock()
+
+				msg := make([]byte, 8)
+				if _, err := io.ReadFull(r, msg); err != nil || binary.BigEndian.Uint32(msg[4:]) != sslRequestCode {
+					return
+				}
+				conn.Write([]byte{reply})
+				if reply == 'S' {
+					tls.Server(conn, &tls.Config{Certificates: []tls.Certificate{cert}}).Handshake()
+				}
+			}()
+		}
+	}()
+	return ln, log
+}
+
+func TestTestTLSHandshake(t *testing.T) {
+	ca := newTestCA(t)
+	serverPEM, serverKey := ca.issue(t, serverTemplate("db.example.com"))
+	serverCert, err := tls.X509KeyPair(serverPEM, serverKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clientPEM, clientKey := ca.issue(t, clientTemplate("user"))
+	dir := t.TempDir()
+	certFile := writeFile(t, dir, "client.crt", clientPEM)
+	keyFile := writeFile(t, dir, "client.key", clientKey)
+	caFile := writeFile(t, dir, "ca.crt", ca.pem)
+	otherCAFile := writeFile(t, dir, "other.crt", newTestCA(t).pem)
+
+	tests := []struct {
+		name    string
+		reply   byte
+		caFile  string
+		setup   func(c *config.ConfigMap)
+		opts    []Option
+		wantErr string
+		// wantAccepted is the log of the connections the server accepted
+		wantAccepted string
+	}{
+		{name: "verified", reply: 'S', caFile: caFile, wantAccepted: "[127.0.0.1] [false]"},
+		{name: "refused", reply: 'N', caFile: caFile, wantErr: "server refused tls"},
+		{name: "untrusted", reply: 'S', caFile: otherCAFile, wantErr: "tls handshake with"},
+		{
+			name: "local addr", reply: 'S', caFile: caFile,
+			setup:        func(c *config.ConfigMap) { c.LocalAddr = "127.0.0.2" },
+			wantAccepted: "[127.0.0.2] [false]",
+		},
+		{
+			name: "dial network", reply: 'S', caFile: caFile,
+			setup:   func(c *config.ConfigMap) { c.DialNetwork = "tcp6" },
+			wantErr: "dial tcp6",
+		},
+		{name: "proxy protocol", reply: 'S', caFile: caFile, opts: []Option{WithProxyProtocol(1)}, wantAccepted: "[127.0.0.1] [true]"},
+		{
+			name: "no tls", reply: 'S', caFile: caFile,
+			setup:   func(c *config.ConfigMap) { c.SSLMode = config.SSLModeDisable },
+			wantErr: "sslmode disable doesn't use tls",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ln, accepted := serveSSLRequestLogged(t, tt.reply, serverCert)
+			host, port, _ := net.SplitHostPort(ln.Addr().String())
+			n, _ := strconv.Atoi(port)
+
+			c := dsnConfig()
+			c.DbHost, c.DbPort, c.SSLHostname = host+",replica.example.com", uint16(n), "db.example.com"
+			c.SSLMode, c.SSLCertFile, c.SSLKeyFile, c.SSLCAFile = config.SSLModeVerifyFull, certFile, keyFile, tt.caFile
+			if tt.setup != nil {
+				tt.setup(c)
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			logs := &logRecorder{}
+			err := TestTLSHandshake(ctx, c, append(tt.opts, WithLogger(logs))...)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("TestTLSHandshake() = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			entries := logs.find("tls handshake succeeded")
+			if len(entries) != 1 {
+				t.Fatalf("logged %v", logs.entries)
+			}
+			chain, _ := entries[0].data["peer_chain"].([]string)
+			if entries[0].data["addr"] != ln.Addr().String() || len(chain) != 1 || chain[0] != "CN=db.example.com" {
+				t.Fatalf("logged %v", entries[0].data)
+			}
+			if got := accepted.String(); got != tt.wantAccepted {
+				t.Fatalf("server accepted %s, want %s", got, tt.wantAccepted)
+			}
+		})
+	}
+}
@@ -0,0 +1,94 @@
+package pgxtls
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/danvixent/pgxtls/config"
+
+	"github.com/jackc/pgx/v4"
+)
+
+// chainAfterConnect returns an AfterConnectFunc running first and then next,
+// either may be nil
+func chainAfterConnect(first, next AfterConnectFunc) AfterConnectFunc {
+	if first == nil {
+		return next
+	}
+	if next == nil {
+		return first
+	}
+	return func(ctx context.Context, conn *pgx.Conn) error {
+		if err := first(ctx, conn); err != nil {
+			return err
+		}
+		return next(ctx, conn)
+	}
+}
+
+// setRole returns an AfterConnectFunc switching the connection to role
+func setRole(role string) AfterConnectFunc {
+	stmt := "SET ROLE " + pgx.Identifier{role}.Sanitize()
+	return func(ctx context.Context, conn *pgx.Conn) error {
+		if _, err := conn.Exec(ctx, stmt); err != nil {
+			err = fmt.Errorf("unable to set role %q: %v", role, err)
+			if conn.IsClosed() {
+				return err
+			}
+			// the server refused the role, as it will on every connection
+			return Fatal(err)
+		}
+		return nil
+	}
+}
+
+// prepareStatements returns an AfterConnectFunc preparing each
+// statement of stmts, keyed by name, on the connection
+func prepareStatements(stmts map[string]string) AfterConnectFunc {
+	names := make([]string, 0, len(stmts))
+	for name := range stmts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return func(ctx context.Context, conn *pgx.Conn) error {
+		for _, name := range names {
+			if _, err := conn.Prepare(ctx, name, stmts[name]); err != nil {
+				return fmt.Errorf("unable to prepare statement %q: %v", name, err)
+			}
+		}
+		return nil
+	}
+}
+
+// requireServerVersion returns an AfterConnectFunc rejecting
+// servers older than min, a version such as "14"
+func requireServerVersion(min string) (AfterConnectFunc, error) {
+	minNum, err := config.ServerVersionNum(min)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(ctx context.Context, conn *pgx.Conn) error {
+		return checkServerVersion(ctx, conn, minNum, min)
+	}, nil
+}
+
+func checkServerVersion(ctx context.Context, q queryRower, minNum int, min string) error {
+	var s string
+	if err := q.QueryRow(ctx, "SHOW server_version_num").Scan(&s); err != nil {
+		return fmt.Errorf("unable to query server_version_num: %v", err)
+	}
+
+	num, err := strconv.Atoi(s)
+	if err != nil {
+		return fmt.Errorf("invalid server_version_num %q: %v", s, err)
+	}
+
+	if num < minNum {
+		return fmt.Errorf("server version %d is older than the minimum %s", num, min)
+	}
+	return nil
+}
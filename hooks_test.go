@@ -0,0 +1,127 @@
+package pgxtls
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/danvixent/pgxtls/config"
+	"github.com/jackc/pgproto3/v2"
+)
+
+func TestCheckServerVersion(t *testing.T) {
+	tests := []struct {
+		server  string
+		min     string
+		wantErr bool
+	}{
+		{server: "140002", min: "14"},
+		{server: "140002", min: "14.2"},
+		{server: "140002", min: "14.3", wantErr: true},
+		{server: "90624", min: "10", wantErr: true},
+		{server: "90624", min: "9.6"},
+		{server: "unknown", min: "9.6", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.server+" "+tt.min, func(t *testing.T) {
+			minNum, err := config.ServerVersionNum(tt.min)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = checkServerVersion(context.Background(), rowQuerier(tt.server), minNum, tt.min)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("checkServerVersion() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRequireServerVersionInvalid(t *testing.T) {
+	if _, err := requireServerVersion("fourteen"); err == nil {
+		t.Fatal("invalid minimum version accepted")
+	}
+}
+
+func TestSetRole(t *testing.T) {
+	tests := []struct {
+		name      string
+		role      string
+		refuse    bool
+		wantQuery string
+	}{
+		{name: "plain", role: "worker", wantQuery: `SET ROLE "worker"`},
+		{name: "quoted", role: `tenant"; RESET ROLE; --`, wantQuery: `SET ROLE "tenant""; RESET ROLE; --"`},
+		{name: "refused", role: "missing", refuse: true, wantQuery: `SET ROLE "missing"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newFakeServer(t)
+			if tt.refuse {
+				srv.fail[tt.wantQuery] = &pgproto3.ErrorResponse{Severity: "ERROR", Code: "22023", Message: `role "missing" does not exist`}
+			}
+			c := srv.configMap(t)
+			c.ConnectRole = tt.role
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			p, err := NewFromCfgMap(ctx, c, nil)
+			if tt.refuse {
+				if !IsFatal(err) || !strings.Contains(err.Error(), `unable to set role "missing"`) {
+					t.Fatalf("NewFromCfgMap() = %v, want a fatal set role error", err)
+				}
+			} else if err != nil {
+				t.Fatal(err)
+			} else {
+				p.Close()
+			}
+
+			if q := srv.queries(); len(q) == 0 || q[0] != tt.wantQuery {
+				t.Fatalf("server received %q, want %q first", q, tt.wantQuery)
+			}
+		})
+	}
+}
+
+func TestPrepareStatements(t *testing.T) {
+	tests := []struct {
+		name    string
+		stmts   map[string]string
+		fail    string
+		want    []string
+		wantErr string
+	}{
+		{name: "none"},
+		{name: "sorted", stmts: map[string]string{"touch": "UPDATE jobs SET seen = now()", "claim": "UPDATE jobs SET owner = $1"}, want: []string{"claim", "touch"}},
+		{name: "failing", stmts: map[string]string{"broken": "SELEC 1"}, fail: "SELEC 1", wantErr: `unable to prepare statement "broken"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newFakeServer(t)
+			if tt.fail != "" {
+				srv.fail[tt.fail] = &pgproto3.ErrorResponse{Severity: "ERROR", Code: "42601", Message: "syntax error"}
+			}
+			c := srv.configMap(t)
+			c.PrepareStatements = tt.stmts
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			p, err := NewFromCfgMap(ctx, c, nil)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("NewFromCfgMap() = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer p.Close()
+
+			if got := srv.statements(); fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Fatalf("prepared %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
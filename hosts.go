@@ -0,0 +1,242 @@
+package pgxtls
+
+import (
+	"context"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+	pool "github.com/jackc/pgx/v4/pgxpool"
+)
+
+// HostSelector picks which of the configured database hosts a
+// new connection is dialed to. A ConfigMap names several hosts
+// by separating them with commas in DbHost
+type HostSelector interface {
+	// Select returns the address out of addrs to try first, addrs are
+	// host:port pairs of the configured hosts, or socket paths
+	Select(addrs []string) string
+	// Observe records the acquire latency of addr, one of those passed to
+	// Select: how long a connection to it took from its dial until it was
+	// usable, reported when the pool first hands it to an Acquire, or err
+	// if dialing addr failed
+	Observe(addr string, latency time.Duration, err error)
+}
+
+// WithHostSelector tries every new connection at the host chosen by sel
+// first, falling back to the other hosts in their configured order
+func WithHostSelector(sel HostSelector) Option {
+	return func(o *options) {
+		o.hostSelector = sel
+	}
+}
+
+// selectHosts makes every new connection of cfg try the host sel picks
+// out of its primary and fallback hosts first, and the others after it in
+// their configured order. Only the order changes, so each host is still
+// dialed at its resolved addresses and verified with its own tls.Config
+func selectHosts(cfg *pool.Config, sel HostSelector) {
+	before := cfg.BeforeConnect
+	cfg.BeforeConnect = func(ctx context.Context, cc *pgx.ConnConfig) error {
+		if before != nil {
+			if err := before(ctx, cc); err != nil {
+				return err
+			}
+		}
+
+		reorderHosts(cc, sel)
+		observeDials(cc, sel)
+		return nil
+	}
+
+	cfg.AfterConnect = chainAfterConnect(cfg.AfterConnect, func(_ context.Context, conn *pgx.Conn) error {
+		if hc := findHostConn(conn.PgConn().Conn()); hc != nil {
+			hc.latency = time.Since(hc.start)
+		}
+		return nil
+	})
+
+	beforeAcquire := cfg.BeforeAcquire
+	cfg.BeforeAcquire = func(ctx context.Context, conn *pgx.Conn) bool {
+		if hc := findHostConn(conn.PgConn().Conn()); hc != nil && !hc.observed {
+			hc.observed = true
+			sel.Observe(hc.addr, hc.latency, nil)
+		}
+		return beforeAcquire == nil || beforeAcquire(ctx, conn)
+	}
+}
+
+// reorderHosts moves the host sel picks to the front of cc
+func reorderHosts(cc *pgx.ConnConfig, sel HostSelector) {
+	hosts := append([]*pgconn.FallbackConfig{{Host: cc.Host, Port: cc.Port, TLSConfig: cc.TLSConfig}}, cc.Fallbacks...)
+
+	var addrs []string
+	for _, h := range hosts {
+		if _, addr := pgconn.NetworkAddress(h.Host, h.Port); !contains(addrs, addr) {
+			addrs = append(addrs, addr)
+		}
+	}
+	pick := sel.Select(addrs)
+
+	// sslmode prefer and allow give each host a tls and a plaintext
+	// attempt, both move keeping their order
+	var first, rest []*pgconn.FallbackConfig
+	for _, h := range hosts {
+		if _, addr := pgconn.NetworkAddress(h.Host, h.Port); addr == pick {
+			first = append(first, h)
+		} else {
+			rest = append(rest, h)
+		}
+	}
+	if len(first) == 0 {
+		return
+	}
+
+	hosts = append(first, rest...)
+	cc.Host, cc.Port, cc.TLSConfig = hosts[0].Host, hosts[0].Port, hosts[0].TLSConfig
+	cc.Fallbacks = hosts[1:]
+}
+
+// observeDials reports the failed dials cc makes to sel and times the
+// others for the acquire path, under the address of the configured
+// host rather than the one it resolved to
+func observeDials(cc *pgx.ConnConfig, sel HostSelector) {
+	hostByIP := map[string]string{}
+	lookup := cc.LookupFunc
+	cc.LookupFunc = func(ctx context.Context, host string) ([]string, error) {
+		addrs, err := lookup(ctx, host)
+		for _, addr := range addrs {
+			if ip, _, err := net.SplitHostPort(addr); err == nil {
+				addr = ip
+			}
+			hostByIP[addr] = host
+		}
+		return addrs, err
+	}
+
+	dial := cc.DialFunc
+	cc.DialFunc = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		hostAddr := addr
+		if ip, port, err := net.SplitHostPort(addr); err == nil {
+			if host, ok := hostByIP[ip]; ok {
+				hostAddr = net.JoinHostPort(host, port)
+			}
+		}
+
+		start := time.Now()
+		conn, err := dial(ctx, network, addr)
+		if err != nil {
+			sel.Observe(hostAddr, time.Since(start), err)
+			return nil, err
+		}
+		return &hostConn{Conn: conn, addr: hostAddr, start: start}, nil
+	}
+}
+
+// hostConn is a net.Conn dialed to a configured host of a pool with a
+// HostSelector. The pool hands out a connection only once it has been
+// established, so latency is set before observed is read
+type hostConn struct {
+	net.Conn
+	addr  string
+	start time.Time
+	// latency is the time from the dial until the connection was usable
+	latency time.Duration
+	// observed is set once latency has been reported to the selector
+	observed bool
+}
+
+func (c *hostConn) NetConn() net.Conn { return c.Conn }
+
+// findHostConn returns the hostConn under conn, or nil if there is none
+func findHostConn(conn net.Conn) *hostConn {
+	for {
+		switch c := conn.(type) {
+		case *hostConn:
+			return c
+		case *eventConn:
+			conn = c.Conn
+		case interface{ NetConn() net.Conn }:
+			conn = c.NetConn()
+		default:
+			return nil
+		}
+	}
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// RoundRobin returns a HostSelector cycling through the hosts in order
+func RoundRobin() HostSelector {
+	return &roundRobin{}
+}
+
+type roundRobin struct {
+	next uint32
+}
+
+func (r *roundRobin) Select(addrs []string) string {
+	n := atomic.AddUint32(&r.next, 1) - 1
+	return addrs[n%uint32(len(addrs))]
+}
+
+func (r *roundRobin) Observe(string, time.Duration, error) {}
+
+// failedDialPenalty is the acquire latency a failed dial is recorded as
+const failedDialPenalty = 30 * time.Second
+
+// LatencyAware returns a HostSelector preferring the host with the lowest
+// moving average acquire latency. weight, between 0 and 1, is how much the
+// latest acquire counts towards the average. Hosts not observed yet are
+// tried first
+func LatencyAware(weight float64) HostSelector {
+	return &latencyAware{weight: weight, avg: make(map[string]float64)}
+}
+
+type latencyAware struct {
+	mu     sync.Mutex
+	weight float64
+	avg    map[string]float64
+}
+
+func (l *latencyAware) Select(addrs []string) string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	best, bestAvg := addrs[0], -1.0
+	for _, addr := range addrs {
+		avg, ok := l.avg[addr]
+		if !ok {
+			return addr
+		}
+		if bestAvg < 0 || avg < bestAvg {
+			best, bestAvg = addr, avg
+		}
+	}
+	return best
+}
+
+func (l *latencyAware) Observe(addr string, latency time.Duration, err error) {
+	if err != nil {
+		latency = failedDialPenalty
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if avg, ok := l.avg[addr]; ok {
+		l.avg[addr] = l.weight*float64(latency) + (1-l.weight)*avg
+	} else {
+		l.avg[addr] = float64(latency)
+	}
+}
@@ -0,0 +1,190 @@
+package pgxtls
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+	pool "github.com/jackc/pgx/v4/pgxpool"
+)
+
+// pickSelector always picks addr
+type pickSelector struct {
+	addr     string
+	observed []string
+}
+
+func (s *pickSelector) Select([]string) string { return s.addr }
+
+func (s *pickSelector) Observe(addr string, _ time.Duration, _ error) {
+	s.observed = append(s.observed, addr)
+}
+
+func TestReorderHosts(t *testing.T) {
+	tlsFor := func(host string) *tls.Config { return &tls.Config{ServerName: host} }
+	newConfig := func() *pgx.ConnConfig {
+		cc := &pgx.ConnConfig{}
+		cc.Host, cc.Port, cc.TLSConfig = "a.example.com", 5432, tlsFor("a.example.com")
+		cc.Fallbacks = []*pgconn.FallbackConfig{
+			{Host: "a.example.com", Port: 5432},
+			{Host: "b.example.com", Port: 5432, TLSConfig: tlsFor("b.example.com")},
+			{Host: "b.example.com", Port: 5432},
+			{Host: "/var/run/postgresql", Port: 5432},
+		}
+		return cc
+	}
+
+	tests := []struct {
+		name      string
+		pick      string
+		wantHosts []string
+	}{
+		{"primary", "a.example.com:5432", []string{"a.example.com", "a.example.com", "b.example.com", "b.example.com", "/var/run/postgresql"}},
+		{"fallback", "b.example.com:5432", []string{"b.example.com", "b.example.com", "a.example.com", "a.example.com", "/var/run/postgresql"}},
+		{"unix socket", "/var/run/postgresql/.s.PGSQL.5432", []string{"/var/run/postgresql", "a.example.com", "a.example.com", "b.example.com", "b.example.com"}},
+		{"unknown", "c.example.com:5432", []string{"a.example.com", "a.example.com", "b.example.com", "b.example.com", "/var/run/postgresql"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cc := newConfig()
+			reorderHosts(cc, &pickSelector{addr: tt.pick})
+
+			hosts := []string{cc.Host}
+			for _, fb := range cc.Fallbacks {
+				hosts = append(hosts, fb.Host)
+			}
+			if fmt.Sprint(hosts) != fmt.Sprint(tt.wantHosts) {
+				t.Fatalf("hosts = %v, want %v", hosts, tt.wantHosts)
+			}
+
+			// each tls attempt keeps the tls.Config of its host
+			if cc.TLSConfig != nil && cc.TLSConfig.ServerName != cc.Host {
+				t.Fatalf("%s is verified as %s", cc.Host, cc.TLSConfig.ServerName)
+			}
+			for _, fb := range cc.Fallbacks {
+				if fb.TLSConfig != nil && fb.TLSConfig.ServerName != fb.Host {
+					t.Fatalf("%s is verified as %s", fb.Host, fb.TLSConfig.ServerName)
+				}
+			}
+		})
+	}
+}
+
+func TestLatencyAwarePrefersFasterHost(t *testing.T) {
+	fast, slow := newFakeServer(t), newFakeServer(t)
+
+	cfg, err := pool.ParseConfig(fmt.Sprintf("postgres://user@%s,%s/db?sslmode=disable", slow.addr(), fast.addr()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// both hosts dial equally fast, the slow one takes longer to be usable
+	cfg.AfterConnect = func(_ context.Context, conn *pgx.Conn) error {
+		if conn.PgConn().Conn().RemoteAddr().String() == slow.addr() {
+			time.Sleep(50 * time.Millisecond)
+		}
+		return nil
+	}
+	selectHosts(cfg, LatencyAware(0.5))
+
+	ctx := context.Background()
+	p, err := pool.ConnectConfig(ctx, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	for i := 0; i < 10; i++ {
+		conn, err := p.Acquire(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// the pool drops closed connections, so every acquire dials
+		conn.Conn().Close(ctx)
+		conn.Release()
+	}
+
+	// each host is tried once before latencies are compared
+	if n := slow.conns(); n != 1 {
+		t.Fatalf("slow host got %d connections, want 1", n)
+	}
+	if n := fast.conns(); n != 9 {
+		t.Fatalf("fast host got %d connections, want 9", n)
+	}
+}
+
+func TestObserveDialsReportsConfiguredHost(t *testing.T) {
+	srv := newFakeServer(t)
+	host, port, _ := net.SplitHostPort(srv.addr())
+
+	cfg, err := pool.ParseConfig(fmt.Sprintf("postgres://user@localhost:%s/db?sslmode=disable", port))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.ConnConfig.LookupFunc = func(context.Context, string) ([]string, error) {
+		return []string{host}, nil
+	}
+	cfg.LazyConnect = true
+	sel := &pickSelector{addr: "localhost:" + port}
+	selectHosts(cfg, sel)
+
+	ctx := context.Background()
+	p, err := pool.ConnectConfig(ctx, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	// the latency is reported on the acquire the connection is made for
+	for i := 0; i < 2; i++ {
+		conn, err := p.Acquire(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		conn.Release()
+	}
+
+	if len(sel.observed) != 1 || sel.observed[0] != sel.addr {
+		t.Fatalf("observed %v, want [%s]", sel.observed, sel.addr)
+	}
+}
+
+func TestObserveFailedDial(t *testing.T) {
+	cfg, err := pool.ParseConfig("postgres://user@db.example.com:5432/db?sslmode=disable&connect_timeout=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.ConnConfig.LookupFunc = func(context.Context, string) ([]string, error) {
+		return []string{"192.0.2.1"}, nil
+	}
+	cfg.ConnConfig.DialFunc = func(context.Context, string, string) (net.Conn, error) {
+		return nil, errors.New("connection refused")
+	}
+	sel := &errSelector{}
+	selectHosts(cfg, sel)
+
+	if _, err := connect(context.Background(), cfg); err == nil {
+		t.Fatal("connected through a failing dial")
+	}
+	if want := "db.example.com:5432"; fmt.Sprint(sel.failed) != fmt.Sprint([]string{want}) {
+		t.Fatalf("observed failures %v, want [%s]", sel.failed, want)
+	}
+}
+
+// errSelector records the hosts observed with an error
+type errSelector struct {
+	failed []string
+}
+
+func (s *errSelector) Select(addrs []string) string { return addrs[0] }
+
+func (s *errSelector) Observe(addr string, _ time.Duration, err error) {
+	if err != nil {
+		s.failed = append(s.failed, addr)
+	}
+}
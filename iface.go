@@ -0,0 +1,55 @@
+package pgxtls
+
+import (
+	"context"
+
+	"github.com/danvixent/pgxtls/config"
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+	pool "github.com/jackc/pgx/v4/pgxpool"
+)
+
+// Pool is the subset of *pool.Pool most code needs,
+// letting consumers substitute a mock in their tests
+type Pool interface {
+	Acquire(ctx context.Context) (*pool.Conn, error)
+	Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row
+	Exec(ctx context.Context, sql string, arguments ...interface{}) (pgconn.CommandTag, error)
+	Begin(ctx context.Context) (pgx.Tx, error)
+	Ping(ctx context.Context) error
+	Close()
+	Stat() *pool.Stat
+}
+
+var (
+	_ Pool = (*pool.Pool)(nil)
+	_ Pool = (*ResilientPool)(nil)
+)
+
+// NewPool is NewFromCfgMap returning the pool as a Pool
+func NewPool(ctx context.Context, config *config.ConfigMap, fn AfterConnectFunc, opts ...Option) (Pool, error) {
+	p, err := NewFromCfgMap(ctx, config, fn, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// PoolFactory creates pools, letting frameworks inject pool
+// creation and tests substitute a fake
+type PoolFactory interface {
+	NewPool(ctx context.Context, config *config.ConfigMap, fn AfterConnectFunc) (*pool.Pool, error)
+}
+
+// DefaultPoolFactory is the PoolFactory creating pools
+// with NewFromCfgMap, passing it Options
+type DefaultPoolFactory struct {
+	Options []Option
+}
+
+var _ PoolFactory = DefaultPoolFactory{}
+
+func (f DefaultPoolFactory) NewPool(ctx context.Context, config *config.ConfigMap, fn AfterConnectFunc) (*pool.Pool, error) {
+	return NewFromCfgMap(ctx, config, fn, f.Options...)
+}
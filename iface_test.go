@@ -0,0 +1,94 @@
+package pgxtls
+
+import (
+	"context"
+	"testing"
+
+	"github.com/danvixent/pgxtls/config"
+	"github.com/jackc/pgx/v4"
+)
+
+func TestNewPool(t *testing.T) {
+	srv := newFakeServer(t)
+	ctx := context.Background()
+
+	tests := []struct {
+		name    string
+		set     func(c *config.ConfigMap)
+		wantErr bool
+	}{
+		{name: "valid", set: func(*config.ConfigMap) {}},
+		{name: "invalid", set: func(c *config.ConfigMap) { c.DbHost = "db.example.com:5432/other" }, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := srv.configMap(t)
+			tt.set(c)
+
+			p, err := NewPool(ctx, c, nil)
+			if tt.wantErr {
+				// a nil *pool.Pool in the interface wouldn't compare equal to nil
+				if err == nil || p != nil {
+					t.Fatalf("NewPool() = %v, %v, want a nil Pool and an error", p, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer p.Close()
+			if err := p.Ping(ctx); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestDefaultPoolFactory(t *testing.T) {
+	srv := newFakeServer(t)
+	ctx := context.Background()
+
+	tests := []struct {
+		name    string
+		set     func(c *config.ConfigMap)
+		wantErr bool
+	}{
+		{name: "valid", set: func(*config.ConfigMap) {}},
+		{name: "invalid", set: func(c *config.ConfigMap) { c.DbHost = "db.example.com:5432/other" }, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := srv.configMap(t)
+			tt.set(c)
+			logs := &logRecorder{}
+			connected := false
+			fn := func(context.Context, *pgx.Conn) error {
+				connected = true
+				return nil
+			}
+
+			var f PoolFactory = DefaultPoolFactory{Options: []Option{WithLogger(logs), WithSlowQueryLog(0, false)}}
+			p, err := f.NewPool(ctx, c, fn)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("NewPool() accepted an invalid config")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer p.Close()
+
+			if _, err := p.Exec(ctx, "VACUUM"); err != nil {
+				t.Fatal(err)
+			}
+			if !connected {
+				t.Fatal("the AfterConnectFunc wasn't run")
+			}
+			if len(logs.find("slow exec")) != 1 {
+				t.Fatal("the factory's options weren't applied")
+			}
+		})
+	}
+}
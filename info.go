@@ -0,0 +1,58 @@
+package pgxtls
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+
+	"github.com/danvixent/pgxtls/config"
+	pool "github.com/jackc/pgx/v4/pgxpool"
+)
+
+// ConnectInfo describes what a new pool connected to, as seen on its
+// first connection
+type ConnectInfo struct {
+	DSN           string `json:"dsn"` // password redacted
+	RemoteAddr    string `json:"remote_addr"`
+	ServerVersion string `json:"server_version"`
+	TLS           bool   `json:"tls"`
+	TLSVersion    string `json:"tls_version,omitempty"`
+	CipherSuite   string `json:"cipher_suite,omitempty"`
+	ServerName    string `json:"server_name,omitempty"`
+	PeerSubject   string `json:"peer_subject,omitempty"`
+}
+
+// NewWithInfo is NewFromCfgMap also describing the server the pool
+// connected to, from one of its connections
+func NewWithInfo(ctx context.Context, config *config.ConfigMap, fn AfterConnectFunc, opts ...Option) (*pool.Pool, ConnectInfo, error) {
+	p, err := NewFromCfgMap(ctx, config, fn, opts...)
+	if err != nil {
+		return nil, ConnectInfo{}, err
+	}
+
+	conn, err := p.Acquire(ctx)
+	if err != nil {
+		p.Close()
+		return nil, ConnectInfo{}, fmt.Errorf("unable to acquire connection for connect info: %v", err)
+	}
+	defer conn.Release()
+
+	pgConn := conn.Conn().PgConn()
+	info := ConnectInfo{
+		DSN:           redactDSN(buildDSN(config)),
+		RemoteAddr:    pgConn.Conn().RemoteAddr().String(),
+		ServerVersion: pgConn.ParameterStatus("server_version"),
+	}
+
+	if tlsConn, ok := pgConn.Conn().(*tls.Conn); ok {
+		cs := tlsConn.ConnectionState()
+		info.TLS = true
+		info.TLSVersion = tlsVersionName(cs.Version)
+		info.CipherSuite = tls.CipherSuiteName(cs.CipherSuite)
+		info.ServerName = cs.ServerName
+		if len(cs.PeerCertificates) > 0 {
+			info.PeerSubject = cs.PeerCertificates[0].Subject.String()
+		}
+	}
+	return p, info, nil
+}
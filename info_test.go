@@ -0,0 +1,67 @@
+package pgxtls
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/danvixent/pgxtls/config"
+	"github.com/jackc/pgproto3/v2"
+)
+
+func TestNewWithInfo(t *testing.T) {
+	tests := []struct {
+		name       string
+		useTLS     bool
+		wantServer string
+		wantPeer   string
+	}{
+		{name: "plaintext"},
+		{name: "tls", useTLS: true, wantServer: "db.example.com", wantPeer: "CN=db.example.com"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newFakeServer(t)
+			var c *config.ConfigMap
+			if tt.useTLS {
+				c = tlsConfigMap(t, srv)
+			} else {
+				c = srv.configMap(t)
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			p, info, err := NewWithInfo(ctx, c, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer p.Close()
+
+			if info.RemoteAddr != srv.addr() || info.ServerVersion != "14.0" {
+				t.Fatalf("NewWithInfo() = %+v", info)
+			}
+			if strings.Contains(info.DSN, c.Password) || !strings.Contains(info.DSN, c.DbUser) {
+				t.Fatalf("DSN = %s, want it redacted", info.DSN)
+			}
+			if info.TLS != tt.useTLS || info.ServerName != tt.wantServer || info.PeerSubject != tt.wantPeer {
+				t.Fatalf("NewWithInfo() = %+v", info)
+			}
+			if tt.useTLS && (info.TLSVersion != "TLS 1.3" || info.CipherSuite == "") {
+				t.Fatalf("NewWithInfo() = %+v", info)
+			}
+		})
+	}
+}
+
+func TestNewWithInfoError(t *testing.T) {
+	srv := newFakeServer(t)
+	srv.reject = &pgproto3.ErrorResponse{Severity: "FATAL", Code: "28P01", Message: "password authentication failed"}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if p, _, err := NewWithInfo(ctx, srv.configMap(t), nil); err == nil {
+		p.Close()
+		t.Fatal("NewWithInfo() connected to a server rejecting it")
+	}
+}
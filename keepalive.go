@@ -0,0 +1,43 @@
+package pgxtls
+
+import (
+	"context"
+	"math/rand"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	pool "github.com/jackc/pgx/v4/pgxpool"
+)
+
+// pinger is the part of a pool keepalives use
+type pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// StartKeepalive pings p about every interval in the background until ctx
+// is cancelled, keeping a connection from being dropped as idle by NATs and
+// firewalls. Each wait is lengthened by up to a tenth of interval at random
+// so pools started together don't ping together. Failed pings are logged
+// to the logger set by WithLogger
+func StartKeepalive(ctx context.Context, p *pool.Pool, interval time.Duration, opts ...Option) {
+	go keepalive(ctx, p, interval, newOptions(opts).logger)
+}
+
+func keepalive(ctx context.Context, p pinger, interval time.Duration, logger pgx.Logger) {
+	for {
+		wait := interval
+		if jitter := int64(interval / 10); jitter > 0 {
+			wait += time.Duration(rand.Int63n(jitter))
+		}
+
+		if err := sleep(ctx, wait); err != nil {
+			// ctx ends before the next ping is due
+			<-ctx.Done()
+			return
+		}
+
+		if err := p.Ping(ctx); err != nil && ctx.Err() == nil {
+			logger.Log(ctx, pgx.LogLevelWarn, "keepalive ping failed", map[string]interface{}{"err": err})
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package pgxtls
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// countPinger counts pings, failing them with err
+type countPinger struct {
+	pings int32
+	err   error
+}
+
+func (p *countPinger) Ping(context.Context) error {
+	atomic.AddInt32(&p.pings, 1)
+	return p.err
+}
+
+func TestKeepalive(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		wantLogs bool
+	}{
+		{name: "healthy"},
+		{name: "failing", err: errors.New("connection reset"), wantLogs: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &countPinger{err: tt.err}
+			logs := &logRecorder{}
+			ctx, cancel := context.WithCancel(context.Background())
+
+			done := make(chan struct{})
+			go func() {
+				keepalive(ctx, p, 5*time.Millisecond, logs)
+				close(done)
+			}()
+			waitFor(t, func() bool { return atomic.LoadInt32(&p.pings) >= 3 })
+			cancel()
+			<-done
+
+			// a ping failing as ctx is canceled isn't logged
+			n := atomic.LoadInt32(&p.pings)
+			if failed := logs.find("keepalive ping failed"); (len(failed) > 0) != tt.wantLogs || tt.wantLogs && len(failed) < int(n)-1 {
+				t.Fatalf("logged %d failures of %d pings", len(failed), n)
+			}
+
+			time.Sleep(20 * time.Millisecond)
+			if atomic.LoadInt32(&p.pings) != n {
+				t.Fatal("pinged after ctx was canceled")
+			}
+		})
+	}
+}
+
+func TestStartKeepalive(t *testing.T) {
+	srv := newFakeServer(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	p, err := NewFromCfgMap(ctx, srv.configMap(t), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	StartKeepalive(ctx, p, 5*time.Millisecond)
+	// pgx pings with an empty simple query
+	waitFor(t, func() bool {
+		n := 0
+		for _, q := range srv.queries() {
+			if q == ";" || q == "" {
+				n++
+			}
+		}
+		return n >= 2
+	})
+}
@@ -0,0 +1,49 @@
+package pgxtls
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"log"
+
+	"github.com/jackc/pgx/v4"
+)
+
+// stdLogger is a pgx.Logger writing to the standard library logger
+type stdLogger struct{}
+
+func (stdLogger) Log(_ context.Context, level pgx.LogLevel, msg string, data map[string]interface{}) {
+	log.Printf("pgxtls: %s: %s %v", level, msg, data)
+}
+
+var tlsVersions = map[uint16]string{
+	tls.VersionTLS10: "TLS 1.0",
+	tls.VersionTLS11: "TLS 1.1",
+	tls.VersionTLS12: "TLS 1.2",
+	tls.VersionTLS13: "TLS 1.3",
+}
+
+func tlsVersionName(v uint16) string {
+	if name, ok := tlsVersions[v]; ok {
+		return name
+	}
+	return fmt.Sprintf("0x%04x", v)
+}
+
+// logHandshake returns a tls.Config.VerifyConnection callback
+// logging the negotiated parameters of every handshake
+func logHandshake(logger pgx.Logger) func(tls.ConnectionState) error {
+	return func(cs tls.ConnectionState) error {
+		data := map[string]interface{}{
+			"version":      tlsVersionName(cs.Version),
+			"cipher_suite": tls.CipherSuiteName(cs.CipherSuite),
+			"server_name":  cs.ServerName,
+		}
+		if len(cs.PeerCertificates) > 0 {
+			data["peer_subject"] = cs.PeerCertificates[0].Subject.String()
+		}
+
+		logger.Log(context.Background(), pgx.LogLevelInfo, "tls handshake complete", data)
+		return nil
+	}
+}
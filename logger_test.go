@@ -0,0 +1,105 @@
+package pgxtls
+
+import (
+	"context"
+	"crypto/tls"
+	"sync"
+	"testing"
+
+	"github.com/danvixent/pgxtls/config"
+	"github.com/jackc/pgx/v4"
+)
+
+// logEntry is a message a logRecorder received
+type logEntry struct {
+	level pgx.LogLevel
+	msg   string
+	data  map[string]interface{}
+}
+
+// logRecorder is a pgx.Logger keeping what is logged
+type logRecorder struct {
+	mu      sync.Mutex
+	entries []logEntry
+}
+
+func (r *logRecorder) Log(_ context.Context, level pgx.LogLevel, msg string, data map[string]interface{}) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.entries = append(r.entries, logEntry{level: level, msg: msg, data: data})
+}
+
+// find returns the entries logged with msg
+func (r *logRecorder) find(msg string) []logEntry {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var found []logEntry
+	for _, e := range r.entries {
+		if e.msg == msg {
+			found = append(found, e)
+		}
+	}
+	return found
+}
+
+func TestTLSVersionName(t *testing.T) {
+	tests := []struct {
+		version uint16
+		want    string
+	}{
+		{tls.VersionTLS12, "TLS 1.2"},
+		{tls.VersionTLS13, "TLS 1.3"},
+		{0x0300, "0x0300"},
+	}
+	for _, tt := range tests {
+		if got := tlsVersionName(tt.version); got != tt.want {
+			t.Errorf("tlsVersionName(%#x) = %q, want %q", tt.version, got, tt.want)
+		}
+	}
+}
+
+func TestSSLDebugLogsHandshake(t *testing.T) {
+	ca := newTestCA(t)
+	certPEM, keyPEM := ca.issue(t, serverTemplate("db.example.com"))
+	clientCertPEM, clientKeyPEM := ca.issue(t, clientTemplate("user"))
+
+	tests := []struct {
+		name  string
+		debug bool
+		want  int
+	}{
+		{"debug", true, 1},
+		{"quiet", false, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			c := dsnConfig()
+			c.SSLCertFile = writeFile(t, dir, "client.crt", clientCertPEM)
+			c.SSLKeyFile = writeFile(t, dir, "client.key", clientKeyPEM)
+			c.SSLCAFile = writeFile(t, dir, "ca.crt", ca.pem)
+			c.SSLMode, c.SSLDebug = config.SSLModeVerifyFull, tt.debug
+
+			logs := &logRecorder{}
+			tlsCfg, err := newTLSConfig(context.Background(), c, newOptions([]Option{WithLogger(logs)}))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := handshake(t, forHost(tlsCfg, "db.example.com"), certPEM, keyPEM); err != nil {
+				t.Fatal(err)
+			}
+
+			entries := logs.find("tls handshake complete")
+			if len(entries) != tt.want {
+				t.Fatalf("logged %d handshakes, want %d", len(entries), tt.want)
+			}
+			if tt.want == 0 {
+				return
+			}
+			data := entries[0].data
+			if data["version"] != "TLS 1.3" || data["server_name"] != "db.example.com" || data["peer_subject"] != "CN=db.example.com" {
+				t.Fatalf("logged %v", data)
+			}
+		})
+	}
+}
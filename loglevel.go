@@ -0,0 +1,36 @@
+package pgxtls
+
+import (
+	"context"
+	"sync/atomic"
+
+	"github.com/jackc/pgx/v4"
+)
+
+// levelLogger passes on the log events at its level or more severe
+type levelLogger struct {
+	logger pgx.Logger
+	level  int32
+}
+
+func (l *levelLogger) Log(ctx context.Context, level pgx.LogLevel, msg string, data map[string]interface{}) {
+	if level > pgx.LogLevel(atomic.LoadInt32(&l.level)) {
+		return
+	}
+	l.logger.Log(ctx, level, msg, data)
+}
+
+// setLevel changes the level of l, taking effect on connections already open
+func (l *levelLogger) setLevel(level pgx.LogLevel) {
+	atomic.StoreInt32(&l.level, int32(level))
+}
+
+// WithPgxLogging writes pgx's own log events at level or more severe to the
+// logger set by WithLogger. The function returned with the Option sets the
+// level at any time, such as to trace a live system without restarting it
+func WithPgxLogging(level pgx.LogLevel) (Option, func(level pgx.LogLevel)) {
+	l := &levelLogger{level: int32(level)}
+	return func(o *options) {
+		o.pgxLog = l
+	}, l.setLevel
+}
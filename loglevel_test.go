@@ -0,0 +1,87 @@
+package pgxtls
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jackc/pgx/v4"
+)
+
+func TestLevelLogger(t *testing.T) {
+	tests := []struct {
+		name   string
+		level  pgx.LogLevel
+		passed []pgx.LogLevel
+	}{
+		{"error", pgx.LogLevelError, []pgx.LogLevel{pgx.LogLevelError}},
+		{"info", pgx.LogLevelInfo, []pgx.LogLevel{pgx.LogLevelError, pgx.LogLevelWarn, pgx.LogLevelInfo}},
+		{"none", pgx.LogLevelNone, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logs := &logRecorder{}
+			l := &levelLogger{logger: logs, level: int32(tt.level)}
+			for _, level := range []pgx.LogLevel{pgx.LogLevelError, pgx.LogLevelWarn, pgx.LogLevelInfo, pgx.LogLevelDebug, pgx.LogLevelTrace} {
+				l.Log(context.Background(), level, "event", nil)
+			}
+
+			if len(logs.entries) != len(tt.passed) {
+				t.Fatalf("passed %v, want %v", logs.entries, tt.passed)
+			}
+			for i, e := range logs.entries {
+				if e.level != tt.passed[i] {
+					t.Fatalf("passed %v, want %v", logs.entries, tt.passed)
+				}
+			}
+		})
+	}
+}
+
+func TestWithPgxLogging(t *testing.T) {
+	srv := newFakeServer(t)
+	logs := &logRecorder{}
+	slow := &logRecorder{}
+	ctx := context.Background()
+
+	opt, setLevel := WithPgxLogging(pgx.LogLevelInfo)
+	p, err := NewFromCfgMap(ctx, srv.configMap(t), nil, WithLogger(logs), opt, func(o *options) {
+		o.queryLoggers = append(o.queryLoggers, slow)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	conn, err := p.Acquire(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Release()
+
+	tests := []struct {
+		name     string
+		level    pgx.LogLevel
+		wantLogs bool
+	}{
+		{"info", pgx.LogLevelInfo, true},
+		{"lowered on an open connection", pgx.LogLevelError, false},
+		{"raised again", pgx.LogLevelDebug, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setLevel(tt.level)
+			before, slowBefore := len(logs.find("Exec")), len(slow.find("Exec"))
+			if _, err := conn.Exec(ctx, "VACUUM"); err != nil {
+				t.Fatal(err)
+			}
+
+			if logged := len(logs.find("Exec")) > before; logged != tt.wantLogs {
+				t.Fatalf("Exec logged %v, want %v", logged, tt.wantLogs)
+			}
+			// the pool's own query loggers see every query regardless
+			if len(slow.find("Exec")) != slowBefore+1 {
+				t.Fatal("query logger missed the Exec")
+			}
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package pgxtls
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"github.com/jackc/pgx/v4"
+)
+
+// QueryMetrics counts the queries run on the connections of the pools it's
+// installed on with WithQueryMetrics
+type QueryMetrics struct {
+	mu          sync.Mutex
+	queries     int64
+	errors      int64
+	byOperation map[string]int64
+}
+
+// QueryMetricsSnapshot is a copy of the counts of a QueryMetrics
+type QueryMetricsSnapshot struct {
+	Queries     int64            `json:"queries"`
+	Errors      int64            `json:"errors"`
+	ByOperation map[string]int64 `json:"by_operation"` // keyed by the sql command, such as SELECT
+}
+
+// NewQueryMetrics returns a QueryMetrics with every count at zero
+func NewQueryMetrics() *QueryMetrics {
+	return &QueryMetrics{byOperation: make(map[string]int64)}
+}
+
+// WithQueryMetrics counts the queries of the pool in m
+func WithQueryMetrics(m *QueryMetrics) Option {
+	return func(o *options) {
+		o.queryLoggers = append(o.queryLoggers, m)
+	}
+}
+
+// Snapshot returns the current counts of m
+func (m *QueryMetrics) Snapshot() QueryMetricsSnapshot {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s := QueryMetricsSnapshot{
+		Queries:     m.queries,
+		Errors:      m.errors,
+		ByOperation: make(map[string]int64, len(m.byOperation)),
+	}
+	for op, n := range m.byOperation {
+		s.ByOperation[op] = n
+	}
+	return s
+}
+
+// Log counts the Query, Exec, SendBatch and CopyFrom events pgx emits
+func (m *QueryMetrics) Log(_ context.Context, level pgx.LogLevel, msg string, data map[string]interface{}) {
+	var op string
+	switch msg {
+	case "Query", "Exec":
+		sql, _ := data["sql"].(string)
+		op = operation(sql)
+	case "SendBatch":
+		op = "BATCH"
+	case "CopyFrom":
+		op = "COPY"
+	default:
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.queries++
+	m.byOperation[op]++
+	if level == pgx.LogLevelError {
+		m.errors++
+	}
+}
+
+// operation returns the command sql starts with, such as SELECT
+func operation(sql string) string {
+	fields := strings.Fields(sql)
+	if len(fields) == 0 {
+		return "UNKNOWN"
+	}
+	return strings.ToUpper(strings.TrimRight(fields[0], ";"))
+}
@@ -0,0 +1,85 @@
+package pgxtls
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jackc/pgproto3/v2"
+	"github.com/jackc/pgx/v4"
+)
+
+func TestOperation(t *testing.T) {
+	tests := []struct {
+		sql, want string
+	}{
+		{"SELECT 1", "SELECT"},
+		{"  insert into jobs values (1)", "INSERT"},
+		{"vacuum;", "VACUUM"},
+		{"\n\tWITH x AS (SELECT 1) SELECT * FROM x", "WITH"},
+		{"", "UNKNOWN"},
+		{"   ", "UNKNOWN"},
+	}
+	for _, tt := range tests {
+		if got := operation(tt.sql); got != tt.want {
+			t.Errorf("operation(%q) = %q, want %q", tt.sql, got, tt.want)
+		}
+	}
+}
+
+func TestQueryMetricsLog(t *testing.T) {
+	m := NewQueryMetrics()
+	ctx := context.Background()
+	for _, e := range []struct {
+		level pgx.LogLevel
+		msg   string
+		sql   string
+	}{
+		{pgx.LogLevelInfo, "Query", "SELECT 1"},
+		{pgx.LogLevelInfo, "Query", "select 2"},
+		{pgx.LogLevelError, "Exec", "UPDATE jobs SET done = true"},
+		{pgx.LogLevelInfo, "SendBatch", ""},
+		{pgx.LogLevelInfo, "CopyFrom", ""},
+		{pgx.LogLevelInfo, "Dialing PostgreSQL server", ""},
+	} {
+		m.Log(ctx, e.level, e.msg, map[string]interface{}{"sql": e.sql})
+	}
+
+	s := m.Snapshot()
+	want := map[string]int64{"SELECT": 2, "UPDATE": 1, "BATCH": 1, "COPY": 1}
+	if s.Queries != 5 || s.Errors != 1 || len(s.ByOperation) != len(want) {
+		t.Fatalf("Snapshot() = %+v", s)
+	}
+	for op, n := range want {
+		if s.ByOperation[op] != n {
+			t.Errorf("%s = %d, want %d", op, s.ByOperation[op], n)
+		}
+	}
+
+	// snapshots are copies
+	s.ByOperation["SELECT"] = 100
+	if m.Snapshot().ByOperation["SELECT"] != 2 {
+		t.Fatal("changing a snapshot changed the metrics")
+	}
+}
+
+func TestWithQueryMetrics(t *testing.T) {
+	srv := newFakeServer(t)
+	srv.fail["DELETE FROM jobs"] = &pgproto3.ErrorResponse{Severity: "ERROR", Code: "42501", Message: "permission denied"}
+	m := NewQueryMetrics()
+	ctx := context.Background()
+
+	p, err := NewFromCfgMap(ctx, srv.configMap(t), nil, WithQueryMetrics(m))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	for _, sql := range []string{"INSERT INTO jobs VALUES (1)", "INSERT INTO jobs VALUES (2)", "DELETE FROM jobs"} {
+		p.Exec(ctx, sql)
+	}
+
+	s := m.Snapshot()
+	if s.Queries != 3 || s.Errors != 1 || s.ByOperation["INSERT"] != 2 || s.ByOperation["DELETE"] != 1 {
+		t.Fatalf("Snapshot() = %+v", s)
+	}
+}
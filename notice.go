@@ -0,0 +1,41 @@
+package pgxtls
+
+import (
+	"context"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+)
+
+// WithNoticeHandler calls fn with every NOTICE, WARNING and other
+// asynchronous message the server sends on a connection of the pool.
+// Without it these messages are logged
+func WithNoticeHandler(fn pgconn.NoticeHandler) Option {
+	return func(o *options) {
+		o.notice = fn
+	}
+}
+
+// logNotice returns a notice handler writing each notice to logger,
+// WARNINGs at warn level and everything else at info level
+func logNotice(logger pgx.Logger) pgconn.NoticeHandler {
+	return func(c *pgconn.PgConn, n *pgconn.Notice) {
+		var level pgx.LogLevel = pgx.LogLevelInfo
+		if n.Severity == "WARNING" {
+			level = pgx.LogLevelWarn
+		}
+
+		data := map[string]interface{}{
+			"severity": n.Severity,
+			"code":     n.Code,
+			"pid":      c.PID(),
+		}
+		if n.Detail != "" {
+			data["detail"] = n.Detail
+		}
+		if n.Hint != "" {
+			data["hint"] = n.Hint
+		}
+		logger.Log(context.Background(), level, n.Message, data)
+	}
+}
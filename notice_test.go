@@ -0,0 +1,85 @@
+package pgxtls
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgproto3/v2"
+	"github.com/jackc/pgx/v4"
+)
+
+func TestLogNotice(t *testing.T) {
+	srv := newFakeServer(t)
+	srv.notice["DROP TABLE IF EXISTS jobs"] = &pgproto3.NoticeResponse{Severity: "NOTICE", Code: "00000", Message: `table "jobs" does not exist, skipping`}
+	srv.notice["COMMIT"] = &pgproto3.NoticeResponse{Severity: "WARNING", Code: "25P01", Message: "there is no transaction in progress", Hint: "begin one", Detail: "none open"}
+	ctx := context.Background()
+
+	tests := []struct {
+		sql       string
+		wantLevel pgx.LogLevel
+		wantMsg   string
+		wantData  map[string]interface{}
+	}{
+		{"DROP TABLE IF EXISTS jobs", pgx.LogLevelInfo, `table "jobs" does not exist, skipping`, map[string]interface{}{"severity": "NOTICE", "code": "00000"}},
+		{"COMMIT", pgx.LogLevelWarn, "there is no transaction in progress", map[string]interface{}{"severity": "WARNING", "detail": "none open", "hint": "begin one"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.sql, func(t *testing.T) {
+			logs := &logRecorder{}
+			p, err := NewFromCfgMap(ctx, srv.configMap(t), nil, WithLogger(logs))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer p.Close()
+
+			conn, err := p.Acquire(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Release()
+			if _, err := conn.Exec(ctx, tt.sql); err != nil {
+				t.Fatal(err)
+			}
+
+			entries := logs.find(tt.wantMsg)
+			if len(entries) != 1 || entries[0].level != tt.wantLevel {
+				t.Fatalf("logged %v", logs.entries)
+			}
+			if entries[0].data["pid"] != conn.Conn().PgConn().PID() {
+				t.Fatalf("logged pid %v", entries[0].data["pid"])
+			}
+			for k, v := range tt.wantData {
+				if entries[0].data[k] != v {
+					t.Errorf("%s = %v, want %v", k, entries[0].data[k], v)
+				}
+			}
+		})
+	}
+}
+
+func TestWithNoticeHandler(t *testing.T) {
+	srv := newFakeServer(t)
+	srv.notice["VACUUM"] = &pgproto3.NoticeResponse{Severity: "WARNING", Message: "skipping jobs"}
+	logs := &logRecorder{}
+	ctx := context.Background()
+
+	var got []string
+	p, err := NewFromCfgMap(ctx, srv.configMap(t), nil, WithLogger(logs), WithNoticeHandler(func(_ *pgconn.PgConn, n *pgconn.Notice) {
+		got = append(got, n.Message)
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	if _, err := p.Exec(ctx, "VACUUM"); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0] != "skipping jobs" {
+		t.Fatalf("handled %v", got)
+	}
+	if len(logs.find("skipping jobs")) != 0 {
+		t.Fatal("notice logged as well as handled")
+	}
+}
@@ -0,0 +1,152 @@
+package pgxtls
+
+import (
+	"context"
+	"crypto/x509"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+	pool "github.com/jackc/pgx/v4/pgxpool"
+)
+
+// Option configures behaviour of a pool that can't be expressed in a ConfigMap
+type Option func(*options)
+
+type options struct {
+	logger        pgx.Logger
+	passwordFunc  PasswordFunc
+	cloudSQL      *cloudSQL
+	slowQuery     *slowQueryLogger
+	rootCAs       *x509.CertPool
+	expiryWarning time.Duration
+	hostSelector  HostSelector
+	secrets       SecretSource
+	backoff       BackoffStrategy
+	proxyProtocol int
+	queryLoggers  []pgx.Logger
+	verifyPeer    leafCheck
+	explain       *explainLogger
+	pids          *PIDTracker
+	notice        pgconn.NoticeHandler
+	connEvents    *connEvents
+	pgxLog        *levelLogger
+	tlsProvider   TLSConfigProvider
+	tlsUpgrade    TLSUpgradeFunc
+}
+
+func newOptions(opts []Option) *options {
+	o := &options{
+		logger:  stdLogger{},
+		backoff: FullJitterBackoff(100*time.Millisecond, 30*time.Second),
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// validate rejects options that would only fail once connecting
+func (o *options) validate() error {
+	if o.proxyProtocol != 0 && o.proxyProtocol != 1 && o.proxyProtocol != 2 {
+		return fmt.Errorf("unsupported proxy protocol version %d, must be 1 or 2", o.proxyProtocol)
+	}
+	return nil
+}
+
+// apply installs the hooks the options call for on cfg
+func (o *options) apply(cfg *pool.Config) {
+	if o.passwordFunc != nil {
+		cfg.BeforeConnect = beforeConnect(o.passwordFunc)
+	}
+
+	if o.pids != nil {
+		o.pids.install(cfg)
+	}
+
+	if o.tlsProvider != nil {
+		provideTLS(cfg, o.tlsProvider)
+	}
+
+	if o.connEvents != nil {
+		o.connEvents.install(cfg)
+	}
+
+	if o.notice != nil {
+		cfg.ConnConfig.OnNotice = o.notice
+	} else {
+		cfg.ConnConfig.OnNotice = logNotice(o.logger)
+	}
+
+	if o.proxyProtocol != 0 {
+		cfg.ConnConfig.DialFunc = proxyDialer(cfg.ConnConfig.DialFunc, o.proxyProtocol)
+	}
+
+	if o.hostSelector != nil {
+		selectHosts(cfg, o.hostSelector)
+	}
+
+	var loggers multiLogger
+	if o.slowQuery != nil {
+		o.slowQuery.logger = o.logger
+		loggers = append(loggers, o.slowQuery)
+	}
+	loggers = append(loggers, o.queryLoggers...)
+	if o.explain != nil {
+		o.explain.logger = o.logger
+		loggers = append(loggers, o.explain)
+	}
+
+	switch {
+	case o.pgxLog != nil:
+		// pgx fixes the level of a connection when it is opened, so it
+		// emits everything and the loggers filter for themselves
+		o.pgxLog.logger = o.logger
+		logger := pgx.Logger(o.pgxLog)
+		if len(loggers) > 0 {
+			logger = multiLogger{&levelLogger{logger: loggers, level: int32(pgx.LogLevelInfo)}, o.pgxLog}
+		}
+		cfg.ConnConfig.Logger = logger
+		cfg.ConnConfig.LogLevel = pgx.LogLevelTrace
+	case len(loggers) > 0:
+		cfg.ConnConfig.Logger = loggers
+		cfg.ConnConfig.LogLevel = pgx.LogLevelInfo
+	}
+}
+
+// WithLogger sets the logger this package writes its own diagnostics to,
+// by default they are written to the standard library logger
+func WithLogger(logger pgx.Logger) Option {
+	return func(o *options) {
+		o.logger = logger
+	}
+}
+
+// PasswordFunc returns the password to authenticate a new connection with
+type PasswordFunc func(context.Context) (string, error)
+
+// WithPasswordFunc calls fn for a fresh password before every new connection
+// is made, replacing ConfigMap.Password. This suits short-lived credentials
+// such as cloud IAM auth tokens
+func WithPasswordFunc(fn PasswordFunc) Option {
+	return func(o *options) {
+		o.passwordFunc = fn
+	}
+}
+
+// WithRootCAs trusts the certificate authorities in certs instead of
+// those in ConfigMap.SSLCAFile, which is then not read
+func WithRootCAs(certs *x509.CertPool) Option {
+	return func(o *options) {
+		o.rootCAs = certs
+	}
+}
+
+// WithCertExpiryWarning logs a warning when the client
+// certificate expires within window of the pool being created
+func WithCertExpiryWarning(window time.Duration) Option {
+	return func(o *options) {
+		o.expiryWarning = window
+	}
+}
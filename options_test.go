@@ -0,0 +1,145 @@
+package pgxtls
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+	pool "github.com/jackc/pgx/v4/pgxpool"
+)
+
+func TestNewOptions(t *testing.T) {
+	logs := &logRecorder{}
+	tests := []struct {
+		name       string
+		opts       []Option
+		wantLogger pgx.Logger
+	}{
+		{name: "defaults", wantLogger: stdLogger{}},
+		{name: "WithLogger", opts: []Option{WithLogger(logs)}, wantLogger: logs},
+		{name: "last wins", opts: []Option{WithLogger(stdLogger{}), WithLogger(logs)}, wantLogger: logs},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := newOptions(tt.opts)
+			if o.logger != tt.wantLogger {
+				t.Fatalf("logger = %#v, want %#v", o.logger, tt.wantLogger)
+			}
+			if o.backoff == nil {
+				t.Fatal("no default backoff")
+			}
+		})
+	}
+}
+
+func TestOptionsApply(t *testing.T) {
+	pgxLog, _ := WithPgxLogging(pgx.LogLevelWarn)
+	tests := []struct {
+		name          string
+		opts          []Option
+		wantLogger    bool
+		wantLogLevel  pgx.LogLevel
+		wantOwnNotice bool
+	}{
+		{name: "defaults", wantLogLevel: pgx.LogLevelNone},
+		{name: "slow queries", opts: []Option{WithSlowQueryLog(time.Second, true)}, wantLogger: true, wantLogLevel: pgx.LogLevelInfo},
+		{name: "pgx logging", opts: []Option{pgxLog}, wantLogger: true, wantLogLevel: pgx.LogLevelTrace},
+		{name: "pgx logging and slow queries", opts: []Option{pgxLog, WithSlowQueryLog(time.Second, true)}, wantLogger: true, wantLogLevel: pgx.LogLevelTrace},
+		{name: "notice handler", opts: []Option{WithNoticeHandler(func(*pgconn.PgConn, *pgconn.Notice) {})}, wantLogLevel: pgx.LogLevelNone, wantOwnNotice: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := pool.ParseConfig("postgres://user@localhost/db?sslmode=disable")
+			if err != nil {
+				t.Fatal(err)
+			}
+			cfg.ConnConfig.LogLevel = pgx.LogLevelNone
+			cfg.ConnConfig.Logger = nil
+			logs := &logRecorder{}
+			newOptions(append([]Option{WithLogger(logs)}, tt.opts...)).apply(cfg)
+
+			if (cfg.ConnConfig.Logger != nil) != tt.wantLogger || cfg.ConnConfig.LogLevel != tt.wantLogLevel {
+				t.Fatalf("pgx logs to %T at %v", cfg.ConnConfig.Logger, cfg.ConnConfig.LogLevel)
+			}
+			if cfg.ConnConfig.OnNotice == nil {
+				t.Fatal("notices aren't handled")
+			}
+
+			// notices go to the logger unless a handler was given
+			cfg.ConnConfig.OnNotice(&pgconn.PgConn{}, &pgconn.Notice{Severity: "WARNING", Message: "deprecated"})
+			if logged := len(logs.entries) > 0; logged == tt.wantOwnNotice {
+				t.Fatalf("notice logged %v with own handler %v", logged, tt.wantOwnNotice)
+			}
+		})
+	}
+}
+
+func TestOptionsApplyChainsBeforeConnect(t *testing.T) {
+	cfg, err := pool.ParseConfig("postgres://user@localhost/db?sslmode=require")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	provided := false
+	o := newOptions([]Option{
+		WithPasswordFunc(func(context.Context) (string, error) { return "token", nil }),
+		WithTLSConfigProvider(func(_ context.Context, _ string, base *tls.Config) (*tls.Config, error) {
+			provided = true
+			return base, nil
+		}),
+	})
+	o.apply(cfg)
+
+	cc := cfg.ConnConfig.Copy()
+	if err := cfg.BeforeConnect(context.Background(), cc); err != nil {
+		t.Fatal(err)
+	}
+	if cc.Password != "token" || !provided {
+		t.Fatalf("password %q, tls config provided %v, want both hooks run", cc.Password, provided)
+	}
+}
+
+func TestWithRootCAs(t *testing.T) {
+	tests := []struct {
+		name    string
+		trust   string
+		wantErr bool
+	}{
+		{name: "server's ca", trust: "server"},
+		{name: "other ca", trust: "other", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newFakeServer(t)
+			c := tlsConfigMap(t, srv)
+			roots := x509.NewCertPool()
+			if tt.trust == "server" {
+				roots.AppendCertsFromPEM(mustRead(t, c.SSLCAFile))
+			} else {
+				roots.AddCert(newTestCA(t).cert)
+			}
+			// the ca file isn't read with WithRootCAs
+			c.SSLCAFile = filepath.Join(t.TempDir(), "missing.crt")
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			p, err := NewFromCfgMap(ctx, c, nil, WithRootCAs(roots))
+			if tt.wantErr {
+				if err == nil {
+					p.Close()
+					t.Fatal("connected to a server signed by an untrusted ca")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			p.Close()
+		})
+	}
+}
@@ -0,0 +1,127 @@
+package pgxtls
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	pool "github.com/jackc/pgx/v4/pgxpool"
+)
+
+// PIDTracker records the backend PIDs of the open connections of the
+// pools created WithPIDTracking, idle or acquired, to help chase leaks
+type PIDTracker struct {
+	mu sync.Mutex
+	// open counts the connections with each PID, pools
+	// of different servers may have backends sharing one
+	open map[uint32]int
+	// pool is set on the tracker of a single pool, which is
+	// in trackedPools while the pool has open connections
+	pool *pool.Pool
+}
+
+// trackedPools holds the tracker of each pool created WithPIDTracking
+// that has open connections, closed pools have none and are dropped
+var trackedPools = struct {
+	sync.Mutex
+	m map[*pool.Pool]*PIDTracker
+}{m: make(map[*pool.Pool]*PIDTracker)}
+
+// NewPIDTracker returns a PIDTracker without connections
+func NewPIDTracker() *PIDTracker {
+	return &PIDTracker{open: make(map[uint32]int)}
+}
+
+// WithPIDTracking records the backend PID of each connection of the pool
+// in t from when it is established until it is closed. ActivePIDs of
+// the pool then reports its acquired connections as well as the idle
+func WithPIDTracking(t *PIDTracker) Option {
+	return func(o *options) {
+		o.pids = t
+	}
+}
+
+// install hooks t into the connects and closes of cfg's connections
+func (t *PIDTracker) install(cfg *pool.Config) {
+	events := &connEvents{onConnect: t.add, onDisconnect: t.remove}
+	events.install(cfg)
+}
+
+func (t *PIDTracker) add(pid uint32) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.open[pid]++
+	t.publish()
+}
+
+func (t *PIDTracker) remove(pid uint32) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.open[pid]--; t.open[pid] <= 0 {
+		delete(t.open, pid)
+	}
+	t.publish()
+}
+
+// attach makes t the tracker of p, reported by ActivePIDs
+func (t *PIDTracker) attach(p *pool.Pool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.pool = p
+	t.publish()
+}
+
+// publish registers the pool of t in trackedPools while it has
+// open connections. The caller holds t.mu
+func (t *PIDTracker) publish() {
+	if t.pool == nil {
+		return
+	}
+
+	trackedPools.Lock()
+	defer trackedPools.Unlock()
+	if len(t.open) > 0 {
+		trackedPools.m[t.pool] = t
+	} else {
+		delete(trackedPools.m, t.pool)
+	}
+}
+
+// ActivePIDs returns the sorted backend PIDs of the open connections
+func (t *PIDTracker) ActivePIDs() []uint32 {
+	t.mu.Lock()
+	pids := make([]uint32, 0, len(t.open))
+	for pid := range t.open {
+		pids = append(pids, pid)
+	}
+	t.mu.Unlock()
+
+	sortPIDs(pids)
+	return pids
+}
+
+// ActivePIDs returns the sorted backend PIDs of the open connections
+// of p. Pools created WithPIDTracking report their idle and acquired
+// connections, other pools only the idle ones
+func ActivePIDs(p *pool.Pool) []uint32 {
+	trackedPools.Lock()
+	t := trackedPools.m[p]
+	trackedPools.Unlock()
+	if t != nil {
+		return t.ActivePIDs()
+	}
+
+	conns := p.AcquireAllIdle(context.Background())
+	pids := make([]uint32, 0, len(conns))
+	for _, conn := range conns {
+		pids = append(pids, conn.Conn().PgConn().PID())
+		conn.Release()
+	}
+
+	sortPIDs(pids)
+	return pids
+}
+
+func sortPIDs(pids []uint32) {
+	sort.Slice(pids, func(i, j int) bool { return pids[i] < pids[j] })
+}
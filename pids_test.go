@@ -0,0 +1,123 @@
+package pgxtls
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	pool "github.com/jackc/pgx/v4/pgxpool"
+)
+
+func TestPIDTracker(t *testing.T) {
+	srv := newFakeServer(t)
+	tracker := NewPIDTracker()
+
+	cfg := srv.poolConfig(t)
+	newOptions([]Option{WithPIDTracking(tracker)}).apply(cfg)
+
+	ctx := context.Background()
+	p, err := pool.ConnectConfig(ctx, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	acquired, err := p.Acquire(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idle, err := p.Acquire(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	acquiredPID, idlePID := acquired.Conn().PgConn().PID(), idle.Conn().PgConn().PID()
+	idle.Release()
+
+	want := []uint32{acquiredPID, idlePID}
+	if acquiredPID > idlePID {
+		want = []uint32{idlePID, acquiredPID}
+	}
+	if got := tracker.ActivePIDs(); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("ActivePIDs() = %v, want %v", got, want)
+	}
+	if total := p.Stat().TotalConns(); total != 2 {
+		t.Fatalf("pool has %d connections after ActivePIDs, want 2", total)
+	}
+
+	acquired.Release()
+	p.Close()
+	waitFor(t, func() bool { return len(tracker.ActivePIDs()) == 0 })
+}
+
+func TestActivePIDs(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []Option
+		// wantAcquired is whether the acquired connection is reported
+		wantAcquired bool
+	}{
+		{name: "tracked", opts: []Option{WithPIDTracking(NewPIDTracker())}, wantAcquired: true},
+		{name: "untracked", wantAcquired: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newFakeServer(t)
+			ctx := context.Background()
+			p, err := NewFromCfgMap(ctx, srv.configMap(t), nil, tt.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer p.Close()
+
+			acquired, err := p.Acquire(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			idle, err := p.Acquire(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			acquiredPID, idlePID := acquired.Conn().PgConn().PID(), idle.Conn().PgConn().PID()
+			idle.Release()
+
+			want := []uint32{idlePID}
+			if tt.wantAcquired {
+				want = []uint32{acquiredPID, idlePID}
+				if acquiredPID > idlePID {
+					want = []uint32{idlePID, acquiredPID}
+				}
+			}
+			if got := ActivePIDs(p); fmt.Sprint(got) != fmt.Sprint(want) {
+				t.Fatalf("ActivePIDs() = %v, want %v", got, want)
+			}
+			if total := p.Stat().TotalConns(); total != 2 {
+				t.Fatalf("pool has %d connections after ActivePIDs, want 2", total)
+			}
+
+			acquired.Release()
+			p.Close()
+			waitFor(t, func() bool {
+				trackedPools.Lock()
+				defer trackedPools.Unlock()
+				_, ok := trackedPools.m[p]
+				return !ok
+			})
+			if got := ActivePIDs(p); len(got) != 0 {
+				t.Fatalf("ActivePIDs() of a closed pool = %v, want none", got)
+			}
+		})
+	}
+}
+
+// waitFor fails t unless cond becomes true within a few seconds
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); !cond(); {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met in time")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
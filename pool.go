@@ -2,16 +2,22 @@ package pgxtls
 
 import (
 	"context"
+	"crypto"
 	"crypto/tls"
 	"crypto/x509"
 	"encoding/pem"
 	"errors"
 	"fmt"
-	"io/ioutil"
 	"net"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
 	"time"
+	"unicode"
 
 	"github.com/danvixent/pgxtls/config"
+	"github.com/jackc/pgconn"
 	"github.com/jackc/pgx/v4"
 	pool "github.com/jackc/pgx/v4/pgxpool"
 )
@@ -19,97 +25,780 @@ import (
 type AfterConnectFunc func(context.Context, *pgx.Conn) error
 
 // NewFromCfgMap Returns a new database initialized with credentials from config
-func NewFromCfgMap(ctx context.Context, config *config.ConfigMap, fn AfterConnectFunc) (*pool.Pool, error) {
-
-	const format = "postgres://%s:%s@%s:%d/%s?sslmode=%s&pool_max_conns=%d"
-	uri := fmt.Sprintf(
-		format, config.DbUser,
-		config.Password, config.DbHost,
-		config.DbPort, config.DbName,
-		config.SSLMode, config.MaxConns,
-	)
+func NewFromCfgMap(ctx context.Context, config *config.ConfigMap, fn AfterConnectFunc, opts ...Option) (*pool.Pool, error) {
+	o := cfgMapOptions(config, opts)
+	cfg, err := sizedPoolConfig(ctx, config, fn, o)
+	if err != nil {
+		return nil, err
+	}
+	return connectPool(ctx, cfg, o)
+}
 
-	cfg, err := pool.ParseConfig(uri)
+// cfgMapOptions returns the options of a pool for config
+func cfgMapOptions(config *config.ConfigMap, opts []Option) *options {
+	o := newOptions(opts)
+	if config.DevExplain {
+		o.explain = &explainLogger{}
+	}
+	return o
+}
+
+// sizedPoolConfig returns the pool configuration described by config
+// with its max connections final, sized by the server if config asks
+func sizedPoolConfig(ctx context.Context, config *config.ConfigMap, fn AfterConnectFunc, o *options) (*pool.Config, error) {
+	cfg, err := newPoolConfig(ctx, config, fn, o)
 	if err != nil {
 		return nil, err
 	}
 
-	cfg.AfterConnect = fn
-	cfg.ConnConfig.DialFunc = func(ctx context.Context, host string, addr string) (net.Conn, error) {
-		return net.Dial(host, addr)
+	if config.MaxConnsPercent > 0 {
+		if err = sizeByServer(ctx, cfg, config.MaxConnsPercent); err != nil {
+			return nil, err
+		}
 	}
+	return cfg, nil
+}
 
-	cfg.ConnConfig.PreferSimpleProtocol = true
-	cfg.ConnConfig.ConnectTimeout = time.Minute
+// connectPool connects the pool of cfg, built with o
+func connectPool(ctx context.Context, cfg *pool.Config, o *options) (*pool.Pool, error) {
+	var pids *PIDTracker
+	if o.pids != nil {
+		// o.pids may be shared by pools, ActivePIDs needs one per pool
+		pids = NewPIDTracker()
+		pids.install(cfg)
+	}
+
+	pool, err := pool.ConnectConfig(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	if pids != nil {
+		pids.attach(pool)
+	}
+
+	if o.explain != nil {
+		o.explain.setQuerier(pool)
+	}
+
+	return pool, nil
+}
+
+// newPoolConfig assembles the pool configuration described by config
+func newPoolConfig(ctx context.Context, config *config.ConfigMap, fn AfterConnectFunc, o *options) (*pool.Config, error) {
+
+	if o.cloudSQL != nil {
+		if err := checkCloudSQL(config, o); err != nil {
+			return nil, err
+		}
+	}
+
+	dsn := buildDSN(config)
+	if err := checkDSN(config, dsn); err != nil {
+		return nil, err
+	}
 
-	var xPool *x509.CertPool
+	cfg, err := pool.ParseConfig(dsn)
+	if err != nil {
+		return nil, attributeParseError(config, err)
+	}
 
-	if config.SSLCAFile == "" {
-		xPool, err = x509.SystemCertPool()
-		return nil, fmt.Errorf("unable to retrieve system cert pool: %v", err)
+	pgxDial := cfg.ConnConfig.DialFunc
+	configure(cfg, fn)
+	if config.UseDefaultPgxDialer {
+		cfg.ConnConfig.DialFunc = pgxDial
+	}
+	if config.LocalAddr != "" {
+		dialer, err := localDialer(config.LocalAddr)
+		if err != nil {
+			return nil, err
+		}
+		cfg.ConnConfig.DialFunc = dialer.DialContext
+	}
+	if config.DialNetwork != "" {
+		cfg.ConnConfig.DialFunc = withNetwork(cfg.ConnConfig.DialFunc, config.DialNetwork)
+	}
+	if err := o.validate(); err != nil {
+		return nil, err
+	}
+	o.apply(cfg)
+
+	if config.NoConnectTimeout {
+		noConnectTimeout(cfg)
+	}
+
+	setExecMode(cfg.ConnConfig, config)
+	if config.MaxConns == 0 {
+		cfg.MaxConns = AutoMaxConns()
+		// pools sized by the server are checked once they are
+		if config.MaxConnsPercent == 0 && cfg.MinConns > cfg.MaxConns {
+			return nil, fmt.Errorf("MinConns (%d) must not exceed MaxConns (%d), sized from GOMAXPROCS", cfg.MinConns, cfg.MaxConns)
+		}
+	}
+
+	if len(config.PrepareStatements) > 0 {
+		cfg.AfterConnect = chainAfterConnect(prepareStatements(config.PrepareStatements), cfg.AfterConnect)
+	}
+
+	if config.MinServerVersion != "" {
+		check, err := requireServerVersion(config.MinServerVersion)
+		if err != nil {
+			return nil, err
+		}
+		cfg.AfterConnect = chainAfterConnect(check, cfg.AfterConnect)
+	}
+
+	if config.ConnectRole != "" {
+		cfg.AfterConnect = chainAfterConnect(setRole(config.ConnectRole), cfg.AfterConnect)
+	}
+
+	if o.cloudSQL != nil {
+		useCloudSQL(cfg, o.cloudSQL)
 	} else {
-		CAcert, err := ioutil.ReadFile(config.SSLCAFile)
+		tlsConfig, err := newTLSConfig(ctx, config, o)
 		if err != nil {
 			return nil, err
 		}
+		setTLSConfig(cfg, tlsConfig)
+	}
+
+	if o.tlsUpgrade != nil {
+		if !config.UsesTLS() {
+			return nil, fmt.Errorf("WithTLSUpgrade needs an sslmode of require, verify-ca or verify-full, not %s", config.SSLMode)
+		}
+		upgradeTLS(cfg, o.tlsUpgrade)
+	}
+
+	if config.SSLHandshakeTimeout > 0 {
+		limitHandshake(cfg, config.SSLHandshakeTimeout)
+	}
+
+	stopOnFatal(cfg)
+	annotateAfterConnect(cfg)
+	return cfg, nil
+}
+
+// buildDSN assembles the connection string described by config
+func buildDSN(config *config.ConfigMap) string {
+	scheme := config.DSNScheme
+	if scheme == "" {
+		scheme = "postgres"
+	}
+
+	// reserved characters in the user, password and database name are
+	// escaped, unless the config holds values that already are
+	userinfo := url.UserPassword(config.DbUser, config.Password).String()
+	dbName := url.PathEscape(config.DbName)
+	if config.RawDSNValues {
+		userinfo = config.DbUser + ":" + config.Password
+		dbName = config.DbName
+	}
+
+	const format = "%s://%s@%s:%d/%s?sslmode=%s"
+	dsn := fmt.Sprintf(
+		format, scheme, userinfo,
+		config.DbHost, config.DbPort,
+		dbName, url.QueryEscape(string(config.SSLMode)),
+	)
+
+	dsn += poolParams(config)
+
+	if config.ClientEncoding != "" {
+		dsn += "&client_encoding=" + url.QueryEscape(config.ClientEncoding)
+	}
+
+	if config.TimeZone != "" {
+		dsn += "&timezone=" + url.QueryEscape(config.TimeZone)
+	}
+
+	if len(config.Options) > 0 {
+		dsn += "&options=" + url.QueryEscape(startupOptions(config.Options))
+	}
+	return dsn
+}
+
+// ConnString returns the connection string NewFromCfgMap parses config
+// into, with the pool settings as pgxpool's pool_ parameters, so the whole
+// ConfigMap but its tls material is expressible as a string. It holds the
+// password
+func ConnString(config *config.ConfigMap) string {
+	return buildDSN(config)
+}
+
+// poolParams returns the pool settings of config that are set
+// as the parameters pgxpool.ParseConfig reads them from
+func poolParams(config *config.ConfigMap) string {
+	var params string
+	if config.MaxConns > 0 {
+		params += fmt.Sprintf("&pool_max_conns=%d", config.MaxConns)
+	}
+	if config.MinConns > 0 {
+		params += fmt.Sprintf("&pool_min_conns=%d", config.MinConns)
+	}
+
+	durations := []struct {
+		name  string
+		value time.Duration
+	}{
+		{"pool_max_conn_lifetime", config.MaxConnLifetime},
+		{"pool_max_conn_idle_time", config.MaxConnIdleTime},
+		{"pool_health_check_period", config.HealthCheckPeriod},
+		{"pool_max_conn_lifetime_jitter", config.MaxConnLifetimeJitter},
+	}
+	for _, d := range durations {
+		if d.value > 0 {
+			params += "&" + d.name + "=" + d.value.String()
+		}
+	}
+	return params
+}
+
+// dsnParams are the connection string parameters buildDSN may set
+var dsnParams = map[string]bool{
+	"sslmode":                       true,
+	"pool_max_conns":                true,
+	"pool_min_conns":                true,
+	"pool_max_conn_lifetime":        true,
+	"pool_max_conn_idle_time":       true,
+	"pool_health_check_period":      true,
+	"pool_max_conn_lifetime_jitter": true,
+	"client_encoding":               true,
+	"timezone":                      true,
+	"options":                       true,
+}
+
+// checkDSN rejects the connection string assembled from config when a field
+// it was built from holds control characters, such as a newline left by a
+// templating tool, or it otherwise isn't a url. It also parses it back to
+// check no value escaped its place, as a DbHost of "host/db?sslmode=disable#"
+// or a raw DbName of "db?sslmode=disable" would to change other settings.
+// Errors name the field, not its value, as it may be the password
+func checkDSN(config *config.ConfigMap, dsn string) error {
+	fields := []struct{ name, value string }{
+		{"DbUser", config.DbUser},
+		{"Password", config.Password},
+		{"DbHost", config.DbHost},
+		{"DbName", config.DbName},
+		{"DSNScheme", config.DSNScheme},
+		{"SSLMode", string(config.SSLMode)},
+	}
+	for _, f := range fields {
+		if i := strings.IndexFunc(f.value, unicode.IsControl); i >= 0 {
+			return fmt.Errorf("%s holds control character %q at offset %d", f.name, f.value[i], i)
+		}
+	}
+
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return errors.New("connection string assembled from config isn't a valid url, check DbUser, Password, DbHost and DbName for reserved characters")
+	}
 
-		xPool = x509.NewCertPool()
-		if !xPool.AppendCertsFromPEM(CAcert) {
-			return nil, errors.New("can't add ca cert to cert pool")
+	if scheme := config.DSNScheme; scheme != "" && u.Scheme != scheme {
+		return fmt.Errorf("invalid DSNScheme %q", scheme)
+	}
+	if u.Host != fmt.Sprintf("%s:%d", config.DbHost, config.DbPort) {
+		return errors.New("connection string assembled from config doesn't connect to DbHost, check DbHost for reserved characters, and with RawDSNValues DbUser and Password too")
+	}
+	query := u.Query()
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	// sorted so the same config always reports the same parameter
+	sort.Strings(keys)
+	for _, key := range keys {
+		if values := query[key]; !dsnParams[key] || len(values) > 1 {
+			return fmt.Errorf("connection string assembled from config sets parameter %s it shouldn't, with RawDSNValues check DbName for reserved characters", key)
 		}
+	}
+	if query.Get("sslmode") != string(config.SSLMode) {
+		return errors.New("connection string assembled from config has another sslmode than SSLMode, with RawDSNValues check DbName for reserved characters")
+	}
+	return nil
+}
+
+// quotedString matches the double quoted connection string in url.Parse errors
+var quotedString = regexp.MustCompile(`"[^"]*"`)
+
+// attributeParseError names the config field that most likely
+// produced the connection string pgx failed to parse with err
+func attributeParseError(config *config.ConfigMap, err error) error {
+	// pgx redacts the password in the connection string it quotes,
+	// but not in the url.Parse error it appends after its reason
+	full := redactPassword(err.Error(), config.Password)
 
+	// pgx quotes the whole connection string before the reason, and
+	// a url.Parse error repeats it, so neither is matched
+	msg := full
+	if i := strings.LastIndex(msg, "`: "); i >= 0 {
+		msg = msg[i+len("`: "):]
 	}
+	msg = quotedString.ReplaceAllLiteralString(msg, `""`)
 
-	cert, err := withPassphrase(config.SSLCertFile, config.SSLKeyFile, []byte(config.SSLKeyFilePassPhrase))
+	switch {
+	case strings.Contains(msg, "sslmode"):
+		return fmt.Errorf("invalid SSLMode %q: %s", config.SSLMode, full)
+	case strings.Contains(msg, "pool_max_conns"):
+		return fmt.Errorf("invalid MaxConns %d: %s", config.MaxConns, full)
+	case strings.Contains(msg, "port"):
+		return fmt.Errorf("invalid DbPort %d: %s", config.DbPort, full)
+	case strings.Contains(msg, "options"):
+		return fmt.Errorf("invalid Options: %s", full)
+	case strings.Contains(msg, "host") || strings.Contains(msg, "URL"):
+		return fmt.Errorf("invalid DbHost %q, DbUser or DbName: %s", config.DbHost, full)
+	case full != err.Error():
+		return errors.New(full)
+	default:
+		return err
+	}
+}
+
+// redactPassword replaces password in msg, as written or as
+// escaped in the connection string, the way pgx redacts it
+func redactPassword(msg, password string) string {
+	if password == "" {
+		return msg
+	}
+	escaped := strings.TrimPrefix(url.UserPassword("", password).String(), ":")
+	msg = strings.ReplaceAll(msg, escaped, "xxxxxx")
+	return strings.ReplaceAll(msg, password, "xxxxxx")
+}
+
+// startupOptions formats opts as the -c flags of the options
+// connection parameter, escaping spaces and backslashes in
+// values as libpq expects
+func startupOptions(opts map[string]string) string {
+	keys := make([]string, 0, len(opts))
+	for k := range opts {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	escaper := strings.NewReplacer(`\`, `\\`, " ", `\ `)
+	flags := make([]string, len(keys))
+	for i, k := range keys {
+		flags[i] = "-c " + escaper.Replace(k) + "=" + escaper.Replace(opts[k])
+	}
+	return strings.Join(flags, " ")
+}
+
+// systemCertPool is x509.SystemCertPool, swappable for platforms without one
+var systemCertPool = x509.SystemCertPool
+
+// loadRootCAs returns the pool of certificate authorities in src, or the
+// system's when src has none. Without either it fails only when mode
+// verifies the server certificate. For require without a CA in src it
+// returns nil, as like libpq that mode then only encrypts
+func loadRootCAs(ctx context.Context, src SecretSource, mode config.SSLMode) (*x509.CertPool, error) {
+	CAcert, err := src.GetCA(ctx)
 	if err != nil {
 		return nil, err
 	}
 
-	cfg.ConnConfig.TLSConfig = &tls.Config{
-		Certificates: []tls.Certificate{*cert},
-		RootCAs:      xPool,
+	if len(CAcert) == 0 && mode == config.SSLModeRequire {
+		return nil, nil
 	}
 
-	if config.SSLHostname != "" {
-		cfg.ConnConfig.TLSConfig.ServerName = config.SSLHostname
-	} else {
-		cfg.ConnConfig.TLSConfig.InsecureSkipVerify = true
+	if len(CAcert) == 0 {
+		xPool, err := systemCertPool()
+		if err == nil && xPool != nil {
+			return xPool, nil
+		}
+
+		if mode == config.SSLModeVerifyCA || mode == config.SSLModeVerifyFull {
+			if err == nil {
+				err = errors.New("no system cert pool on this platform")
+			}
+			return nil, fmt.Errorf("sslmode %s verifies the server certificate but no CA is configured and the system cert pool is unavailable: %v", mode, err)
+		}
+		return nil, nil
 	}
 
-	pool, err := pool.ConnectConfig(ctx, cfg)
-	if err != nil {
+	if CAcert, err = decryptCA(ctx, src, CAcert); err != nil {
 		return nil, err
 	}
 
-	return pool, nil
+	xPool := x509.NewCertPool()
+	if !xPool.AppendCertsFromPEM(CAcert) {
+		return nil, errors.New("can't add ca cert to cert pool")
+	}
+	return xPool, nil
 }
 
-// withPassphrase takes .key and .crt file paths
-// decodes the .key file with the give passphrase
-// and constructs a tls.Certificate with the .crt
-// file and the decoded .key file
-func withPassphrase(pathToCert string, pathToKey string, password []byte) (*tls.Certificate, error) {
+// encryptOnly reports whether connections with mode and the root CAs
+// in xPool are encrypted without verifying the server, as libpq does
+// for require without a CA
+func encryptOnly(mode config.SSLMode, xPool *x509.CertPool) bool {
+	return xPool == nil && mode == config.SSLModeRequire
+}
+
+// verifiesHost reports whether connections with mode check the server
+// certificate is for the host, as libpq does only for verify-full, or
+// for hostname when one is set explicitly, whatever the mode
+func verifiesHost(mode config.SSLMode, hostname string) bool {
+	return mode == config.SSLModeVerifyFull || hostname != ""
+}
+
+// decryptCA returns the PEM in CAcert with any encrypted blocks decrypted
+// with the passphrase src gives, if it has one. Plain PEM is returned as is
+func decryptCA(ctx context.Context, src SecretSource, CAcert []byte) ([]byte, error) {
+	var (
+		out        []byte
+		passphrase []byte
+		fetched    bool
+	)
+
+	for rest := CAcert; ; {
+		var block *pem.Block
+		if block, rest = pem.Decode(rest); block == nil {
+			break
+		}
+
+		if x509.IsEncryptedPEMBlock(block) {
+			if !fetched {
+				ps, ok := src.(caPassphraseSource)
+				if !ok {
+					return nil, errors.New("ca file is encrypted but no passphrase is configured")
+				}
+
+				var err error
+				if passphrase, err = ps.GetCAPassphrase(ctx); err != nil {
+					return nil, err
+				}
+				fetched = true
+			}
+
+			der, err := x509.DecryptPEMBlock(block, passphrase)
+			if err != nil {
+				return nil, fmt.Errorf("can't decrypt ca file: %w", passphraseError(err))
+			}
+			block = &pem.Block{Type: block.Type, Bytes: der}
+		}
+
+		out = append(out, pem.EncodeToMemory(block)...)
+	}
 
-	keyFile, err := ioutil.ReadFile(pathToKey)
+	if !fetched {
+		return CAcert, nil
+	}
+	return out, nil
+}
+
+// loadClientCert returns the client certificate and decrypted key in src
+func loadClientCert(ctx context.Context, src SecretSource) (*tls.Certificate, error) {
+	certPEM, err := src.GetCert(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	keyPEM, err := src.GetKey(ctx)
 	if err != nil {
 		return nil, err
 	}
 
-	certFile, err := ioutil.ReadFile(pathToCert)
+	passphrase, err := src.GetPassphrase(ctx)
 	if err != nil {
 		return nil, err
 	}
 
-	keyBlock, _ := pem.Decode(keyFile)
+	return withPassphrase(certPEM, keyPEM, passphrase)
+}
+
+// BuildClientTLSConfig returns the tls.Config NewFromCfgMap connects with,
+// for reuse by other clients of related services. Its ServerName is
+// SSLHostname, or the first host in DbHost when that is empty
+func BuildClientTLSConfig(config *config.ConfigMap, opts ...Option) (*tls.Config, error) {
+	tlsConfig, err := newTLSConfig(context.Background(), config, newOptions(opts))
+	if err != nil {
+		return nil, err
+	}
+
+	host := strings.Split(config.DbHost, ",")[0]
+	return forHost(tlsConfig, host), nil
+}
+
+// newTLSConfig assembles the tls.Config to connect with from the ca,
+// certificate and key in o's SecretSource, by default the files named in config
+func newTLSConfig(ctx context.Context, config *config.ConfigMap, o *options) (*tls.Config, error) {
+	src := o.secrets
+	if src == nil {
+		files := fileSecrets(config)
+		if config.PromptPassphraseIfEmpty && files.Passphrase == "" {
+			passphrase, err := promptPassphrase(ctx, files)
+			if err != nil {
+				return nil, err
+			}
+			files.Passphrase = passphrase
+		}
+		src = files
+	}
+
+	fileSrc, cached := src.(FileSecretSource)
+
+	xPool := o.rootCAs
+	if xPool == nil {
+		var err error
+		if cached {
+			xPool, err = cachedRootCAs(ctx, fileSrc, config.SSLMode)
+		} else {
+			xPool, err = loadRootCAs(ctx, src, config.SSLMode)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var (
+		cert *tls.Certificate
+		err  error
+	)
+	if cached {
+		cert, err = cachedClientCert(ctx, fileSrc)
+	} else {
+		cert, err = loadClientCert(ctx, src)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err = checkExpiry(cert, o); err != nil {
+		return nil, err
+	}
+
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{*cert},
+		RootCAs:      xPool,
+	}
+
+	// ServerName is only sent as SNI and checked against the server
+	// certificate, connections are still dialed to DbHost, so endpoints
+	// routing by SNI work. When empty, setTLSConfig uses the host dialed
+	// for verify-full.
+	// A concrete name verifies against a wildcard SAN covering it, while
+	// a wildcard name such as *.db.example.com needs that exact SAN
+	tlsConfig.ServerName = config.SSLHostname
+
+	if config.SSLDebug {
+		tlsConfig.VerifyConnection = logHandshake(o.logger)
+	}
+
+	// with InsecureSkipVerify the chain goes unverified, but
+	// VerifyPeerCertificate still runs so the leaf can be checked
+	tlsConfig.InsecureSkipVerify = config.SSLInsecureSkipVerify
+	if encryptOnly(config.SSLMode, xPool) {
+		tlsConfig.InsecureSkipVerify = true
+	}
+
+	// as in libpq only verify-full checks the certificate is for the host
+	// it dialed, verify-ca and require with a CA verify its chain alone, which
+	// crypto/tls only does with InsecureSkipVerify and VerifyPeerCertificate.
+	// An SSLHostname is checked in every mode, it names the expected server
+	var chainRoots *x509.CertPool
+	if !tlsConfig.InsecureSkipVerify && !verifiesHost(config.SSLMode, config.SSLHostname) {
+		tlsConfig.InsecureSkipVerify = true
+		chainRoots = xPool
+	}
 
-	// Decrypt key
-	keyDER, err := x509.DecryptPEMBlock(keyBlock, password)
+	checks := leafChecks(config)
+	if config.SSLCRLFile != "" {
+		issuers, err := crlIssuers(ctx, src)
+		if err != nil {
+			return nil, err
+		}
+		check, err := loadCRL(config.SSLCRLFile, issuers)
+		if err != nil {
+			return nil, err
+		}
+		checks = append(checks, check)
+	}
+	if o.verifyPeer != nil {
+		checks = append(checks, o.verifyPeer)
+	}
+	if len(checks) > 0 || chainRoots != nil {
+		tlsConfig.VerifyPeerCertificate = verifyLeaf(chainRoots, checks)
+	}
+
+	return tlsConfig, nil
+}
+
+// NewFromDSN Returns a new database initialized from an already assembled dsn.
+// tlsCfg replaces the tls.Config pgx derives from the dsn's sslmode, so it
+// only takes effect when that sslmode uses TLS
+func NewFromDSN(ctx context.Context, dsn string, tlsCfg *tls.Config, fn AfterConnectFunc) (*pool.Pool, error) {
+	cfg, err := pool.ParseConfig(dsn)
 	if err != nil {
 		return nil, err
 	}
 
-	keyBlock.Bytes = keyDER // Update keyBlock with the plaintext bytes
-	keyBlock.Headers = nil  //clear the now obsolete headers.
+	configure(cfg, fn)
+
+	if tlsCfg != nil {
+		setTLSConfig(cfg, tlsCfg)
+	}
+
+	annotateAfterConnect(cfg)
+
+	return pool.ConnectConfig(ctx, cfg)
+}
+
+// setTLSConfig replaces the tls.Config of every connection attempt
+// cfg's sslmode makes over TLS, leaving plaintext attempts untouched.
+// Unless tlsCfg skips verifying the server certificate, as it does for
+// all but verify-full, one without a ServerName is verified against the
+// host of each attempt, a mismatch fails the handshake with an error
+// listing the names the certificate is valid for
+func setTLSConfig(cfg *pool.Config, tlsCfg *tls.Config) {
+	if cfg.ConnConfig.TLSConfig != nil {
+		cfg.ConnConfig.TLSConfig = forHost(tlsCfg, cfg.ConnConfig.Host)
+	}
+	for _, fb := range cfg.ConnConfig.Fallbacks {
+		if fb.TLSConfig != nil {
+			fb.TLSConfig = forHost(tlsCfg, fb.Host)
+		}
+	}
+}
+
+func forHost(tlsCfg *tls.Config, host string) *tls.Config {
+	if tlsCfg.ServerName != "" || tlsCfg.InsecureSkipVerify {
+		return tlsCfg
+	}
+
+	c := tlsCfg.Clone()
+	c.ServerName = host
+	return c
+}
+
+// beforeConnect returns a pool.Config.BeforeConnect hook
+// setting the password of each new connection from fn
+func beforeConnect(fn PasswordFunc) func(context.Context, *pgx.ConnConfig) error {
+	return func(ctx context.Context, cc *pgx.ConnConfig) error {
+		password, err := fn(ctx)
+		if err != nil {
+			return fmt.Errorf("unable to obtain password: %v", err)
+		}
+		cc.Password = password
+		return nil
+	}
+}
+
+// configure applies the settings shared by every pool created by this package
+func configure(cfg *pool.Config, fn AfterConnectFunc) {
+	cfg.AfterConnect = fn
+	cfg.ConnConfig.DialFunc = func(ctx context.Context, host string, addr string) (net.Conn, error) {
+		return net.Dial(host, addr)
+	}
+
+	cfg.ConnConfig.PreferSimpleProtocol = true
+	cfg.ConnConfig.ConnectTimeout = time.Minute
+}
+
+// noConnectTimeout leaves connections cfg makes without a connect timeout,
+// bounded only by their context. pgxpool gives connections without one a
+// timeout of two minutes, so it is cleared again before each connection
+func noConnectTimeout(cfg *pool.Config) {
+	cfg.ConnConfig.ConnectTimeout = 0
+	before := cfg.BeforeConnect
+	cfg.BeforeConnect = func(ctx context.Context, cc *pgx.ConnConfig) error {
+		cc.ConnectTimeout = 0
+		if before != nil {
+			return before(ctx, cc)
+		}
+		return nil
+	}
+}
+
+// localDialer returns a dialer binding connections to addr, an IP
+// address optionally with a port, like pgx's own dialer otherwise
+func localDialer(addr string) (*net.Dialer, error) {
+	hostport := addr
+	if ip := net.ParseIP(addr); ip != nil {
+		hostport = net.JoinHostPort(addr, "0")
+	}
+	local, err := net.ResolveTCPAddr("tcp", hostport)
+	if err != nil {
+		return nil, fmt.Errorf("invalid LocalAddr %q: %v", addr, err)
+	}
+	return &net.Dialer{LocalAddr: local, KeepAlive: 5 * time.Minute}, nil
+}
+
+// withNetwork wraps dial to use network instead of the tcp network pgx asks
+// for, forcing IPv4 or IPv6. Unix socket connections are left alone
+func withNetwork(dial pgconn.DialFunc, network string) pgconn.DialFunc {
+	return func(ctx context.Context, n, addr string) (net.Conn, error) {
+		if n == "tcp" {
+			n = network
+		}
+		return dial(ctx, n, addr)
+	}
+}
+
+// checkExpiry returns an error if the leaf of cert has expired
+// and warns if it expires within o.expiryWarning
+func checkExpiry(cert *tls.Certificate, o *options) error {
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		return fmt.Errorf("can't parse client certificate: %v", err)
+	}
+
+	now := time.Now()
+	if now.After(leaf.NotAfter) {
+		return fmt.Errorf("client certificate expired on %s", leaf.NotAfter.Format(time.RFC3339))
+	}
+
+	if o.expiryWarning > 0 && now.Add(o.expiryWarning).After(leaf.NotAfter) {
+		o.logger.Log(context.Background(), pgx.LogLevelWarn, "client certificate expires soon", map[string]interface{}{
+			"subject":   leaf.Subject.String(),
+			"not_after": leaf.NotAfter,
+		})
+	}
+	return nil
+}
+
+// ErrBadPassphrase is returned, possibly wrapped, when the passphrase
+// for an encrypted key or ca file is wrong, so callers can check for it
+// with errors.Is and ask for the passphrase again
+var ErrBadPassphrase = errors.New("incorrect passphrase")
+
+// passphraseError returns ErrBadPassphrase in place of x509's
+// error for a wrong passphrase, and any other err as is
+func passphraseError(err error) error {
+	if errors.Is(err, x509.IncorrectPasswordError) {
+		return ErrBadPassphrase
+	}
+	return err
+}
+
+// withPassphrase takes the contents of .crt and .key files
+// decodes the .key file with the give passphrase
+// and constructs a tls.Certificate with the .crt
+// file and the decoded .key file. Both may be the
+// same bundle holding the certificate and the key,
+// and a key that isn't encrypted is used as is
+func withPassphrase(certFile []byte, keyFile []byte, password []byte) (*tls.Certificate, error) {
+
+	keyBlock := findBlock(keyFile, func(typ string) bool {
+		return strings.HasSuffix(typ, "PRIVATE KEY")
+	})
+	if keyBlock == nil {
+		return nil, errors.New("no private key found in client key file")
+	}
+
+	keyDER := keyBlock.Bytes
+	if x509.IsEncryptedPEMBlock(keyBlock) {
+		// Decrypt key
+		var err error
+		keyDER, err = x509.DecryptPEMBlock(keyBlock, password)
+		if err != nil {
+			return nil, passphraseError(err)
+		}
+
+		keyBlock.Bytes = keyDER // Update keyBlock with the plaintext bytes
+		keyBlock.Headers = nil  //clear the now obsolete headers.
+	}
+
+	if err := matchKeyPair(certFile, keyDER); err != nil {
+		return nil, err
+	}
 
 	// Turn the key back into PEM format so we can leverage tls.X509KeyPair,
 	// which will deal with the intricacies of error handling, different key
@@ -120,3 +809,62 @@ func withPassphrase(pathToCert string, pathToKey string, password []byte) (*tls.
 	}
 	return &cert, nil
 }
+
+// findBlock returns the first PEM block in data whose type satisfies match
+func findBlock(data []byte, match func(typ string) bool) *pem.Block {
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil || match(block.Type) {
+			return block
+		}
+	}
+}
+
+// matchKeyPair checks the private key in keyDER belongs to
+// the first certificate in certFile, so a mismatched pair is
+// reported clearly rather than failing the handshake
+func matchKeyPair(certFile []byte, keyDER []byte) error {
+	certBlock := findBlock(certFile, func(typ string) bool {
+		return typ == "CERTIFICATE"
+	})
+	if certBlock == nil {
+		return errors.New("no certificate found in client certificate file")
+	}
+
+	leaf, err := x509.ParseCertificate(certBlock.Bytes)
+	if err != nil {
+		return fmt.Errorf("can't parse client certificate: %v", err)
+	}
+
+	key, err := parsePrivateKey(keyDER)
+	if err != nil {
+		return err
+	}
+
+	pub, ok := key.Public().(interface{ Equal(crypto.PublicKey) bool })
+	if !ok || !pub.Equal(leaf.PublicKey) {
+		return fmt.Errorf("client key does not match the public key of certificate %q", leaf.Subject)
+	}
+	return nil
+}
+
+// parsePrivateKey parses a PKCS #1, PKCS #8 or SEC 1 encoded private key
+func parsePrivateKey(der []byte) (crypto.Signer, error) {
+	if key, err := x509.ParsePKCS1PrivateKey(der); err == nil {
+		return key, nil
+	}
+
+	if key, err := x509.ParsePKCS8PrivateKey(der); err == nil {
+		if signer, ok := key.(crypto.Signer); ok {
+			return signer, nil
+		}
+		return nil, errors.New("unsupported client key type")
+	}
+
+	if key, err := x509.ParseECPrivateKey(der); err == nil {
+		return key, nil
+	}
+
+	return nil, errors.New("can't parse client key")
+}
@@ -0,0 +1,51 @@
+package pgxtls
+
+import (
+	"context"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+// readPassword reads a line from the terminal fd without echoing it
+var readPassword = term.ReadPassword
+
+// isTerminal reports whether fd is a terminal
+var isTerminal = term.IsTerminal
+
+// promptPassphrase asks on the terminal for the passphrase of the key in
+// src when it is encrypted, and returns an empty passphrase when it isn't
+func promptPassphrase(ctx context.Context, src FileSecretSource) (string, error) {
+	data, err := src.GetKey(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	block := findBlock(data, func(typ string) bool {
+		return strings.HasSuffix(typ, "PRIVATE KEY")
+	})
+	if block == nil || !x509.IsEncryptedPEMBlock(block) {
+		return "", nil
+	}
+
+	name := src.KeyFile
+	if src.KeyPEM != nil {
+		name = "the client key"
+	}
+
+	fd := int(os.Stdin.Fd())
+	if !isTerminal(fd) {
+		return "", fmt.Errorf("%s is encrypted and has no passphrase, and stdin isn't a terminal to ask for one on", name)
+	}
+
+	fmt.Fprintf(os.Stderr, "Passphrase for %s: ", name)
+	passphrase, err := readPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", fmt.Errorf("unable to read passphrase: %v", err)
+	}
+	return string(passphrase), nil
+}
@@ -0,0 +1,58 @@
+package pgxtls
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestPromptPassphrase(t *testing.T) {
+	ca := newTestCA(t)
+	_, keyPEM := ca.issue(t, clientTemplate("user"))
+	encrypted := encryptPEM(t, keyPEM, "secret")
+
+	tests := []struct {
+		name       string
+		src        FileSecretSource
+		terminal   bool
+		typed      string
+		readErr    error
+		want       string
+		wantPrompt bool
+		wantErr    string
+	}{
+		{name: "unencrypted", src: FileSecretSource{KeyPEM: keyPEM}, terminal: true},
+		{name: "typed", src: FileSecretSource{KeyPEM: encrypted}, terminal: true, typed: "secret", want: "secret", wantPrompt: true},
+		{name: "no terminal", src: FileSecretSource{KeyFile: writeFile(t, t.TempDir(), "client.key", encrypted)}, wantErr: "client.key is encrypted"},
+		{name: "read fails", src: FileSecretSource{KeyPEM: encrypted}, terminal: true, readErr: errors.New("interrupted"), wantPrompt: true, wantErr: "unable to read passphrase: interrupted"},
+		{name: "missing key", src: FileSecretSource{KeyFile: "/nonexistent/client.key"}, terminal: true, wantErr: "client.key"},
+	}
+	read, is := readPassword, isTerminal
+	defer func() { readPassword, isTerminal = read, is }()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prompted := false
+			isTerminal = func(int) bool { return tt.terminal }
+			readPassword = func(int) ([]byte, error) {
+				prompted = true
+				return []byte(tt.typed), tt.readErr
+			}
+
+			got, err := promptPassphrase(context.Background(), tt.src)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("promptPassphrase() error = %v, want %q", err, tt.wantErr)
+				}
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Fatalf("promptPassphrase() = %q, want %q", got, tt.want)
+			}
+			if prompted != tt.wantPrompt {
+				t.Fatalf("prompted = %v, want %v", prompted, tt.wantPrompt)
+			}
+		})
+	}
+}
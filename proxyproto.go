@@ -0,0 +1,93 @@
+package pgxtls
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"net"
+
+	"github.com/jackc/pgconn"
+)
+
+// WithProxyProtocol sends a PROXY protocol header of version, 1 or 2,
+// on every new connection before anything else, for databases behind
+// a proxy such as HAProxy expecting one. Pools aren't created with
+// other versions
+func WithProxyProtocol(version int) Option {
+	return func(o *options) {
+		o.proxyProtocol = version
+	}
+}
+
+// proxyDialer wraps dial to write a PROXY header on each connection it makes
+func proxyDialer(dial pgconn.DialFunc, version int) pgconn.DialFunc {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := dial(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+
+		header, err := proxyHeader(version, conn.LocalAddr(), conn.RemoteAddr())
+		if err == nil {
+			_, err = conn.Write(header)
+		}
+		if err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("unable to send proxy protocol header: %v", err)
+		}
+		return conn, nil
+	}
+}
+
+// proxyV2Signature starts every version 2 header
+var proxyV2Signature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+
+// proxyHeader returns the PROXY protocol header announcing a connection from src to dst
+func proxyHeader(version int, src, dst net.Addr) ([]byte, error) {
+	srcTCP, srcOK := src.(*net.TCPAddr)
+	dstTCP, dstOK := dst.(*net.TCPAddr)
+	tcp := srcOK && dstOK
+	ipv4 := tcp && srcTCP.IP.To4() != nil && dstTCP.IP.To4() != nil
+
+	switch version {
+	case 1:
+		if !tcp {
+			return []byte("PROXY UNKNOWN\r\n"), nil
+		}
+		proto := "TCP6"
+		if ipv4 {
+			proto = "TCP4"
+		}
+		return []byte(fmt.Sprintf("PROXY %s %s %s %d %d\r\n",
+			proto, srcTCP.IP, dstTCP.IP, srcTCP.Port, dstTCP.Port)), nil
+
+	case 2:
+		var buf bytes.Buffer
+		buf.Write(proxyV2Signature)
+		buf.WriteByte(0x21) // version 2, PROXY command
+
+		var addrs []byte
+		switch {
+		case !tcp:
+			buf.WriteByte(0x00) // unspecified
+		case ipv4:
+			buf.WriteByte(0x11) // TCP over IPv4
+			addrs = append(append(addrs, srcTCP.IP.To4()...), dstTCP.IP.To4()...)
+		default:
+			buf.WriteByte(0x21) // TCP over IPv6
+			addrs = append(append(addrs, srcTCP.IP.To16()...), dstTCP.IP.To16()...)
+		}
+		if tcp {
+			addrs = append(addrs, byte(srcTCP.Port>>8), byte(srcTCP.Port))
+			addrs = append(addrs, byte(dstTCP.Port>>8), byte(dstTCP.Port))
+		}
+
+		binary.Write(&buf, binary.BigEndian, uint16(len(addrs)))
+		buf.Write(addrs)
+		return buf.Bytes(), nil
+
+	default:
+		return nil, fmt.Errorf("unsupported proxy protocol version %d", version)
+	}
+}
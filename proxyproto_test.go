@@ -0,0 +1,116 @@
+package pgxtls
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestProxyHeader(t *testing.T) {
+	v4src := &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 51234}
+	v4dst := &net.TCPAddr{IP: net.ParseIP("10.0.0.2"), Port: 5432}
+	v6src := &net.TCPAddr{IP: net.ParseIP("2001:db8::1"), Port: 51234}
+	v6dst := &net.TCPAddr{IP: net.ParseIP("2001:db8::2"), Port: 5432}
+	unix := &net.UnixAddr{Name: "/var/run/postgresql/.s.PGSQL.5432", Net: "unix"}
+
+	v2 := func(family byte, addrs ...byte) []byte {
+		h := append([]byte("\r\n\r\n\x00\r\nQUIT\n\x21"), family, byte(len(addrs)>>8), byte(len(addrs)))
+		return append(h, addrs...)
+	}
+
+	tests := []struct {
+		name     string
+		version  int
+		src, dst net.Addr
+		want     []byte
+		wantErr  bool
+	}{
+		{name: "v1 ipv4", version: 1, src: v4src, dst: v4dst, want: []byte("PROXY TCP4 10.0.0.1 10.0.0.2 51234 5432\r\n")},
+		{name: "v1 ipv6", version: 1, src: v6src, dst: v6dst, want: []byte("PROXY TCP6 2001:db8::1 2001:db8::2 51234 5432\r\n")},
+		{name: "v1 unix", version: 1, src: unix, dst: unix, want: []byte("PROXY UNKNOWN\r\n")},
+		{name: "v2 ipv4", version: 2, src: v4src, dst: v4dst, want: v2(0x11, 10, 0, 0, 1, 10, 0, 0, 2, 0xc8, 0x22, 0x15, 0x38)},
+		{name: "v2 ipv6", version: 2, src: v6src, dst: v6dst, want: v2(0x21,
+			0x20, 0x01, 0x0d, 0xb8, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1,
+			0x20, 0x01, 0x0d, 0xb8, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 2,
+			0xc8, 0x22, 0x15, 0x38)},
+		{name: "v2 unix", version: 2, src: unix, dst: unix, want: v2(0x00)},
+		{name: "v3", version: 3, src: v4src, dst: v4dst, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := proxyHeader(tt.version, tt.src, tt.dst)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("proxyHeader() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !bytes.Equal(got, tt.want) {
+				t.Fatalf("proxyHeader() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProxyDialer(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	received := make(chan string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		header := make([]byte, len("PROXY TCP4 127.0.0.1 127.0.0.1 "))
+		io.ReadFull(conn, header)
+		received <- string(header)
+	}()
+
+	dialer := &net.Dialer{}
+	conn, err := proxyDialer(dialer.DialContext, 1)(context.Background(), "tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	if got := <-received; got != "PROXY TCP4 127.0.0.1 127.0.0.1 " {
+		t.Fatalf("server received %q", got)
+	}
+
+	if _, err := proxyDialer(dialer.DialContext, 3)(context.Background(), "tcp", ln.Addr().String()); err == nil || !strings.Contains(err.Error(), "unsupported proxy protocol version 3") {
+		t.Fatalf("got %v, want the unsupported version", err)
+	}
+}
+
+func TestWithProxyProtocolVersion(t *testing.T) {
+	srv := newFakeServer(t)
+	tests := []struct {
+		version int
+		wantErr bool
+	}{
+		{version: 1},
+		{version: 2},
+		{version: 3, wantErr: true},
+		{version: -1, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(strconv.Itoa(tt.version), func(t *testing.T) {
+			_, err := newPoolConfig(context.Background(), srv.configMap(t), nil, newOptions([]Option{WithProxyProtocol(tt.version)}))
+			if tt.wantErr {
+				if err == nil || !strings.Contains(err.Error(), "unsupported proxy protocol version") {
+					t.Fatalf("newPoolConfig() error = %v, want the unsupported version", err)
+				}
+			} else if err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
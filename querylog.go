@@ -0,0 +1,52 @@
+package pgxtls
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+)
+
+// multiLogger passes every log event pgx emits on to each of its loggers
+type multiLogger []pgx.Logger
+
+func (m multiLogger) Log(ctx context.Context, level pgx.LogLevel, msg string, data map[string]interface{}) {
+	for _, l := range m {
+		l.Log(ctx, level, msg, data)
+	}
+}
+
+// slowQueryLogger logs the Query, Exec, SendBatch and CopyFrom
+// events pgx emits that took at least threshold to complete
+type slowQueryLogger struct {
+	logger     pgx.Logger
+	threshold  time.Duration
+	redactArgs bool
+}
+
+func (l *slowQueryLogger) Log(ctx context.Context, level pgx.LogLevel, msg string, data map[string]interface{}) {
+	elapsed, ok := data["time"].(time.Duration)
+	if !ok || elapsed < l.threshold {
+		return
+	}
+
+	fields := make(map[string]interface{}, len(data))
+	for k, v := range data {
+		fields[k] = v
+	}
+	if _, ok := fields["args"]; ok && l.redactArgs {
+		fields["args"] = "[redacted]"
+	}
+
+	l.logger.Log(ctx, pgx.LogLevelWarn, "slow "+strings.ToLower(msg), fields)
+}
+
+// WithSlowQueryLog logs every query taking threshold or longer to the
+// logger set by WithLogger, along with its sql and arguments. Set
+// redactArgs to leave the arguments out
+func WithSlowQueryLog(threshold time.Duration, redactArgs bool) Option {
+	return func(o *options) {
+		o.slowQuery = &slowQueryLogger{threshold: threshold, redactArgs: redactArgs}
+	}
+}
@@ -0,0 +1,71 @@
+package pgxtls
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+)
+
+func TestSlowQueryLogger(t *testing.T) {
+	tests := []struct {
+		name       string
+		msg        string
+		data       map[string]interface{}
+		redactArgs bool
+		wantMsg    string
+		wantArgs   interface{}
+	}{
+		{name: "fast", msg: "Query", data: map[string]interface{}{"sql": "SELECT 1", "time": time.Millisecond}},
+		{name: "no duration", msg: "Dialing PostgreSQL server", data: map[string]interface{}{"host": "db"}},
+		{name: "slow", msg: "Query", data: map[string]interface{}{"sql": "SELECT $1", "args": []interface{}{1}, "time": time.Second}, wantMsg: "slow query", wantArgs: []interface{}{1}},
+		{name: "at threshold", msg: "Exec", data: map[string]interface{}{"sql": "VACUUM", "time": 100 * time.Millisecond}, wantMsg: "slow exec"},
+		{name: "redacted", msg: "Query", data: map[string]interface{}{"sql": "SELECT $1", "args": []interface{}{"secret"}, "time": time.Second}, redactArgs: true, wantMsg: "slow query", wantArgs: "[redacted]"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logs := &logRecorder{}
+			l := &slowQueryLogger{logger: logs, threshold: 100 * time.Millisecond, redactArgs: tt.redactArgs}
+			l.Log(context.Background(), pgx.LogLevelInfo, tt.msg, tt.data)
+
+			if tt.wantMsg == "" {
+				if len(logs.entries) != 0 {
+					t.Fatalf("logged %v", logs.entries)
+				}
+				return
+			}
+			entries := logs.find(tt.wantMsg)
+			if len(entries) != 1 || entries[0].level != pgx.LogLevelWarn {
+				t.Fatalf("logged %v, want one warning %q", logs.entries, tt.wantMsg)
+			}
+			if got := entries[0].data["args"]; fmt.Sprint(got) != fmt.Sprint(tt.wantArgs) {
+				t.Fatalf("args = %v, want %v", got, tt.wantArgs)
+			}
+			if tt.data["args"] == "[redacted]" {
+				t.Fatal("redacting changed the event pgx passed")
+			}
+		})
+	}
+}
+
+func TestWithSlowQueryLog(t *testing.T) {
+	srv := newFakeServer(t)
+	logs := &logRecorder{}
+	ctx := context.Background()
+
+	p, err := NewFromCfgMap(ctx, srv.configMap(t), nil, WithLogger(logs), WithSlowQueryLog(0, true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	if _, err := p.Exec(ctx, "UPDATE jobs SET done = true WHERE id = $1", 7); err != nil {
+		t.Fatal(err)
+	}
+	entries := logs.find("slow exec")
+	if len(entries) != 1 || entries[0].data["args"] != "[redacted]" {
+		t.Fatalf("logged %v, want the redacted exec", logs.entries)
+	}
+}
@@ -0,0 +1,57 @@
+package pgxtls
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+)
+
+// rdsTokenExpiry is how long an RDS auth token is accepted for
+const rdsTokenExpiry = 15 * time.Minute
+
+// hex encoded sha256 of an empty payload
+const emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// RDSIAMAuth returns a PasswordFunc generating an RDS IAM auth token for user
+// on every call, for use with WithPasswordFunc. RDS only accepts IAM auth
+// over TLS so the database must be connected to with sslmode require or stricter
+func RDSIAMAuth(region, host string, port uint16, user string, creds aws.CredentialsProvider) PasswordFunc {
+	signer := v4.NewSigner()
+	return func(ctx context.Context) (string, error) {
+		return buildRDSAuthToken(ctx, signer, region, host, port, user, creds, time.Now())
+	}
+}
+
+// buildRDSAuthToken presigns an rds-db connect request, the token
+// is the presigned url without its scheme
+func buildRDSAuthToken(ctx context.Context, signer *v4.Signer, region, host string, port uint16, user string, creds aws.CredentialsProvider, now time.Time) (string, error) {
+	credentials, err := creds.Retrieve(ctx)
+	if err != nil {
+		return "", fmt.Errorf("unable to retrieve aws credentials: %v", err)
+	}
+
+	query := url.Values{}
+	query.Set("Action", "connect")
+	query.Set("DBUser", user)
+	query.Set("X-Amz-Expires", strconv.Itoa(int(rdsTokenExpiry/time.Second)))
+
+	endpoint := fmt.Sprintf("https://%s:%d/?%s", host, port, query.Encode())
+	req, err := http.NewRequest(http.MethodGet, endpoint, nil)
+	if err != nil {
+		return "", err
+	}
+
+	signed, _, err := signer.PresignHTTP(ctx, credentials, req, emptyPayloadHash, "rds-db", region, now.UTC())
+	if err != nil {
+		return "", fmt.Errorf("unable to sign rds auth token: %v", err)
+	}
+
+	return strings.TrimPrefix(signed, "https://"), nil
+}
@@ -0,0 +1,76 @@
+package pgxtls
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+)
+
+func TestBuildRDSAuthToken(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name       string
+		creds      aws.Credentials
+		wantParams map[string]string
+	}{
+		{
+			name:  "static",
+			creds: aws.Credentials{AccessKeyID: "AKIDEXAMPLE", SecretAccessKey: "secret"},
+			wantParams: map[string]string{
+				"Action":           "connect",
+				"DBUser":           "app",
+				"X-Amz-Expires":    "900",
+				"X-Amz-Date":       "20240301T120000Z",
+				"X-Amz-Credential": "AKIDEXAMPLE/20240301/eu-west-1/rds-db/aws4_request",
+			},
+		},
+		{
+			name:  "session",
+			creds: aws.Credentials{AccessKeyID: "ASIAEXAMPLE", SecretAccessKey: "secret", SessionToken: "session"},
+			wantParams: map[string]string{
+				"X-Amz-Security-Token": "session",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			creds := aws.CredentialsProviderFunc(func(context.Context) (aws.Credentials, error) { return tt.creds, nil })
+			token, err := buildRDSAuthToken(context.Background(), v4.NewSigner(), "eu-west-1", "db.example.com", 5432, "app", creds, now)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !strings.HasPrefix(token, "db.example.com:5432/?") {
+				t.Fatalf("token %q doesn't start with the endpoint", token)
+			}
+			query, err := url.ParseQuery(token[strings.Index(token, "?")+1:])
+			if err != nil {
+				t.Fatal(err)
+			}
+			for key, want := range tt.wantParams {
+				if got := query.Get(key); got != want {
+					t.Errorf("%s = %q, want %q", key, got, want)
+				}
+			}
+			if query.Get("X-Amz-Signature") == "" {
+				t.Error("token isn't signed")
+			}
+		})
+	}
+}
+
+func TestBuildRDSAuthTokenCredentialsError(t *testing.T) {
+	creds := aws.CredentialsProviderFunc(func(context.Context) (aws.Credentials, error) {
+		return aws.Credentials{}, errors.New("no credentials")
+	})
+	_, err := buildRDSAuthToken(context.Background(), v4.NewSigner(), "eu-west-1", "db.example.com", 5432, "app", creds, time.Now())
+	if err == nil || !strings.Contains(err.Error(), "unable to retrieve aws credentials") {
+		t.Fatalf("got %v, want the credentials error", err)
+	}
+}
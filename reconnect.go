@@ -0,0 +1,107 @@
+package pgxtls
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"strings"
+
+	"github.com/danvixent/pgxtls/config"
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+	pool "github.com/jackc/pgx/v4/pgxpool"
+)
+
+// RunWithReconnect runs work on a single connection made from config,
+// for long lived workers such as LISTEN/NOTIFY consumers. Whenever work
+// returns because the connection was lost, or connecting fails with an
+// error retrying may fix, a new connection is made after waiting as the
+// WithBackoff strategy says and work is run again. RunWithReconnect
+// returns when work returns nil or any other error, when connecting
+// fails for good, such as with a wrong password, or when ctx is done
+func RunWithReconnect(ctx context.Context, config *config.ConfigMap, work func(context.Context, *pgx.Conn) error, opts ...Option) error {
+	o := newOptions(opts)
+	cfg, err := newPoolConfig(ctx, config, nil, o)
+	if err != nil {
+		return err
+	}
+
+	attempt := 0
+	for {
+		conn, err := connect(ctx, cfg)
+		if err != nil && !retryConnect(err) {
+			return err
+		}
+		if err == nil {
+			attempt = 0
+			err = work(ctx, conn)
+			lost := conn.IsClosed() || pgconn.SafeToRetry(err)
+			conn.Close(context.Background())
+			if err == nil || !lost {
+				return err
+			}
+		}
+
+		delay := o.backoff.Delay(attempt)
+		o.logger.Log(ctx, pgx.LogLevelWarn, "database connection lost, reconnecting", map[string]interface{}{
+			"err":     err,
+			"attempt": attempt + 1,
+			"delay":   delay,
+		})
+		if err := sleep(ctx, delay); err != nil {
+			return err
+		}
+		attempt++
+	}
+}
+
+// connect makes a single connection, running the
+// BeforeConnect and AfterConnect hooks of cfg as a pool would
+func connect(ctx context.Context, cfg *pool.Config) (*pgx.Conn, error) {
+	cc := cfg.ConnConfig.Copy()
+	if cfg.BeforeConnect != nil {
+		if err := cfg.BeforeConnect(ctx, cc); err != nil {
+			return nil, err
+		}
+	}
+
+	conn, err := pgx.ConnectConfig(ctx, cc)
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.AfterConnect != nil {
+		if err := cfg.AfterConnect(ctx, conn); err != nil {
+			conn.Close(ctx)
+			return nil, err
+		}
+	}
+	return conn, nil
+}
+
+// retryConnect reports whether connecting again may get past err, as
+// when the server can't be reached or is starting up, unlike errors such
+// as a wrong password, a failed certificate check or one marked Fatal
+func retryConnect(err error) bool {
+	if IsFatal(err) {
+		return false
+	}
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		switch {
+		case strings.HasPrefix(pgErr.Code, "08"), // connection_exception
+			pgErr.Code == "53300", // too_many_connections
+			pgErr.Code == "57P01", // admin_shutdown
+			pgErr.Code == "57P02", // crash_shutdown
+			pgErr.Code == "57P03": // cannot_connect_now
+			return true
+		}
+		return false
+	}
+
+	var netErr net.Error
+	return pgconn.SafeToRetry(err) || errors.As(err, &netErr) ||
+		errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+}
@@ -0,0 +1,106 @@
+package pgxtls
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgproto3/v2"
+	"github.com/jackc/pgx/v4"
+)
+
+func TestRunWithReconnect(t *testing.T) {
+	srv := newFakeServer(t)
+	config := srv.configMap(t)
+	config.ConnectRole = "worker"
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	calls := 0
+	err := RunWithReconnect(ctx, config, func(ctx context.Context, conn *pgx.Conn) error {
+		calls++
+		if calls == 1 {
+			srv.kill(conn.PgConn().PID())
+			_, err := conn.Exec(ctx, "LISTEN jobs")
+			return err
+		}
+		return nil
+	}, WithBackoff(ConstantBackoff(time.Millisecond)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls != 2 {
+		t.Fatalf("work ran %d times, want 2", calls)
+	}
+
+	roles := 0
+	for _, q := range srv.queries() {
+		if q == `SET ROLE "worker"` {
+			roles++
+		}
+	}
+	if roles != 2 {
+		t.Fatalf("SET ROLE ran on %d connections, want 2", roles)
+	}
+}
+
+func TestRunWithReconnectPermanentError(t *testing.T) {
+	srv := newFakeServer(t)
+	srv.reject = &pgproto3.ErrorResponse{Severity: "FATAL", Code: "28P01", Message: "password authentication failed"}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	err := RunWithReconnect(ctx, srv.configMap(t), func(context.Context, *pgx.Conn) error {
+		t.Fatal("work ran without a connection")
+		return nil
+	}, WithBackoff(ConstantBackoff(time.Millisecond)))
+
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) || pgErr.Code != "28P01" {
+		t.Fatalf("got %v, want the authentication error", err)
+	}
+}
+
+func TestWaitForDBPermanentError(t *testing.T) {
+	srv := newFakeServer(t)
+	srv.reject = &pgproto3.ErrorResponse{Severity: "FATAL", Code: "3D000", Message: `database "db" does not exist`}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var pgErr *pgconn.PgError
+	if err := WaitForDB(ctx, srv.configMap(t), time.Millisecond); !errors.As(err, &pgErr) || pgErr.Code != "3D000" {
+		t.Fatalf("got %v, want the missing database error", err)
+	}
+}
+
+func TestRetryConnect(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"refused", &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}, true},
+		{"eof", fmt.Errorf("read: %w", io.EOF), true},
+		{"starting up", &pgconn.PgError{Code: "57P03"}, true},
+		{"too many connections", &pgconn.PgError{Code: "53300"}, true},
+		{"bad password", &pgconn.PgError{Code: "28P01"}, false},
+		{"missing role", Fatal(&pgconn.PgError{Code: "22023"}), false},
+		{"fatal dial", Fatal(&net.OpError{Op: "dial", Err: errors.New("refused")}), false},
+		{"certificate", errors.New("x509: certificate signed by unknown authority"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := retryConnect(tt.err); got != tt.want {
+				t.Fatalf("retryConnect(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
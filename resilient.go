@@ -0,0 +1,58 @@
+package pgxtls
+
+import (
+	"context"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+	pool "github.com/jackc/pgx/v4/pgxpool"
+)
+
+// ResilientPool is a pool running a query once more when it failed on a
+// connection broken before the query reached the server, as every pooled
+// connection is after a database restart. pgconn.SafeToRetry decides, so
+// queries that may have run are never repeated
+type ResilientPool struct {
+	*pool.Pool
+}
+
+// NewResilientPool returns p as a ResilientPool
+func NewResilientPool(p *pool.Pool) *ResilientPool {
+	return &ResilientPool{Pool: p}
+}
+
+func (p *ResilientPool) Exec(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error) {
+	tag, err := p.Pool.Exec(ctx, sql, args...)
+	if err != nil && pgconn.SafeToRetry(err) && ctx.Err() == nil {
+		return p.Pool.Exec(ctx, sql, args...)
+	}
+	return tag, err
+}
+
+func (p *ResilientPool) Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error) {
+	rows, err := p.Pool.Query(ctx, sql, args...)
+	if err != nil && pgconn.SafeToRetry(err) && ctx.Err() == nil {
+		return p.Pool.Query(ctx, sql, args...)
+	}
+	return rows, err
+}
+
+func (p *ResilientPool) QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row {
+	return &resilientRow{ctx: ctx, p: p.Pool, sql: sql, args: args}
+}
+
+// resilientRow runs its query when scanned, so it can be run again
+type resilientRow struct {
+	ctx  context.Context
+	p    *pool.Pool
+	sql  string
+	args []interface{}
+}
+
+func (r *resilientRow) Scan(dest ...interface{}) error {
+	err := r.p.QueryRow(r.ctx, r.sql, r.args...).Scan(dest...)
+	if err != nil && pgconn.SafeToRetry(err) && r.ctx.Err() == nil {
+		return r.p.QueryRow(r.ctx, r.sql, r.args...).Scan(dest...)
+	}
+	return err
+}
@@ -0,0 +1,95 @@
+package pgxtls
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgproto3/v2"
+	pool "github.com/jackc/pgx/v4/pgxpool"
+)
+
+func TestResilientPool(t *testing.T) {
+	run := map[string]func(ctx context.Context, p *ResilientPool, sql string) error{
+		"Exec": func(ctx context.Context, p *ResilientPool, sql string) error {
+			_, err := p.Exec(ctx, sql)
+			return err
+		},
+		"Query": func(ctx context.Context, p *ResilientPool, sql string) error {
+			rows, err := p.Query(ctx, sql)
+			if err != nil {
+				return err
+			}
+			rows.Close()
+			return rows.Err()
+		},
+		"QueryRow": func(ctx context.Context, p *ResilientPool, sql string) error {
+			var v string
+			return p.QueryRow(ctx, sql).Scan(&v)
+		},
+	}
+
+	tests := []struct {
+		name      string
+		sql       string
+		broken    bool
+		cancelled bool
+		wantErr   bool
+		wantRuns  int
+	}{
+		{name: "healthy", sql: "SELECT 1", wantRuns: 1},
+		{name: "broken connection", sql: "SELECT 1", broken: true, wantRuns: 1},
+		{name: "query error", sql: "SELECT fail", wantErr: true, wantRuns: 1},
+		{name: "cancelled", sql: "SELECT 1", broken: true, cancelled: true, wantErr: true},
+	}
+	for method, fn := range run {
+		for _, tt := range tests {
+			t.Run(method+"/"+tt.name, func(t *testing.T) {
+				srv := newFakeServer(t)
+				srv.rows["SELECT 1"] = "1"
+				srv.fail["SELECT fail"] = &pgproto3.ErrorResponse{Severity: "ERROR", Code: "42P01", Message: "relation does not exist"}
+
+				cfg := srv.poolConfig(t)
+				cfg.MaxConns = 1
+				ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+				defer cancel()
+				pp, err := pool.ConnectConfig(ctx, cfg)
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer pp.Close()
+				p := NewResilientPool(pp)
+
+				if tt.broken {
+					// break the only pooled connection without pgconn noticing,
+					// as a database restart does
+					conn, err := p.Acquire(ctx)
+					if err != nil {
+						t.Fatal(err)
+					}
+					conn.Conn().PgConn().Conn().Close()
+					conn.Release()
+				}
+				qctx := ctx
+				if tt.cancelled {
+					var qcancel context.CancelFunc
+					qctx, qcancel = context.WithCancel(ctx)
+					qcancel()
+				}
+
+				if err := fn(qctx, p, tt.sql); (err != nil) != tt.wantErr {
+					t.Fatalf("%s() error = %v, wantErr %v", method, err, tt.wantErr)
+				}
+				runs := 0
+				for _, q := range srv.queries() {
+					if q == tt.sql {
+						runs++
+					}
+				}
+				if runs != tt.wantRuns {
+					t.Fatalf("query reached the server %d times, want %d", runs, tt.wantRuns)
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package pgxtls
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/danvixent/pgxtls/config"
+	pool "github.com/jackc/pgx/v4/pgxpool"
+)
+
+// RolePool keeps a pool per role for multi-tenant applications, each
+// switching its connections to the role with SET ROLE. Pools are created
+// from the base ConfigMap on first use and, read from the same files, share
+// its parsed tls material. Together they hold at most a fixed number of
+// connections
+type RolePool struct {
+	base  config.ConfigMap
+	fn    AfterConnectFunc
+	opts  []Option
+	total int32
+
+	mu    sync.Mutex
+	pools map[string]*pool.Pool
+	used  int32
+}
+
+// NewRolePool returns a RolePool creating pools from base, each sized
+// as NewFromCfgMap sizes pools of base, as long as all of them together
+// hold at most total connections
+func NewRolePool(base *config.ConfigMap, total int32, fn AfterConnectFunc, opts ...Option) *RolePool {
+	return &RolePool{
+		base:  *base,
+		fn:    fn,
+		opts:  opts,
+		total: total,
+		pools: make(map[string]*pool.Pool),
+	}
+}
+
+// Pool returns the pool of role, creating it if it doesn't exist yet.
+// It fails when a new pool would take the connections of the RolePool
+// over its total. Pools of other roles stay available while one is
+// created
+func (r *RolePool) Pool(ctx context.Context, role string) (*pool.Pool, error) {
+	if p, ok := r.lookup(role); ok {
+		return p, nil
+	}
+
+	config := r.base
+	config.ConnectRole = role
+	o := cfgMapOptions(&config, r.opts)
+	cfg, err := sizedPoolConfig(ctx, &config, r.fn, o)
+	if err != nil {
+		return nil, err
+	}
+
+	size := cfg.MaxConns
+	r.mu.Lock()
+	if p, ok := r.pools[role]; ok {
+		r.mu.Unlock()
+		return p, nil
+	}
+	if r.used+size > r.total {
+		left := r.total - r.used
+		r.mu.Unlock()
+		return nil, fmt.Errorf("pool for role %q needs %d connections, only %d of %d left", role, size, left, r.total)
+	}
+	r.used += size
+	r.mu.Unlock()
+
+	p, err := connectPool(ctx, cfg, o)
+
+	r.mu.Lock()
+	if err != nil {
+		r.used -= size
+		r.mu.Unlock()
+		return nil, err
+	}
+	// another call created the role's pool meanwhile
+	existing, ok := r.pools[role]
+	if ok {
+		r.used -= size
+	} else {
+		r.pools[role] = p
+	}
+	r.mu.Unlock()
+
+	if ok {
+		p.Close()
+		return existing, nil
+	}
+	return p, nil
+}
+
+// lookup returns the pool of role, if it exists
+func (r *RolePool) lookup(role string) (*pool.Pool, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	p, ok := r.pools[role]
+	return p, ok
+}
+
+// Close closes the pool of every role
+func (r *RolePool) Close() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for role, p := range r.pools {
+		p.Close()
+		delete(r.pools, role)
+	}
+	r.used = 0
+}
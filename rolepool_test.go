@@ -0,0 +1,146 @@
+package pgxtls
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	pool "github.com/jackc/pgx/v4/pgxpool"
+)
+
+func TestRolePool(t *testing.T) {
+	tests := []struct {
+		name     string
+		maxConns uint8
+		percent  float64 // of the server's max_connections of 10
+		total    int32
+		roles    []string
+		wantErr  string
+		wantSets []string
+	}{
+		{name: "within total", maxConns: 2, total: 4, roles: []string{"tenant_a", "tenant_b", "tenant_a"}, wantSets: []string{"tenant_a", "tenant_b"}},
+		{name: "over total", maxConns: 2, total: 3, roles: []string{"tenant_a", "tenant_b"}, wantErr: `pool for role "tenant_b" needs 2 connections, only 1 of 3 left`, wantSets: []string{"tenant_a"}},
+		{name: "auto sized", total: 1 << 20, roles: []string{"tenant_a"}, wantSets: []string{"tenant_a"}},
+		{name: "auto sized over total", total: AutoMaxConns() + 1, roles: []string{"tenant_a", "tenant_b"},
+			wantErr: fmt.Sprintf(`pool for role "tenant_b" needs %d connections, only 1 of %d left`, AutoMaxConns(), AutoMaxConns()+1), wantSets: []string{"tenant_a"}},
+		{name: "sized by server", maxConns: 100, percent: 50, total: 9, roles: []string{"tenant_a", "tenant_b"},
+			// tenant_b connects once to be sized, then is refused
+			wantErr: `pool for role "tenant_b" needs 5 connections, only 4 of 9 left`, wantSets: []string{"tenant_a", "tenant_b"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newFakeServer(t)
+			base := srv.configMap(t)
+			base.MaxConns, base.MaxConnsPercent = tt.maxConns, tt.percent
+			srv.rows["SHOW max_connections"] = "10"
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			r := NewRolePool(base, tt.total, nil)
+			defer r.Close()
+			var err error
+			for _, role := range tt.roles {
+				var p *pool.Pool
+				if p, err = r.Pool(ctx, role); err != nil {
+					break
+				}
+				if err = p.Ping(ctx); err != nil {
+					t.Fatal(err)
+				}
+				if again, _ := r.Pool(ctx, role); again != p {
+					t.Fatalf("second Pool(%s) returned a new pool", role)
+				}
+			}
+			if tt.wantErr == "" && err != nil || tt.wantErr != "" && (err == nil || err.Error() != tt.wantErr) {
+				t.Fatalf("Pool() error = %v, want %q", err, tt.wantErr)
+			}
+
+			var sets []string
+			seen := map[string]bool{}
+			for _, q := range srv.queries() {
+				if role := strings.TrimPrefix(q, "SET ROLE "); role != q && !seen[role] {
+					seen[role] = true
+					sets = append(sets, strings.Trim(role, `"`))
+				}
+			}
+			if fmt.Sprint(sets) != fmt.Sprint(tt.wantSets) {
+				t.Fatalf("SET ROLE ran for %v, want %v", sets, tt.wantSets)
+			}
+			if base.ConnectRole != "" {
+				t.Fatal("Pool() changed the base config")
+			}
+		})
+	}
+}
+
+func TestRolePoolCloseFreesConnections(t *testing.T) {
+	srv := newFakeServer(t)
+	base := srv.configMap(t)
+	base.MaxConns = 2
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	r := NewRolePool(base, 2, nil)
+	defer r.Close()
+	for i, role := range []string{"tenant_a", "tenant_b"} {
+		if _, err := r.Pool(ctx, role); err != nil {
+			t.Fatalf("pool %d: %v", i, err)
+		}
+		r.Close()
+	}
+}
+
+func TestRolePoolCreationDoesntBlockLookups(t *testing.T) {
+	srv := newFakeServer(t)
+	base := srv.configMap(t)
+	base.MaxConns = 2
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var blocking int32
+	release := make(chan struct{})
+	r := NewRolePool(base, 4, func(context.Context, *pgx.Conn) error {
+		if atomic.LoadInt32(&blocking) == 1 {
+			<-release
+		}
+		return nil
+	})
+	defer r.Close()
+
+	existing, err := r.Pool(ctx, "tenant_a")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	atomic.StoreInt32(&blocking, 1)
+	created := make(chan error, 1)
+	go func() {
+		_, err := r.Pool(ctx, "tenant_b")
+		created <- err
+	}()
+
+	// tenant_b is connecting, blocked in its AfterConnectFunc
+	waitFor(t, func() bool { return srv.conns() == 2 })
+	done := make(chan *pool.Pool, 1)
+	go func() {
+		p, _ := r.Pool(ctx, "tenant_a")
+		done <- p
+	}()
+	select {
+	case p := <-done:
+		if p != existing {
+			t.Fatal("Pool(tenant_a) returned a new pool")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Pool(tenant_a) waited for tenant_b's pool to be created")
+	}
+
+	close(release)
+	if err := <-created; err != nil {
+		t.Fatal(err)
+	}
+}
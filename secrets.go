@@ -0,0 +1,126 @@
+package pgxtls
+
+import (
+	"context"
+	"io/ioutil"
+
+	"github.com/danvixent/pgxtls/config"
+	pool "github.com/jackc/pgx/v4/pgxpool"
+)
+
+// SecretSource supplies the PEM encoded tls material to connect with,
+// letting it come from a secrets manager instead of the filesystem
+type SecretSource interface {
+	// GetCert returns the client certificate
+	GetCert(ctx context.Context) ([]byte, error)
+	// GetKey returns the, possibly encrypted, client key
+	GetKey(ctx context.Context) ([]byte, error)
+	// GetCA returns the certificate authorities to trust,
+	// or nothing to trust the system's
+	GetCA(ctx context.Context) ([]byte, error)
+	// GetPassphrase returns the passphrase of the client key
+	GetPassphrase(ctx context.Context) ([]byte, error)
+}
+
+// FileSecretSource reads tls material from files, or takes it
+// from the PEM fields that are set instead
+type FileSecretSource struct {
+	CertFile     string
+	ChainFile    string // intermediate certificates sent after CertFile's, optional
+	KeyFile      string
+	CAFile       string // empty to trust the system's certificate authorities
+	Passphrase   string
+	CAPassphrase string // decrypts CAFile when it is encrypted
+	CertPEM      []byte // used instead of CertFile when set
+	KeyPEM       []byte // used instead of KeyFile when set
+	CAPEM        []byte // used instead of CAFile when set
+}
+
+// caPassphraseSource is implemented by SecretSources whose
+// GetCA may return encrypted PEM blocks
+type caPassphraseSource interface {
+	GetCAPassphrase(ctx context.Context) ([]byte, error)
+}
+
+func fileSecrets(config *config.ConfigMap) FileSecretSource {
+	return FileSecretSource{
+		CertFile:     config.SSLCertFile,
+		ChainFile:    config.SSLCertChainFile,
+		KeyFile:      config.SSLKeyFile,
+		CAFile:       config.SSLCAFile,
+		Passphrase:   config.SSLKeyFilePassPhrase,
+		CAPassphrase: config.SSLCAFilePassPhrase,
+		CertPEM:      pemBytes(config.SSLCertPEM),
+		KeyPEM:       pemBytes(config.SSLKeyPEM),
+		CAPEM:        pemBytes(config.SSLCAPEM),
+	}
+}
+
+// pemBytes returns the bytes of a PEM field, nil when it is empty
+func pemBytes(s string) []byte {
+	if s == "" {
+		return nil
+	}
+	return []byte(s)
+}
+
+// inMemory reports whether f holds any tls material
+// itself rather than naming the files it is in
+func (f FileSecretSource) inMemory() bool {
+	return f.CertPEM != nil || f.KeyPEM != nil || f.CAPEM != nil
+}
+
+func (f FileSecretSource) GetCert(context.Context) ([]byte, error) {
+	var err error
+	cert := f.CertPEM
+	if cert == nil {
+		cert, err = ioutil.ReadFile(f.CertFile)
+	}
+	if err != nil || f.ChainFile == "" {
+		return cert, err
+	}
+
+	chain, err := ioutil.ReadFile(f.ChainFile)
+	if err != nil {
+		return nil, err
+	}
+
+	// tls.X509KeyPair keeps the certificates in order, leaf first
+	bundle := make([]byte, 0, len(cert)+1+len(chain))
+	return append(append(append(bundle, cert...), '\n'), chain...), nil
+}
+
+func (f FileSecretSource) GetKey(context.Context) ([]byte, error) {
+	if f.KeyPEM != nil {
+		return f.KeyPEM, nil
+	}
+	return ioutil.ReadFile(f.KeyFile)
+}
+
+func (f FileSecretSource) GetCA(context.Context) ([]byte, error) {
+	if f.CAPEM != nil {
+		return f.CAPEM, nil
+	}
+	if f.CAFile == "" {
+		return nil, nil
+	}
+	return ioutil.ReadFile(f.CAFile)
+}
+
+func (f FileSecretSource) GetPassphrase(context.Context) ([]byte, error) {
+	return []byte(f.Passphrase), nil
+}
+
+func (f FileSecretSource) GetCAPassphrase(context.Context) ([]byte, error) {
+	return []byte(f.CAPassphrase), nil
+}
+
+// NewFromSecretSource Returns a new database initialized with credentials from base
+// and tls material from src, the ssl file fields of base are ignored
+func NewFromSecretSource(ctx context.Context, base *config.ConfigMap, src SecretSource, fn AfterConnectFunc, opts ...Option) (*pool.Pool, error) {
+	// copied, so a caller's spare capacity isn't written to
+	opts = append(append([]Option(nil), opts...), func(o *options) {
+		o.secrets = src
+	})
+	return NewFromCfgMap(ctx, base, fn, opts...)
+}
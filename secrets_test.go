@@ -0,0 +1,146 @@
+package pgxtls
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// memSecrets is a SecretSource holding its tls material in memory
+type memSecrets struct {
+	cert, key, ca, passphrase []byte
+	err                       error
+}
+
+func (m memSecrets) GetCert(context.Context) ([]byte, error)       { return m.cert, nil }
+func (m memSecrets) GetKey(context.Context) ([]byte, error)        { return m.key, m.err }
+func (m memSecrets) GetCA(context.Context) ([]byte, error)         { return m.ca, nil }
+func (m memSecrets) GetPassphrase(context.Context) ([]byte, error) { return m.passphrase, nil }
+
+func TestFileSecretSource(t *testing.T) {
+	dir := t.TempDir()
+	certFile := writeFile(t, dir, "client.crt", []byte("cert"))
+	chainFile := writeFile(t, dir, "chain.crt", []byte("chain"))
+	keyFile := writeFile(t, dir, "client.key", []byte("key"))
+	caFile := writeFile(t, dir, "ca.crt", []byte("ca"))
+	ctx := context.Background()
+
+	tests := []struct {
+		name              string
+		src               FileSecretSource
+		cert, key, ca     string
+		wantErr, inMemory bool
+	}{
+		{name: "files", src: FileSecretSource{CertFile: certFile, KeyFile: keyFile, CAFile: caFile}, cert: "cert", key: "key", ca: "ca"},
+		{name: "chain", src: FileSecretSource{CertFile: certFile, ChainFile: chainFile, KeyFile: keyFile}, cert: "cert\nchain", key: "key"},
+		{name: "no ca", src: FileSecretSource{CertFile: certFile, KeyFile: keyFile}, cert: "cert", key: "key"},
+		{name: "pem", src: FileSecretSource{CertFile: certFile, CertPEM: []byte("cert pem"), KeyPEM: []byte("key pem"), CAPEM: []byte("ca pem")}, cert: "cert pem", key: "key pem", ca: "ca pem", inMemory: true},
+		{name: "pem with chain", src: FileSecretSource{CertPEM: []byte("cert pem"), ChainFile: chainFile, KeyFile: keyFile}, cert: "cert pem\nchain", key: "key", inMemory: true},
+		{name: "missing file", src: FileSecretSource{CertFile: dir + "/missing.crt", KeyFile: keyFile}, wantErr: true},
+		{name: "missing chain", src: FileSecretSource{CertFile: certFile, ChainFile: dir + "/missing.crt", KeyFile: keyFile}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cert, err := tt.src.GetCert(ctx)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("missing file read")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			key, err := tt.src.GetKey(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ca, err := tt.src.GetCA(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if string(cert) != tt.cert || string(key) != tt.key || string(ca) != tt.ca {
+				t.Fatalf("got cert %q key %q ca %q", cert, key, ca)
+			}
+			if tt.src.inMemory() != tt.inMemory {
+				t.Fatalf("inMemory() = %v", tt.src.inMemory())
+			}
+		})
+	}
+}
+
+func TestSecretSourceTLSConfig(t *testing.T) {
+	ca := newTestCA(t)
+	serverCert, serverKey := ca.issue(t, serverTemplate("db.example.com"))
+	certPEM, keyPEM := ca.issue(t, clientTemplate("user"))
+
+	tests := []struct {
+		name    string
+		src     memSecrets
+		wantErr string
+	}{
+		{name: "plain", src: memSecrets{cert: certPEM, key: keyPEM, ca: ca.pem}},
+		{name: "encrypted key", src: memSecrets{cert: certPEM, key: encryptPEM(t, keyPEM, "hunter2"), ca: ca.pem, passphrase: []byte("hunter2")}},
+		{name: "source error", src: memSecrets{cert: certPEM, ca: ca.pem, err: errors.New("vault sealed")}, wantErr: "vault sealed"},
+		{name: "untrusted", src: memSecrets{cert: certPEM, key: keyPEM, ca: newTestCA(t).pem}, wantErr: "unknown authority"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// the ssl file fields are ignored
+			c := dsnConfig()
+			c.SSLCertFile, c.SSLKeyFile, c.SSLCAFile = "/nonexistent/client.crt", "/nonexistent/client.key", "/nonexistent/ca.crt"
+
+			tlsCfg, err := newTLSConfig(context.Background(), c, newOptions([]Option{func(o *options) { o.secrets = tt.src }}))
+			if err == nil {
+				if !bytes.Equal(tlsCfg.Certificates[0].Certificate[0], parseCert(t, certPEM).Raw) {
+					t.Fatal("client certificate isn't the source's")
+				}
+				err = handshake(t, forHost(tlsCfg, "db.example.com"), serverCert, serverKey)
+			}
+
+			if tt.wantErr == "" && err != nil || tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("got %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNewFromSecretSource(t *testing.T) {
+	srv := newFakeServer(t)
+	certPEM, keyPEM := newTestCA(t).issue(t, clientTemplate("user"))
+	base := srv.configMap(t)
+	base.SSLCertFile, base.SSLKeyFile = "/nonexistent/client.crt", "/nonexistent/client.key"
+
+	tests := []struct {
+		name string
+		opts []Option
+	}{
+		{name: "no options"},
+		// the source's option mustn't land in the caller's spare capacity
+		{name: "spare capacity", opts: make([]Option, 1, 2)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for i := range tt.opts {
+				tt.opts[i] = WithLogger(&logRecorder{})
+			}
+
+			ctx := context.Background()
+			p, err := NewFromSecretSource(ctx, base, memSecrets{cert: certPEM, key: keyPEM}, nil, tt.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer p.Close()
+			if err := p.Ping(ctx); err != nil {
+				t.Fatal(err)
+			}
+
+			if spare := tt.opts[len(tt.opts):cap(tt.opts)]; len(spare) > 0 && spare[0] != nil {
+				t.Fatal("NewFromSecretSource() wrote to the caller's options")
+			}
+		})
+	}
+}
@@ -0,0 +1,312 @@
+package pgxtls
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/danvixent/pgxtls/config"
+	"github.com/jackc/pgproto3/v2"
+	pool "github.com/jackc/pgx/v4/pgxpool"
+)
+
+// firstPID is one less than the PID of the first backend of a fakeServer
+const firstPID = 1000
+
+// fakeServer is a minimal postgres backend accepting every
+// connection without a password, answering simple queries and
+// preparing statements that return no rows
+type fakeServer struct {
+	ln net.Listener
+
+	// rows holds the single text value answering each query,
+	// other queries complete without rows
+	rows map[string]string
+	// fail holds the error answering each query that fails
+	fail map[string]*pgproto3.ErrorResponse
+	// notice holds the notice sent ahead of the result of each query
+	notice map[string]*pgproto3.NoticeResponse
+	// reject, when set, is sent to connections instead of AuthenticationOk
+	reject *pgproto3.ErrorResponse
+	// tls, when set, accepts SSLRequests with this config, they are declined otherwise
+	tls *tls.Config
+
+	mu       sync.Mutex
+	nextPID  uint32
+	backends map[uint32]net.Conn
+	received []string
+	prepared []string
+	canceled []uint32
+}
+
+func newFakeServer(t *testing.T) *fakeServer {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return serveFake(t, ln)
+}
+
+// serveFake starts a fakeServer accepting connections on ln
+func serveFake(t *testing.T, ln net.Listener) *fakeServer {
+	s := &fakeServer{ln: ln, rows: map[string]string{}, fail: map[string]*pgproto3.ErrorResponse{}, notice: map[string]*pgproto3.NoticeResponse{}, nextPID: firstPID, backends: map[uint32]net.Conn{}}
+	t.Cleanup(func() { s.close() })
+	go s.serve()
+	return s
+}
+
+// addr returns the host:port the server listens on
+func (s *fakeServer) addr() string {
+	return s.ln.Addr().String()
+}
+
+// configMap returns a config connecting to s over plaintext,
+// with a client certificate and key valid for an hour
+func (s *fakeServer) configMap(t *testing.T) *config.ConfigMap {
+	t.Helper()
+	host, port, err := net.SplitHostPort(s.addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, err := strconv.Atoi(port)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ca := newTestCA(t)
+	certPEM, keyPEM := ca.issue(t, clientTemplate("user"))
+	dir := t.TempDir()
+	return &config.ConfigMap{
+		DbName:      "db",
+		DbHost:      host,
+		DbPort:      uint16(n),
+		DbUser:      "user",
+		Password:    "secret",
+		SSLMode:     config.SSLModeDisable,
+		SSLCertFile: writeFile(t, dir, "client.crt", certPEM),
+		SSLKeyFile:  writeFile(t, dir, "client.key", keyPEM),
+		ServerPort:  8080,
+	}
+}
+
+// poolConfig returns a pool config connecting to s over plaintext
+func (s *fakeServer) poolConfig(t *testing.T) *pool.Config {
+	t.Helper()
+	cfg, err := pool.ParseConfig(fmt.Sprintf("postgres://user@%s/db?sslmode=disable", s.addr()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.ConnConfig.PreferSimpleProtocol = true
+	return cfg
+}
+
+// queries returns the queries received so far
+func (s *fakeServer) queries() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.received...)
+}
+
+// statements returns the names of the statements prepared so far
+func (s *fakeServer) statements() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.prepared...)
+}
+
+// cancels returns the PIDs of the backends that cancel requests
+// with the right secret key were received for
+func (s *fakeServer) cancels() []uint32 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]uint32(nil), s.canceled...)
+}
+
+// conns returns how many backends s has started
+func (s *fakeServer) conns() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return int(s.nextPID - firstPID)
+}
+
+// kill closes the server side of the connection of backend pid
+func (s *fakeServer) kill(pid uint32) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if conn, ok := s.backends[pid]; ok {
+		conn.Close()
+	}
+}
+
+func (s *fakeServer) close() {
+	s.ln.Close()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, conn := range s.backends {
+		conn.Close()
+	}
+}
+
+func (s *fakeServer) serve() {
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *fakeServer) handle(conn net.Conn) {
+	defer conn.Close()
+	be := pgproto3.NewBackend(pgproto3.NewChunkReader(conn), conn)
+
+	for {
+		msg, err := be.ReceiveStartupMessage()
+		if err != nil {
+			return
+		}
+		if _, ok := msg.(*pgproto3.StartupMessage); ok {
+			break
+		}
+		if cancel, ok := msg.(*pgproto3.CancelRequest); ok {
+			s.mu.Lock()
+			if cancel.SecretKey == cancel.ProcessID {
+				s.canceled = append(s.canceled, cancel.ProcessID)
+			}
+			s.mu.Unlock()
+			return
+		}
+
+		if _, ok := msg.(*pgproto3.SSLRequest); ok && s.tls != nil {
+			if _, err := conn.Write([]byte{'S'}); err != nil {
+				return
+			}
+			tlsConn := tls.Server(conn, s.tls)
+			if err := tlsConn.Handshake(); err != nil {
+				return
+			}
+			conn = tlsConn
+			be = pgproto3.NewBackend(pgproto3.NewChunkReader(conn), conn)
+			continue
+		}
+		// GSSEncRequest and SSLRequest without tls are declined
+		if _, err := conn.Write([]byte{'N'}); err != nil {
+			return
+		}
+	}
+
+	if s.reject != nil {
+		be.Send(s.reject)
+		return
+	}
+
+	s.mu.Lock()
+	s.nextPID++
+	pid := s.nextPID
+	s.backends[pid] = conn
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.backends, pid)
+		s.mu.Unlock()
+	}()
+
+	for _, msg := range []pgproto3.BackendMessage{
+		&pgproto3.AuthenticationOk{},
+		&pgproto3.ParameterStatus{Name: "server_version", Value: "14.0"},
+		&pgproto3.ParameterStatus{Name: "client_encoding", Value: "UTF8"},
+		&pgproto3.ParameterStatus{Name: "standard_conforming_strings", Value: "on"},
+		&pgproto3.BackendKeyData{ProcessID: pid, SecretKey: pid},
+		&pgproto3.ReadyForQuery{TxStatus: 'I'},
+	} {
+		if err := be.Send(msg); err != nil {
+			return
+		}
+	}
+
+	// failed skips the rest of an extended query until its Sync
+	failed := false
+	for {
+		msg, err := be.Receive()
+		if err != nil {
+			return
+		}
+
+		var reply []pgproto3.BackendMessage
+		switch msg := msg.(type) {
+		case *pgproto3.Terminate:
+			return
+		case *pgproto3.Query:
+			if err := s.answer(be, msg.String); err != nil {
+				return
+			}
+		case *pgproto3.Parse:
+			s.mu.Lock()
+			fail := s.fail[msg.Query]
+			if fail == nil {
+				s.prepared = append(s.prepared, msg.Name)
+			}
+			s.mu.Unlock()
+			if failed = fail != nil; failed {
+				reply = append(reply, fail)
+			} else {
+				reply = append(reply, &pgproto3.ParseComplete{})
+			}
+		case *pgproto3.Describe:
+			if !failed {
+				reply = append(reply, &pgproto3.ParameterDescription{}, &pgproto3.NoData{})
+			}
+		case *pgproto3.Sync:
+			failed = false
+			reply = append(reply, &pgproto3.ReadyForQuery{TxStatus: 'I'})
+		}
+		for _, msg := range reply {
+			if err := be.Send(msg); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// answer sends the result of the simple query sql
+func (s *fakeServer) answer(be *pgproto3.Backend, sql string) error {
+	sql = strings.TrimSpace(sql)
+
+	var msgs []pgproto3.BackendMessage
+	s.mu.Lock()
+	s.received = append(s.received, sql)
+	value, ok := s.rows[sql]
+	fail := s.fail[sql]
+	if notice := s.notice[sql]; notice != nil {
+		msgs = append(msgs, notice)
+	}
+	s.mu.Unlock()
+	switch {
+	case fail != nil:
+		msgs = append(msgs, fail)
+	case ok:
+		msgs = append(msgs,
+			&pgproto3.RowDescription{Fields: []pgproto3.FieldDescription{{Name: []byte("value"), DataTypeOID: 25, DataTypeSize: -1, TypeModifier: -1}}},
+			&pgproto3.DataRow{Values: [][]byte{[]byte(value)}},
+			&pgproto3.CommandComplete{CommandTag: []byte("SELECT 1")},
+		)
+	case sql == "" || sql == ";":
+		msgs = append(msgs, &pgproto3.EmptyQueryResponse{})
+	default:
+		tag := strings.ToUpper(strings.Fields(sql)[0])
+		msgs = append(msgs, &pgproto3.CommandComplete{CommandTag: []byte(tag)})
+	}
+
+	for _, msg := range append(msgs, &pgproto3.ReadyForQuery{TxStatus: 'I'}) {
+		if err := be.Send(msg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
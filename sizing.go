@@ -0,0 +1,81 @@
+package pgxtls
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"strconv"
+
+	"github.com/jackc/pgx/v4"
+	pool "github.com/jackc/pgx/v4/pgxpool"
+)
+
+// queryRower is the part of a connection sizing queries through
+type queryRower interface {
+	QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row
+}
+
+// Bounds of the pool size AutoMaxConns picks
+const (
+	minAutoMaxConns = 4
+	maxAutoMaxConns = 100
+)
+
+// AutoMaxConns returns a pool size suited to the processors this program
+// may use, four connections per GOMAXPROCS between 4 and 100. Pools of a
+// ConfigMap with a zero MaxConns are sized this way
+func AutoMaxConns() int32 {
+	n := int32(runtime.GOMAXPROCS(0)) * 4
+	if n < minAutoMaxConns {
+		n = minAutoMaxConns
+	}
+	if n > maxAutoMaxConns {
+		n = maxAutoMaxConns
+	}
+	return n
+}
+
+// sizeByServer sets the max connections of cfg to percent of
+// the server's max_connections, connecting once to find it
+func sizeByServer(ctx context.Context, cfg *pool.Config, percent float64) error {
+	conn, err := connect(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	defer conn.Close(ctx)
+
+	n, err := maxConnsFromServer(ctx, conn, percent)
+	if err != nil {
+		return err
+	}
+
+	if cfg.MinConns > n {
+		return fmt.Errorf("MinConns (%d) must not exceed MaxConns (%d), %v%% of the server's max_connections", cfg.MinConns, n, percent)
+	}
+
+	cfg.MaxConns = n
+	return nil
+}
+
+// maxConnsFromServer returns percent of the server's max_connections,
+// at least one and at most max_connections
+func maxConnsFromServer(ctx context.Context, q queryRower, percent float64) (int32, error) {
+	var s string
+	if err := q.QueryRow(ctx, "SHOW max_connections").Scan(&s); err != nil {
+		return 0, fmt.Errorf("unable to query max_connections: %v", err)
+	}
+
+	max, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid max_connections %q: %v", s, err)
+	}
+
+	n := int(float64(max) * percent / 100)
+	if n < 1 {
+		n = 1
+	}
+	if n > max {
+		n = max
+	}
+	return int32(n), nil
+}
@@ -0,0 +1,168 @@
+package pgxtls
+
+import (
+	"context"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/jackc/pgx/v4"
+)
+
+// rowQuerier answers every QueryRow with value
+type rowQuerier string
+
+func (q rowQuerier) QueryRow(context.Context, string, ...interface{}) pgx.Row {
+	return stringRow(q)
+}
+
+type stringRow string
+
+func (r stringRow) Scan(dest ...interface{}) error {
+	*dest[0].(*string) = string(r)
+	return nil
+}
+
+func TestMaxConnsFromServer(t *testing.T) {
+	tests := []struct {
+		max     string
+		percent float64
+		want    int32
+		wantErr bool
+	}{
+		{max: "100", percent: 50, want: 50},
+		{max: "100", percent: 100, want: 100},
+		{max: "100", percent: 0.1, want: 1},
+		{max: "3", percent: 50, want: 1},
+		{max: "many", percent: 50, wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := maxConnsFromServer(context.Background(), rowQuerier(tt.max), tt.percent)
+		if (err != nil) != tt.wantErr {
+			t.Fatalf("maxConnsFromServer(%s, %v) error = %v, wantErr %v", tt.max, tt.percent, err, tt.wantErr)
+		}
+		if got != tt.want {
+			t.Fatalf("maxConnsFromServer(%s, %v) = %d, want %d", tt.max, tt.percent, got, tt.want)
+		}
+	}
+}
+
+func TestAutoMaxConns(t *testing.T) {
+	tests := []struct {
+		procs int
+		want  int32
+	}{
+		{procs: 1, want: minAutoMaxConns},
+		{procs: 2, want: 8},
+		{procs: 16, want: 64},
+		{procs: 64, want: maxAutoMaxConns},
+	}
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(0))
+	for _, tt := range tests {
+		t.Run(strconv.Itoa(tt.procs), func(t *testing.T) {
+			runtime.GOMAXPROCS(tt.procs)
+			if n := AutoMaxConns(); n != tt.want {
+				t.Fatalf("AutoMaxConns() = %d, want %d", n, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewPoolConfigAutoMaxConns(t *testing.T) {
+	tests := []struct {
+		name     string
+		maxConns uint8
+		want     int32
+	}{
+		{"configured", 7, 7},
+		{"zero", 0, AutoMaxConns()},
+	}
+	srv := newFakeServer(t)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := srv.configMap(t)
+			c.MaxConns = tt.maxConns
+			cfg, err := newPoolConfig(context.Background(), c, nil, newOptions(nil))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cfg.MaxConns != tt.want {
+				t.Fatalf("MaxConns = %d, want %d", cfg.MaxConns, tt.want)
+			}
+		})
+	}
+}
+
+func TestMinConnsAgainstSizedMaxConns(t *testing.T) {
+	srv := newFakeServer(t)
+	srv.rows["SHOW max_connections"] = "100"
+	ctx := context.Background()
+
+	tests := []struct {
+		name     string
+		minConns uint8
+		percent  float64
+		wantErr  string
+	}{
+		{name: "auto", minConns: 2},
+		{name: "auto exceeded", minConns: 200, wantErr: "sized from GOMAXPROCS"},
+		{name: "server", minConns: 10, percent: 10},
+		{name: "server exceeded", minConns: 11, percent: 10, wantErr: "10% of the server's max_connections"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := srv.configMap(t)
+			config.MinConns = tt.minConns
+			config.MaxConnsPercent = tt.percent
+
+			p, err := NewFromCfgMap(ctx, config, nil)
+			if err == nil {
+				p.Close()
+			}
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Fatalf("NewFromCfgMap() = %v, want no error", err)
+			case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+				t.Fatalf("NewFromCfgMap() = %v, want an error with %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSizeByServer(t *testing.T) {
+	tests := []struct {
+		name    string
+		max     string
+		percent float64
+		want    int32
+		wantErr string
+	}{
+		{name: "quarter", max: "80", percent: 25, want: 20},
+		{name: "all", max: "80", percent: 100, want: 80},
+		{name: "unparseable", max: "lots", percent: 25, wantErr: `invalid max_connections "lots"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newFakeServer(t)
+			srv.rows["SHOW max_connections"] = tt.max
+			config := srv.configMap(t)
+			config.MaxConns, config.MaxConnsPercent = 0, tt.percent
+
+			p, err := NewFromCfgMap(context.Background(), config, nil)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("NewFromCfgMap() = %v, want an error with %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer p.Close()
+			if got := p.Config().MaxConns; got != tt.want {
+				t.Fatalf("MaxConns = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
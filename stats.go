@@ -0,0 +1,74 @@
+package pgxtls
+
+import (
+	"expvar"
+	"time"
+
+	pool "github.com/jackc/pgx/v4/pgxpool"
+)
+
+// PoolStats is a plain copy of a pool's statistics, see pool.Stat
+// for what each field counts
+type PoolStats struct {
+	AcquireCount            int64         `json:"acquire_count"`
+	AcquireDuration         time.Duration `json:"acquire_duration"`
+	AcquiredConns           int32         `json:"acquired_conns"`
+	CanceledAcquireCount    int64         `json:"canceled_acquire_count"`
+	ConstructingConns       int32         `json:"constructing_conns"`
+	EmptyAcquireCount       int64         `json:"empty_acquire_count"`
+	IdleConns               int32         `json:"idle_conns"`
+	MaxConns                int32         `json:"max_conns"`
+	TotalConns              int32         `json:"total_conns"`
+	NewConnsCount           int64         `json:"new_conns_count"`
+	MaxLifetimeDestroyCount int64         `json:"max_lifetime_destroy_count"`
+	MaxIdleDestroyCount     int64         `json:"max_idle_destroy_count"`
+}
+
+// Snapshot returns the current statistics of p
+func Snapshot(p *pool.Pool) PoolStats {
+	return snapshot(p.Stat())
+}
+
+// PublishExpvar publishes the statistics of p as the expvar name, read
+// afresh on every request of /debug/vars. Like expvar.Publish it panics
+// if name is already in use
+func PublishExpvar(name string, p *pool.Pool) {
+	expvar.Publish(name, expvar.Func(func() interface{} {
+		return Snapshot(p)
+	}))
+}
+
+func snapshot(s *pool.Stat) PoolStats {
+	return PoolStats{
+		AcquireCount:            s.AcquireCount(),
+		AcquireDuration:         s.AcquireDuration(),
+		AcquiredConns:           s.AcquiredConns(),
+		CanceledAcquireCount:    s.CanceledAcquireCount(),
+		ConstructingConns:       s.ConstructingConns(),
+		EmptyAcquireCount:       s.EmptyAcquireCount(),
+		IdleConns:               s.IdleConns(),
+		MaxConns:                s.MaxConns(),
+		TotalConns:              s.TotalConns(),
+		NewConnsCount:           s.NewConnsCount(),
+		MaxLifetimeDestroyCount: s.MaxLifetimeDestroyCount(),
+		MaxIdleDestroyCount:     s.MaxIdleDestroyCount(),
+	}
+}
+
+// add returns the sum of s and o, with durations and counts added up
+func (s PoolStats) add(o PoolStats) PoolStats {
+	return PoolStats{
+		AcquireCount:            s.AcquireCount + o.AcquireCount,
+		AcquireDuration:         s.AcquireDuration + o.AcquireDuration,
+		AcquiredConns:           s.AcquiredConns + o.AcquiredConns,
+		CanceledAcquireCount:    s.CanceledAcquireCount + o.CanceledAcquireCount,
+		ConstructingConns:       s.ConstructingConns + o.ConstructingConns,
+		EmptyAcquireCount:       s.EmptyAcquireCount + o.EmptyAcquireCount,
+		IdleConns:               s.IdleConns + o.IdleConns,
+		MaxConns:                s.MaxConns + o.MaxConns,
+		TotalConns:              s.TotalConns + o.TotalConns,
+		NewConnsCount:           s.NewConnsCount + o.NewConnsCount,
+		MaxLifetimeDestroyCount: s.MaxLifetimeDestroyCount + o.MaxLifetimeDestroyCount,
+		MaxIdleDestroyCount:     s.MaxIdleDestroyCount + o.MaxIdleDestroyCount,
+	}
+}
@@ -0,0 +1,115 @@
+package pgxtls
+
+import (
+	"context"
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"testing"
+
+	pool "github.com/jackc/pgx/v4/pgxpool"
+)
+
+func TestSnapshot(t *testing.T) {
+	srv := newFakeServer(t)
+	ctx := context.Background()
+
+	cfg := srv.poolConfig(t)
+	cfg.MaxConns = 3
+	p, err := pool.ConnectConfig(ctx, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	tests := []struct {
+		name         string
+		acquire      int
+		wantAcquired int32
+	}{
+		{"idle", 0, 0},
+		{"one acquired", 1, 1},
+		{"all acquired", 3, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for i := 0; i < tt.acquire; i++ {
+				conn, err := p.Acquire(ctx)
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer conn.Release()
+			}
+
+			s := Snapshot(p)
+			if s.AcquiredConns != tt.wantAcquired || s.MaxConns != 3 || s.TotalConns < tt.wantAcquired {
+				t.Fatalf("Snapshot() = %+v", s)
+			}
+		})
+	}
+}
+
+var expvarRuns int
+
+func TestPublishExpvar(t *testing.T) {
+	srv := newFakeServer(t)
+	ctx := context.Background()
+	p, err := pool.ConnectConfig(ctx, srv.poolConfig(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	// expvar names can't be reused, also by reruns of the test
+	expvarRuns++
+	name := fmt.Sprintf("pgxtls_test_pool_%d", expvarRuns)
+	PublishExpvar(name, p)
+	published := func() PoolStats {
+		var s PoolStats
+		if err := json.Unmarshal([]byte(expvar.Get(name).String()), &s); err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+
+	before := published()
+	if err := p.Ping(ctx); err != nil {
+		t.Fatal(err)
+	}
+	// read afresh on every request
+	if after := published(); after.AcquireCount != before.AcquireCount+1 || after.TotalConns != 1 {
+		t.Fatalf("published %+v after %+v", after, before)
+	}
+}
+
+func TestPublishExpvarNameInUse(t *testing.T) {
+	srv := newFakeServer(t)
+	p, err := pool.ConnectConfig(context.Background(), srv.poolConfig(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	expvarRuns++
+	taken := fmt.Sprintf("pgxtls_test_taken_%d", expvarRuns)
+	expvar.NewInt(taken)
+
+	tests := []struct {
+		name      string
+		expvar    string
+		wantPanic bool
+	}{
+		{"free", fmt.Sprintf("pgxtls_test_free_%d", expvarRuns), false},
+		{"in use", taken, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func() {
+				if panicked := recover() != nil; panicked != tt.wantPanic {
+					t.Fatalf("PublishExpvar(%q) panicked %v, want %v", tt.expvar, panicked, tt.wantPanic)
+				}
+			}()
+			PublishExpvar(tt.expvar, p)
+		})
+	}
+}
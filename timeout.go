@@ -0,0 +1,95 @@
+package pgxtls
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+)
+
+// Querier runs queries, it is satisfied by *pool.Pool, *pgx.Conn and pgx.Tx
+type Querier interface {
+	Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row
+	Exec(ctx context.Context, sql string, arguments ...interface{}) (pgconn.CommandTag, error)
+}
+
+// QueryRowWithTimeout runs q.QueryRow, cancelling the query if it hasn't
+// been scanned within d
+func QueryRowWithTimeout(ctx context.Context, q Querier, d time.Duration, sql string, args ...interface{}) pgx.Row {
+	ctx, cancel := context.WithTimeout(ctx, d)
+	return &timeoutRow{Row: q.QueryRow(ctx, sql, args...), cancel: cancel}
+}
+
+type timeoutRow struct {
+	pgx.Row
+	cancel context.CancelFunc
+}
+
+func (r *timeoutRow) Scan(dest ...interface{}) error {
+	defer r.cancel()
+	return r.Row.Scan(dest...)
+}
+
+// QueryWithTimeout runs q.Query, cancelling the query if its rows
+// haven't been read within d
+func QueryWithTimeout(ctx context.Context, q Querier, d time.Duration, sql string, args ...interface{}) (pgx.Rows, error) {
+	ctx, cancel := context.WithTimeout(ctx, d)
+	rows, err := q.Query(ctx, sql, args...)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	return &timeoutRows{Rows: rows, cancel: cancel}, nil
+}
+
+type timeoutRows struct {
+	pgx.Rows
+	cancel context.CancelFunc
+}
+
+func (r *timeoutRows) Next() bool {
+	if r.Rows.Next() {
+		return true
+	}
+	r.cancel()
+	return false
+}
+
+func (r *timeoutRows) Close() {
+	r.Rows.Close()
+	r.cancel()
+}
+
+// ExecWithTimeout runs q.Exec, cancelling it if it takes longer than d
+func ExecWithTimeout(ctx context.Context, q Querier, d time.Duration, sql string, args ...interface{}) (pgconn.CommandTag, error) {
+	ctx, cancel := context.WithTimeout(ctx, d)
+	defer cancel()
+	return q.Exec(ctx, sql, args...)
+}
+
+// DefaultQueryTimeout returns a Querier running queries on q with the
+// timeouts of QueryRowWithTimeout, QueryWithTimeout and ExecWithTimeout,
+// so every query is bounded by d without passing it each time. A query
+// whose ctx has an earlier deadline keeps it
+func DefaultQueryTimeout(q Querier, d time.Duration) Querier {
+	return &defaultTimeout{q: q, d: d}
+}
+
+type defaultTimeout struct {
+	q Querier
+	d time.Duration
+}
+
+func (t *defaultTimeout) Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error) {
+	return QueryWithTimeout(ctx, t.q, t.d, sql, args...)
+}
+
+func (t *defaultTimeout) QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row {
+	return QueryRowWithTimeout(ctx, t.q, t.d, sql, args...)
+}
+
+func (t *defaultTimeout) Exec(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error) {
+	return ExecWithTimeout(ctx, t.q, t.d, sql, args...)
+}
@@ -0,0 +1,165 @@
+package pgxtls
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+)
+
+// ctxQuerier records the context each query is run with
+type ctxQuerier struct {
+	ctx  context.Context
+	rows int
+	err  error
+}
+
+func (q *ctxQuerier) Query(ctx context.Context, _ string, _ ...interface{}) (pgx.Rows, error) {
+	q.ctx = ctx
+	if q.err != nil {
+		return nil, q.err
+	}
+	return &countRows{n: q.rows}, nil
+}
+
+func (q *ctxQuerier) QueryRow(ctx context.Context, _ string, _ ...interface{}) pgx.Row {
+	q.ctx = ctx
+	return errRow{q.err}
+}
+
+func (q *ctxQuerier) Exec(ctx context.Context, _ string, _ ...interface{}) (pgconn.CommandTag, error) {
+	q.ctx = ctx
+	return pgconn.CommandTag("UPDATE 1"), q.err
+}
+
+// countRows yields n rows
+type countRows struct {
+	pgx.Rows
+	n int
+}
+
+func (r *countRows) Next() bool {
+	r.n--
+	return r.n >= 0
+}
+
+func (r *countRows) Close() {}
+
+type errRow struct{ err error }
+
+func (r errRow) Scan(...interface{}) error { return r.err }
+
+func TestQueryTimeouts(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		name string
+		run  func(q Querier) error
+	}{
+		{"QueryRow", func(q Querier) error {
+			return QueryRowWithTimeout(ctx, q, time.Minute, "SELECT 1").Scan()
+		}},
+		{"Query read", func(q Querier) error {
+			rows, err := QueryWithTimeout(ctx, q, time.Minute, "SELECT 1")
+			if err != nil {
+				return err
+			}
+			for rows.Next() {
+			}
+			return nil
+		}},
+		{"Query closed", func(q Querier) error {
+			rows, err := QueryWithTimeout(ctx, q, time.Minute, "SELECT 1")
+			if err != nil {
+				return err
+			}
+			rows.Next()
+			rows.Close()
+			return nil
+		}},
+		{"Exec", func(q Querier) error {
+			_, err := ExecWithTimeout(ctx, q, time.Minute, "UPDATE jobs SET done = true")
+			return err
+		}},
+		{"DefaultQueryTimeout QueryRow", func(q Querier) error {
+			return DefaultQueryTimeout(q, time.Minute).QueryRow(ctx, "SELECT 1").Scan()
+		}},
+		{"DefaultQueryTimeout Query", func(q Querier) error {
+			rows, err := DefaultQueryTimeout(q, time.Minute).Query(ctx, "SELECT 1")
+			if err != nil {
+				return err
+			}
+			rows.Close()
+			return nil
+		}},
+		{"DefaultQueryTimeout Exec", func(q Querier) error {
+			_, err := DefaultQueryTimeout(q, time.Minute).Exec(ctx, "UPDATE jobs SET done = true")
+			return err
+		}},
+	}
+	for _, tt := range tests {
+		for _, queryErr := range []error{nil, errors.New("conn closed")} {
+			name := tt.name
+			if queryErr != nil {
+				name += " failing"
+			}
+			t.Run(name, func(t *testing.T) {
+				q := &ctxQuerier{rows: 2, err: queryErr}
+				if err := tt.run(q); err != queryErr {
+					t.Fatalf("got %v, want %v", err, queryErr)
+				}
+
+				deadline, ok := q.ctx.Deadline()
+				if !ok || time.Until(deadline) > time.Minute {
+					t.Fatalf("query ran with deadline %v, %v", deadline, ok)
+				}
+				// the timeout's resources are released once the query is done
+				if q.ctx.Err() != context.Canceled {
+					t.Fatalf("query context is %v once done, want canceled", q.ctx.Err())
+				}
+			})
+		}
+	}
+}
+
+func TestQueryRowsStayOpenUntilRead(t *testing.T) {
+	q := &ctxQuerier{rows: 2}
+	rows, err := QueryWithTimeout(context.Background(), q, time.Minute, "SELECT 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !rows.Next() || q.ctx.Err() != nil {
+		t.Fatal("rows canceled before they were read")
+	}
+	rows.Close()
+}
+
+func TestDefaultQueryTimeoutKeepsEarlierDeadline(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	want, _ := ctx.Deadline()
+
+	tests := []struct {
+		name string
+		run  func(q Querier)
+	}{
+		{"QueryRow", func(q Querier) { q.QueryRow(ctx, "SELECT 1").Scan() }},
+		{"Query", func(q Querier) {
+			if rows, err := q.Query(ctx, "SELECT 1"); err == nil {
+				rows.Close()
+			}
+		}},
+		{"Exec", func(q Querier) { q.Exec(ctx, "VACUUM") }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := &ctxQuerier{}
+			tt.run(DefaultQueryTimeout(q, time.Hour))
+			if got, _ := q.ctx.Deadline(); !got.Equal(want) {
+				t.Fatalf("deadline %v, want the caller's %v", got, want)
+			}
+		})
+	}
+}
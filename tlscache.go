@@ -0,0 +1,125 @@
+package pgxtls
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/danvixent/pgxtls/config"
+)
+
+// fileStamp identifies a version of a file's contents
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+func stampFile(path string) (fileStamp, bool) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fileStamp{}, false
+	}
+	return fileStamp{modTime: info.ModTime(), size: info.Size()}, true
+}
+
+type cachedCert struct {
+	cert, chain, key fileStamp
+	passphrase       [sha256.Size]byte
+	value            *tls.Certificate
+}
+
+type cachedPool struct {
+	stamp      fileStamp
+	passphrase [sha256.Size]byte
+	value      *x509.CertPool
+}
+
+// tlsCache holds the tls material parsed from files so pools created
+// repeatedly from the same files, as in test suites, skip rereading
+// and decrypting them. An entry is replaced once its files are modified
+var tlsCache = struct {
+	sync.Mutex
+	certs map[[3]string]cachedCert
+	pools map[string]cachedPool
+}{
+	certs: make(map[[3]string]cachedCert),
+	pools: make(map[string]cachedPool),
+}
+
+// cachedClientCert is loadClientCert for src, reusing a previous result
+// while the certificate and key files are unchanged
+func cachedClientCert(ctx context.Context, src FileSecretSource) (*tls.Certificate, error) {
+	if src.inMemory() {
+		return loadClientCert(ctx, src)
+	}
+
+	certStamp, ok1 := stampFile(src.CertFile)
+	keyStamp, ok2 := stampFile(src.KeyFile)
+	if !ok1 || !ok2 {
+		return loadClientCert(ctx, src)
+	}
+
+	var chainStamp fileStamp
+	if src.ChainFile != "" {
+		var ok bool
+		if chainStamp, ok = stampFile(src.ChainFile); !ok {
+			return loadClientCert(ctx, src)
+		}
+	}
+
+	key := [3]string{src.CertFile, src.ChainFile, src.KeyFile}
+	sum := sha256.Sum256([]byte(src.Passphrase))
+
+	tlsCache.Lock()
+	c, ok := tlsCache.certs[key]
+	tlsCache.Unlock()
+	if ok && c.cert == certStamp && c.chain == chainStamp && c.key == keyStamp && c.passphrase == sum {
+		return c.value, nil
+	}
+
+	cert, err := loadClientCert(ctx, src)
+	if err != nil {
+		return nil, err
+	}
+
+	tlsCache.Lock()
+	tlsCache.certs[key] = cachedCert{cert: certStamp, chain: chainStamp, key: keyStamp, passphrase: sum, value: cert}
+	tlsCache.Unlock()
+	return cert, nil
+}
+
+// cachedRootCAs is loadRootCAs for src, reusing a previous result
+// while the ca file and the passphrase decrypting it are unchanged
+func cachedRootCAs(ctx context.Context, src FileSecretSource, mode config.SSLMode) (*x509.CertPool, error) {
+	if src.inMemory() {
+		return loadRootCAs(ctx, src, mode)
+	}
+
+	stamp, ok := stampFile(src.CAFile)
+	if !ok {
+		return loadRootCAs(ctx, src, mode)
+	}
+
+	sum := sha256.Sum256([]byte(src.CAPassphrase))
+
+	tlsCache.Lock()
+	p, ok := tlsCache.pools[src.CAFile]
+	tlsCache.Unlock()
+	if ok && p.stamp == stamp && p.passphrase == sum {
+		return p.value, nil
+	}
+
+	pool, err := loadRootCAs(ctx, src, mode)
+	if err != nil {
+		return nil, err
+	}
+
+	tlsCache.Lock()
+	tlsCache.pools[src.CAFile] = cachedPool{stamp: stamp, passphrase: sum, value: pool}
+	tlsCache.Unlock()
+	return pool, nil
+}
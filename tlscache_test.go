@@ -0,0 +1,170 @@
+package pgxtls
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/danvixent/pgxtls/config"
+)
+
+// testFileSecrets writes a client certificate, its key and a ca
+// encrypted with passphrase to a temporary directory
+func testFileSecrets(t testing.TB, passphrase string) FileSecretSource {
+	t.Helper()
+	ca := newTestCA(t)
+	certPEM, keyPEM := ca.issue(t, clientTemplate("user"))
+
+	dir := t.TempDir()
+	return FileSecretSource{
+		CertFile:     writeFile(t, dir, "client.crt", certPEM),
+		KeyFile:      writeFile(t, dir, "client.key", encryptPEM(t, keyPEM, passphrase)),
+		CAFile:       writeFile(t, dir, "ca.crt", encryptPEM(t, ca.pem, passphrase)),
+		Passphrase:   passphrase,
+		CAPassphrase: passphrase,
+	}
+}
+
+// touch moves the modification time of path forward
+func touch(t *testing.T, path string) {
+	t.Helper()
+	later := time.Now().Add(time.Hour)
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCachedClientCert(t *testing.T) {
+	ctx := context.Background()
+	src := testFileSecrets(t, "secret")
+
+	first, err := cachedClientCert(ctx, src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again, err := cachedClientCert(ctx, src); err != nil || again != first {
+		t.Fatalf("unchanged files were parsed again (err %v)", err)
+	}
+
+	wrong := src
+	wrong.Passphrase = "wrong"
+	// legacy PEM encryption can't always tell a wrong passphrase
+	// apart from a corrupt file, so any error will do
+	if _, err := cachedClientCert(ctx, wrong); err == nil {
+		t.Fatal("wrong passphrase was served from the cache")
+	}
+
+	touch(t, src.KeyFile)
+	if again, err := cachedClientCert(ctx, src); err != nil || again == first {
+		t.Fatalf("modified key file was served from the cache (err %v)", err)
+	}
+}
+
+func TestCachedRootCAs(t *testing.T) {
+	ctx := context.Background()
+	src := testFileSecrets(t, "secret")
+
+	first, err := cachedRootCAs(ctx, src, config.SSLModeVerifyFull)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again, err := cachedRootCAs(ctx, src, config.SSLModeVerifyFull); err != nil || again != first {
+		t.Fatalf("unchanged ca file was parsed again (err %v)", err)
+	}
+
+	tests := []struct {
+		name       string
+		passphrase string
+	}{
+		{"wrong passphrase", "wrong"},
+		{"no passphrase", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			other := src
+			other.CAPassphrase = tt.passphrase
+			if _, err := cachedRootCAs(ctx, other, config.SSLModeVerifyFull); err == nil {
+				t.Fatal("ca pool decrypted with another passphrase was served from the cache")
+			}
+		})
+	}
+
+	touch(t, src.CAFile)
+	if again, err := cachedRootCAs(ctx, src, config.SSLModeVerifyFull); err != nil || again == first {
+		t.Fatalf("modified ca file was served from the cache (err %v)", err)
+	}
+}
+
+func BenchmarkTLSMaterial(b *testing.B) {
+	ctx := context.Background()
+	src := testFileSecrets(b, "secret")
+
+	benchmarks := []struct {
+		name string
+		load func() error
+	}{
+		{"uncached", func() error {
+			if _, err := loadRootCAs(ctx, src, config.SSLModeVerifyFull); err != nil {
+				return err
+			}
+			_, err := loadClientCert(ctx, src)
+			return err
+		}},
+		{"cached", func() error {
+			if _, err := cachedRootCAs(ctx, src, config.SSLModeVerifyFull); err != nil {
+				return err
+			}
+			_, err := cachedClientCert(ctx, src)
+			return err
+		}},
+	}
+	for _, bm := range benchmarks {
+		b.Run(bm.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if err := bm.load(); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestCachedClientCertChain(t *testing.T) {
+	ctx := context.Background()
+	ca := newTestCA(t)
+	certPEM, keyPEM := ca.issue(t, clientTemplate("user"))
+
+	dir := t.TempDir()
+	src := FileSecretSource{
+		CertFile:  writeFile(t, dir, "client.crt", certPEM),
+		ChainFile: writeFile(t, dir, "chain.crt", ca.pem),
+		KeyFile:   writeFile(t, dir, "client.key", keyPEM),
+	}
+
+	tests := []struct {
+		name     string
+		modified func() string
+	}{
+		{"modified chain", func() string { return src.ChainFile }},
+		{"modified cert", func() string { return src.CertFile }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			first, err := cachedClientCert(ctx, src)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// the intermediates follow the leaf
+			if len(first.Certificate) != 2 || !bytes.Equal(first.Certificate[1], ca.cert.Raw) {
+				t.Fatalf("got %d certificates, want the leaf and the chain", len(first.Certificate))
+			}
+
+			touch(t, tt.modified())
+			if again, err := cachedClientCert(ctx, src); err != nil || again == first {
+				t.Fatalf("%s was served from the cache (err %v)", tt.name, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package pgxtls
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+
+	"github.com/jackc/pgx/v4"
+	pool "github.com/jackc/pgx/v4/pgxpool"
+)
+
+// TLSConfigProvider returns the tls.Config to connect to host with, given
+// a copy of the one the pool would use. It is called for every
+// connection, letting certificates or settings differ between them
+type TLSConfigProvider func(ctx context.Context, host string, base *tls.Config) (*tls.Config, error)
+
+// WithTLSConfigProvider calls provider for the tls.Config of each new
+// connection, to every host it is attempted on with TLS
+func WithTLSConfigProvider(provider TLSConfigProvider) Option {
+	return func(o *options) {
+		o.tlsProvider = provider
+	}
+}
+
+// provideTLS installs provider on cfg, consulted before each connection
+func provideTLS(cfg *pool.Config, provider TLSConfigProvider) {
+	before := cfg.BeforeConnect
+	cfg.BeforeConnect = func(ctx context.Context, cc *pgx.ConnConfig) error {
+		if before != nil {
+			if err := before(ctx, cc); err != nil {
+				return err
+			}
+		}
+
+		var err error
+		if cc.TLSConfig != nil {
+			if cc.TLSConfig, err = provider(ctx, cc.Host, cc.TLSConfig.Clone()); err != nil {
+				return fmt.Errorf("unable to provide tls config for %s: %v", cc.Host, err)
+			}
+		}
+		for _, fb := range cc.Fallbacks {
+			if fb.TLSConfig == nil {
+				continue
+			}
+			if fb.TLSConfig, err = provider(ctx, fb.Host, fb.TLSConfig.Clone()); err != nil {
+				return fmt.Errorf("unable to provide tls config for %s: %v", fb.Host, err)
+			}
+		}
+		return nil
+	}
+}
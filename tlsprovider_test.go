@@ -0,0 +1,120 @@
+package pgxtls
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+	pool "github.com/jackc/pgx/v4/pgxpool"
+)
+
+func TestProvideTLS(t *testing.T) {
+	newConfig := func() *pgx.ConnConfig {
+		cc := &pgx.ConnConfig{}
+		cc.Host, cc.TLSConfig = "a.example.com", &tls.Config{ServerName: "a.example.com"}
+		cc.Fallbacks = []*pgconn.FallbackConfig{
+			{Host: "a.example.com"},
+			{Host: "b.example.com", TLSConfig: &tls.Config{ServerName: "b.example.com"}},
+		}
+		return cc
+	}
+
+	tests := []struct {
+		name         string
+		before       func(context.Context, *pgx.ConnConfig) error
+		failFor      string
+		wantHosts    []string
+		wantErr      string
+		wantProvided bool
+	}{
+		{name: "every tls attempt", wantHosts: []string{"a.example.com", "b.example.com"}, wantProvided: true},
+		{name: "after BeforeConnect", before: func(_ context.Context, cc *pgx.ConnConfig) error {
+			cc.Fallbacks = cc.Fallbacks[:1]
+			return nil
+		}, wantHosts: []string{"a.example.com"}, wantProvided: true},
+		{name: "BeforeConnect fails", before: func(context.Context, *pgx.ConnConfig) error {
+			return errors.New("no password")
+		}, wantErr: "no password"},
+		{name: "provider fails", failFor: "b.example.com", wantHosts: []string{"a.example.com", "b.example.com"}, wantErr: "unable to provide tls config for b.example.com: no certificate"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var hosts []string
+			cfg := &pool.Config{BeforeConnect: tt.before}
+			provideTLS(cfg, func(_ context.Context, host string, base *tls.Config) (*tls.Config, error) {
+				hosts = append(hosts, host)
+				if host == tt.failFor {
+					return nil, errors.New("no certificate")
+				}
+				if base.ServerName != host {
+					t.Errorf("provider for %s given the tls.Config of %s", host, base.ServerName)
+				}
+				base.NextProtos = []string{"provided"}
+				return base, nil
+			})
+
+			cc := newConfig()
+			orig := cc.TLSConfig
+			err := cfg.BeforeConnect(context.Background(), cc)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("BeforeConnect() = %v, want %q", err, tt.wantErr)
+				}
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			if fmt.Sprint(hosts) != fmt.Sprint(tt.wantHosts) {
+				t.Fatalf("provider called for %v, want %v", hosts, tt.wantHosts)
+			}
+			if len(orig.NextProtos) != 0 {
+				t.Fatal("provider changed the pool's tls.Config rather than a copy")
+			}
+			if !tt.wantProvided {
+				return
+			}
+			if len(cc.TLSConfig.NextProtos) == 0 || cc.Fallbacks[0].TLSConfig != nil {
+				t.Fatal("provided tls.Configs not installed")
+			}
+		})
+	}
+}
+
+func TestWithTLSConfigProvider(t *testing.T) {
+	srv := newFakeServer(t)
+	c := tlsConfigMap(t, srv)
+	ctx := context.Background()
+
+	var mu sync.Mutex
+	var hosts []string
+	p, err := NewFromCfgMap(ctx, c, nil, WithTLSConfigProvider(func(_ context.Context, host string, base *tls.Config) (*tls.Config, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		hosts = append(hosts, host)
+		return base, nil
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	conn, err := p.Acquire(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Release()
+	if _, ok := conn.Conn().PgConn().Conn().(*tls.Conn); !ok {
+		t.Fatal("connected without tls")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(hosts) == 0 || !strings.HasPrefix(srv.addr(), hosts[0]+":") {
+		t.Fatalf("provider called for %v, want %s", hosts, srv.addr())
+	}
+}
@@ -0,0 +1,159 @@
+package pgxtls
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	pool "github.com/jackc/pgx/v4/pgxpool"
+)
+
+// limitHandshake bounds the TLS handshake of every connection cfg makes to
+// timeout, apart from the rest of connecting. pgconn upgrades connections
+// itself, so each connection gets a net.Conn that starts a timer when the
+// server agrees to TLS and a tls.Config whose VerifyConnection stops it
+func limitHandshake(cfg *pool.Config, timeout time.Duration) {
+	before := cfg.BeforeConnect
+	cfg.BeforeConnect = func(ctx context.Context, cc *pgx.ConnConfig) error {
+		if before != nil {
+			if err := before(ctx, cc); err != nil {
+				return err
+			}
+		}
+
+		var (
+			mu      sync.Mutex
+			current *handshakeConn
+		)
+		done := func(tls.ConnectionState) error {
+			mu.Lock()
+			defer mu.Unlock()
+			if current != nil {
+				current.finish()
+			}
+			return nil
+		}
+
+		dial := cc.DialFunc
+		cc.DialFunc = func(ctx context.Context, network, addr string) (net.Conn, error) {
+			conn, err := dial(ctx, network, addr)
+			if err != nil {
+				return nil, err
+			}
+			hc := &handshakeConn{Conn: conn, timeout: timeout}
+			mu.Lock()
+			current = hc
+			mu.Unlock()
+			return hc, nil
+		}
+
+		cc.TLSConfig = onHandshake(cc.TLSConfig, done)
+		for _, fb := range cc.Fallbacks {
+			fb.TLSConfig = onHandshake(fb.TLSConfig, done)
+		}
+		return nil
+	}
+}
+
+// onHandshake returns a copy of tlsCfg also calling fn once the
+// server is verified, or nil if tlsCfg is nil
+func onHandshake(tlsCfg *tls.Config, fn func(tls.ConnectionState) error) *tls.Config {
+	if tlsCfg == nil {
+		return nil
+	}
+	tlsCfg = tlsCfg.Clone()
+	verify := tlsCfg.VerifyConnection
+	tlsCfg.VerifyConnection = func(cs tls.ConnectionState) error {
+		fn(cs)
+		if verify != nil {
+			return verify(cs)
+		}
+		return nil
+	}
+	return tlsCfg
+}
+
+// handshakeConn is a net.Conn interrupting a TLS handshake that takes
+// longer than timeout
+type handshakeConn struct {
+	net.Conn
+	timeout time.Duration
+
+	mu        sync.Mutex
+	requested bool
+	timer     *time.Timer
+	finished  bool
+	expired   bool
+}
+
+func (c *handshakeConn) Write(b []byte) (int, error) {
+	if len(b) == 8 && binary.BigEndian.Uint32(b[4:8]) == sslRequestCode {
+		c.mu.Lock()
+		c.requested = true
+		c.mu.Unlock()
+	}
+	n, err := c.Conn.Write(b)
+	return n, c.wrap(err)
+}
+
+func (c *handshakeConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+
+	c.mu.Lock()
+	if c.requested && n == 1 && b[0] == 'S' {
+		// the handshake starts with the next write
+		c.requested = false
+		c.timer = time.AfterFunc(c.timeout, c.expire)
+	}
+	c.mu.Unlock()
+
+	return n, c.wrap(err)
+}
+
+func (c *handshakeConn) Close() error {
+	c.finish()
+	return c.Conn.Close()
+}
+
+// NetConn returns the connection c wraps
+func (c *handshakeConn) NetConn() net.Conn {
+	return c.Conn
+}
+
+// expire interrupts the handshake in progress
+func (c *handshakeConn) expire() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.finished {
+		c.expired = true
+		c.Conn.SetDeadline(time.Unix(1, 0))
+	}
+}
+
+// finish stops the handshake timer
+func (c *handshakeConn) finish() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.finished = true
+	if c.timer != nil {
+		c.timer.Stop()
+	}
+}
+
+// wrap replaces err with one naming the timeout when the handshake timed out
+func (c *handshakeConn) wrap(err error) error {
+	if err == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.expired {
+		return fmt.Errorf("tls handshake timed out after %s: %v", c.timeout, err)
+	}
+	return err
+}
@@ -0,0 +1,104 @@
+package pgxtls
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/danvixent/pgxtls/config"
+)
+
+// tlsConfigMap makes s accept tls with a certificate for db.example.com
+// and returns a config verifying it
+func tlsConfigMap(t *testing.T, s *fakeServer) *config.ConfigMap {
+	t.Helper()
+	ca := newTestCA(t)
+	certPEM, keyPEM := ca.issue(t, serverTemplate("db.example.com"))
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.tls = &tls.Config{Certificates: []tls.Certificate{cert}}
+
+	c := s.configMap(t)
+	c.SSLMode, c.SSLHostname = config.SSLModeVerifyFull, "db.example.com"
+	c.SSLCAFile = writeFile(t, t.TempDir(), "ca.crt", ca.pem)
+	return c
+}
+
+func TestSSLHandshakeTimeout(t *testing.T) {
+	srv := newFakeServer(t)
+	c := tlsConfigMap(t, srv)
+	c.SSLHandshakeTimeout = 50 * time.Millisecond
+	c.MaxConns = 1
+	ctx := context.Background()
+
+	p, err := NewFromCfgMap(ctx, c, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	conn, err := p.Acquire(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Release()
+	if _, ok := conn.Conn().PgConn().Conn().(*tls.Conn); !ok {
+		t.Fatal("connected without tls")
+	}
+
+	// the timer stops once the handshake is done
+	time.Sleep(100 * time.Millisecond)
+	if _, err := conn.Exec(ctx, "VACUUM"); err != nil {
+		t.Fatalf("connection broken once the timeout passed: %v", err)
+	}
+}
+
+func TestSSLHandshakeTimeoutExpires(t *testing.T) {
+	// agrees to tls but never completes the handshake
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				io.ReadFull(conn, make([]byte, 8))
+				conn.Write([]byte{'S'})
+				io.Copy(io.Discard, conn)
+			}()
+		}
+	}()
+
+	srv := newFakeServer(t)
+	c := tlsConfigMap(t, srv)
+	host, port, _ := net.SplitHostPort(ln.Addr().String())
+	n, _ := strconv.Atoi(port)
+	c.DbHost, c.DbPort = host, uint16(n)
+
+	c.SSLHandshakeTimeout = 50 * time.Millisecond
+	start := time.Now()
+	p, err := NewFromCfgMap(context.Background(), c, nil)
+	if err == nil {
+		p.Close()
+	}
+	if err == nil || !strings.Contains(err.Error(), "tls handshake timed out after 50ms") {
+		t.Fatalf("NewFromCfgMap() = %v, want the handshake timeout", err)
+	}
+	// rather than the minute connecting may take
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("handshake gave up after %v", elapsed)
+	}
+}
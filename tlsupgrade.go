@@ -0,0 +1,125 @@
+package pgxtls
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	pool "github.com/jackc/pgx/v4/pgxpool"
+)
+
+// TLSUpgradeFunc negotiates TLS on conn, freshly dialed to the server,
+// and returns the encrypted connection to speak the protocol over. For
+// custom proxies that need control over the SSLRequest exchange
+type TLSUpgradeFunc func(ctx context.Context, conn net.Conn, tlsCfg *tls.Config) (net.Conn, error)
+
+// DefaultTLSUpgrade upgrades conn as pgx does, sending an SSLRequest
+// and starting the handshake once the server agrees
+func DefaultTLSUpgrade(ctx context.Context, conn net.Conn, tlsCfg *tls.Config) (net.Conn, error) {
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+		defer conn.SetDeadline(time.Time{})
+	}
+
+	if err := requestSSL(conn); err != nil {
+		return nil, err
+	}
+
+	tlsConn := tls.Client(conn, tlsCfg)
+	if err := tlsConn.Handshake(); err != nil {
+		return nil, err
+	}
+	return tlsConn, nil
+}
+
+// WithTLSUpgrade negotiates TLS on every connection with fn instead of
+// pgx. pgx can't fall back to unencrypted connections then, so it needs
+// an sslmode of require, verify-ca or verify-full. SSLHandshakeTimeout
+// doesn't apply, fn bounds the handshake itself
+func WithTLSUpgrade(fn TLSUpgradeFunc) Option {
+	return func(o *options) {
+		o.tlsUpgrade = fn
+	}
+}
+
+// upgradeTLS makes the connections of cfg negotiate TLS with fn. pgconn
+// has no hook for it, so the tls.Configs are taken from it before each
+// connection, leaving it to speak plaintext over the connections its
+// DialFunc returns, and fn runs in the DialFunc. pgconn dials resolved
+// addresses, so the hosts they were looked up from are recorded to find
+// each one's tls.Config. The DialFunc stays in the connection's config,
+// so CancelQuery's connections are upgraded with the same tls.Config
+func upgradeTLS(cfg *pool.Config, fn TLSUpgradeFunc) {
+	before := cfg.BeforeConnect
+	cfg.BeforeConnect = func(ctx context.Context, cc *pgx.ConnConfig) error {
+		if before != nil {
+			if err := before(ctx, cc); err != nil {
+				return err
+			}
+		}
+
+		tlsByHost := map[string]*tls.Config{}
+		if cc.TLSConfig != nil {
+			tlsByHost[cc.Host] = cc.TLSConfig
+			cc.TLSConfig = nil
+		}
+		for _, fb := range cc.Fallbacks {
+			if fb.TLSConfig != nil {
+				tlsByHost[fb.Host] = fb.TLSConfig
+				fb.TLSConfig = nil
+			}
+		}
+		if len(tlsByHost) == 0 {
+			return nil
+		}
+
+		var (
+			mu       sync.Mutex
+			hostByIP = map[string]string{}
+		)
+		lookup := cc.LookupFunc
+		cc.LookupFunc = func(ctx context.Context, host string) ([]string, error) {
+			addrs, err := lookup(ctx, host)
+			mu.Lock()
+			for _, addr := range addrs {
+				if ip, _, err := net.SplitHostPort(addr); err == nil {
+					addr = ip
+				}
+				hostByIP[addr] = host
+			}
+			mu.Unlock()
+			return addrs, err
+		}
+
+		dial := cc.DialFunc
+		cc.DialFunc = func(ctx context.Context, network, addr string) (net.Conn, error) {
+			conn, err := dial(ctx, network, addr)
+			if err != nil || network == "unix" {
+				return conn, err
+			}
+
+			host, _, _ := net.SplitHostPort(addr)
+			mu.Lock()
+			if name, ok := hostByIP[host]; ok {
+				host = name
+			}
+			mu.Unlock()
+
+			tlsCfg, ok := tlsByHost[host]
+			if !ok {
+				return conn, nil
+			}
+
+			upgraded, err := fn(ctx, conn, tlsCfg)
+			if err != nil {
+				conn.Close()
+				return nil, err
+			}
+			return upgraded, nil
+		}
+		return nil
+	}
+}
@@ -0,0 +1,162 @@
+package pgxtls
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/danvixent/pgxtls/config"
+	pool "github.com/jackc/pgx/v4/pgxpool"
+)
+
+func TestWithTLSUpgrade(t *testing.T) {
+	tests := []struct {
+		name    string
+		sslMode config.SSLMode
+		fn      TLSUpgradeFunc
+		wantErr string
+	}{
+		{name: "default", fn: DefaultTLSUpgrade},
+		{name: "failing", fn: func(context.Context, net.Conn, *tls.Config) (net.Conn, error) {
+			return nil, errors.New("proxy refused")
+		}, wantErr: "proxy refused"},
+		{name: "plaintext sslmode", sslMode: config.SSLModeDisable, fn: DefaultTLSUpgrade, wantErr: "WithTLSUpgrade needs an sslmode of require, verify-ca or verify-full, not disable"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newFakeServer(t)
+			c := tlsConfigMap(t, srv)
+			if tt.sslMode != "" {
+				c.SSLMode = tt.sslMode
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			var mu sync.Mutex
+			upgrades := 0
+			p, err := NewFromCfgMap(ctx, c, nil, WithTLSUpgrade(func(ctx context.Context, conn net.Conn, tlsCfg *tls.Config) (net.Conn, error) {
+				mu.Lock()
+				upgrades++
+				mu.Unlock()
+				return tt.fn(ctx, conn, tlsCfg)
+			}))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("NewFromCfgMap() = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer p.Close()
+
+			conn, err := p.Acquire(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Release()
+			if _, ok := conn.Conn().PgConn().Conn().(*tls.Conn); !ok {
+				t.Fatal("connected without tls")
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if upgrades != 1 {
+				t.Fatalf("upgraded %d connections, want 1", upgrades)
+			}
+		})
+	}
+}
+
+func TestUpgradeTLSResolvedHost(t *testing.T) {
+	srv := newFakeServer(t)
+	c := tlsConfigMap(t, srv)
+	host, port, _ := net.SplitHostPort(srv.addr())
+
+	cfg, err := pool.ParseConfig(fmt.Sprintf("postgres://user@db.example.com:%s/db?sslmode=verify-full&sslrootcert=%s", port, c.SSLCAFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.ConnConfig.LookupFunc = func(context.Context, string) ([]string, error) {
+		return []string{host}, nil
+	}
+
+	var mu sync.Mutex
+	var names []string
+	upgradeTLS(cfg, func(ctx context.Context, conn net.Conn, tlsCfg *tls.Config) (net.Conn, error) {
+		mu.Lock()
+		names = append(names, tlsCfg.ServerName)
+		mu.Unlock()
+		return DefaultTLSUpgrade(ctx, conn, tlsCfg)
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	p, err := pool.ConnectConfig(ctx, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(names) != 1 || names[0] != "db.example.com" {
+		t.Fatalf("upgraded with the tls.Config of %v, want db.example.com", names)
+	}
+}
+
+func TestDefaultTLSUpgrade(t *testing.T) {
+	ca := newTestCA(t)
+	certPEM, keyPEM := ca.issue(t, serverTemplate("db.example.com"))
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+
+	tests := []struct {
+		name       string
+		reply      byte
+		serverName string
+		wantErr    string
+	}{
+		{name: "accepted", reply: 'S', serverName: "db.example.com"},
+		{name: "refused", reply: 'N', serverName: "db.example.com", wantErr: "server refused tls"},
+		{name: "wrong host", reply: 'S', serverName: "other.example.com", wantErr: "certificate is valid for db.example.com"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ln := serveSSLRequest(t, tt.reply, cert)
+			conn, err := net.Dial("tcp", ln.Addr().String())
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			tlsCfg := &tls.Config{ServerName: tt.serverName, RootCAs: roots}
+			upgraded, err := DefaultTLSUpgrade(ctx, conn, tlsCfg)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("DefaultTLSUpgrade() = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, ok := upgraded.(*tls.Conn); !ok {
+				t.Fatalf("DefaultTLSUpgrade() = %T, want a *tls.Conn", upgraded)
+			}
+		})
+	}
+}
@@ -0,0 +1,46 @@
+package pgxtls
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v4"
+	pool "github.com/jackc/pgx/v4/pgxpool"
+)
+
+type txBeginner interface {
+	BeginTx(ctx context.Context, txOptions pgx.TxOptions) (pgx.Tx, error)
+}
+
+// InTx runs fn in a transaction on p, committing it if fn returns nil and
+// rolling it back if fn returns an error or panics, in which case the
+// panic continues once the transaction is rolled back
+func InTx(ctx context.Context, p *pool.Pool, fn func(pgx.Tx) error) error {
+	return inTx(ctx, p, pgx.TxOptions{}, fn)
+}
+
+// InTxWithOptions is InTx with the transaction started with opts,
+// e.g. to pick its isolation level
+func InTxWithOptions(ctx context.Context, p *pool.Pool, opts pgx.TxOptions, fn func(pgx.Tx) error) error {
+	return inTx(ctx, p, opts, fn)
+}
+
+func inTx(ctx context.Context, b txBeginner, opts pgx.TxOptions, fn func(pgx.Tx) error) error {
+	tx, err := b.BeginTx(ctx, opts)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback(ctx)
+			panic(r)
+		}
+	}()
+
+	if err := fn(tx); err != nil {
+		tx.Rollback(ctx)
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
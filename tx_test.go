@@ -0,0 +1,100 @@
+package pgxtls
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/jackc/pgproto3/v2"
+	"github.com/jackc/pgx/v4"
+	pool "github.com/jackc/pgx/v4/pgxpool"
+)
+
+func TestInTx(t *testing.T) {
+	ctx := context.Background()
+	errWork := errors.New("job failed")
+
+	tests := []struct {
+		name      string
+		opts      pgx.TxOptions
+		fn        func(pgx.Tx) error
+		failSQL   string
+		wantErr   bool
+		wantPanic bool
+		want      []string
+	}{
+		{
+			name: "commit",
+			fn:   func(tx pgx.Tx) error { _, err := tx.Exec(ctx, "UPDATE jobs SET done = true"); return err },
+			want: []string{"begin", "UPDATE jobs SET done = true", "commit"},
+		},
+		{
+			name:    "error",
+			fn:      func(pgx.Tx) error { return errWork },
+			wantErr: true,
+			want:    []string{"begin", "rollback"},
+		},
+		{
+			name:      "panic",
+			fn:        func(pgx.Tx) error { panic("boom") },
+			wantPanic: true,
+			want:      []string{"begin", "rollback"},
+		},
+		{
+			name:    "commit fails",
+			fn:      func(pgx.Tx) error { return nil },
+			failSQL: "commit",
+			wantErr: true,
+			want:    []string{"begin", "commit"},
+		},
+		{
+			name: "options",
+			opts: pgx.TxOptions{IsoLevel: pgx.Serializable},
+			fn:   func(pgx.Tx) error { return nil },
+			want: []string{"begin isolation level serializable", "commit"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newFakeServer(t)
+			if tt.failSQL != "" {
+				srv.fail[tt.failSQL] = &pgproto3.ErrorResponse{Severity: "ERROR", Code: "40001", Message: "could not serialize access"}
+			}
+			p, err := pool.ConnectConfig(ctx, srv.poolConfig(t))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer p.Close()
+
+			func() {
+				defer func() {
+					if r := recover(); (r != nil) != tt.wantPanic {
+						t.Fatalf("recovered %v, wantPanic %v", r, tt.wantPanic)
+					}
+				}()
+				if tt.opts == (pgx.TxOptions{}) {
+					err = InTx(ctx, p, tt.fn)
+				} else {
+					err = InTxWithOptions(ctx, p, tt.opts, tt.fn)
+				}
+			}()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("InTx() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.name == "error" && err != errWork {
+				t.Fatalf("InTx() = %v, want fn's error", err)
+			}
+
+			var run []string
+			for _, q := range srv.queries() {
+				if q != ";" {
+					run = append(run, q)
+				}
+			}
+			if fmt.Sprint(run) != fmt.Sprint(tt.want) {
+				t.Fatalf("ran %q, want %q", run, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,182 @@
+package pgxtls
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/danvixent/pgxtls/config"
+)
+
+// leafCheck inspects the certificate presented by the server
+type leafCheck func(leaf *x509.Certificate) error
+
+// WithVerifyPeerCertificate runs fn on the certificate the server presents,
+// after the checks the ConfigMap asks for. It runs even when
+// SSLInsecureSkipVerify skips verifying the certificate chain
+func WithVerifyPeerCertificate(fn func(leaf *x509.Certificate) error) Option {
+	return func(o *options) {
+		o.verifyPeer = fn
+	}
+}
+
+// leafChecks returns the checks config asks the server certificate to pass
+func leafChecks(config *config.ConfigMap) []leafCheck {
+	var checks []leafCheck
+	if config.SSLInsecureSkipVerify {
+		// the standard verification that would check it is skipped
+		checks = append(checks, checkLeafExpiry)
+	}
+	if len(config.SSLPinnedSPKI) > 0 {
+		checks = append(checks, pinSPKI(config.SSLPinnedSPKI))
+	}
+	if len(config.SSLPinnedCertFingerprints) > 0 {
+		checks = append(checks, pinFingerprint(config.SSLPinnedCertFingerprints))
+	}
+	if config.SSLExpectedServerSerial != "" {
+		checks = append(checks, expectSerial(config.SSLExpectedServerSerial))
+	}
+	if config.SSLRequireSCT {
+		checks = append(checks, requireSCT)
+	}
+	if config.SSLRequireServerAuthEKU {
+		checks = append(checks, requireServerAuth)
+	}
+	return checks
+}
+
+// verifyLeaf returns a tls.Config.VerifyPeerCertificate callback
+// verifying the chain of the server certificate up to roots, unless
+// they are nil, then running each of checks on the certificate
+func verifyLeaf(roots *x509.CertPool, checks []leafCheck) func([][]byte, [][]*x509.Certificate) error {
+	return func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+		if len(rawCerts) == 0 {
+			return errors.New("server presented no certificate")
+		}
+
+		leaf, err := x509.ParseCertificate(rawCerts[0])
+		if err != nil {
+			return fmt.Errorf("can't parse server certificate: %v", err)
+		}
+
+		if roots != nil {
+			if err := verifyChain(leaf, rawCerts[1:], roots); err != nil {
+				return err
+			}
+		}
+
+		for _, check := range checks {
+			if err := check(leaf); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// verifyChain verifies leaf chains up to roots through the intermediates
+// the server sent, without checking which host it is for
+func verifyChain(leaf *x509.Certificate, intermediates [][]byte, roots *x509.CertPool) error {
+	opts := x509.VerifyOptions{Roots: roots, Intermediates: x509.NewCertPool()}
+	for _, der := range intermediates {
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			return fmt.Errorf("can't parse server certificate chain: %v", err)
+		}
+		opts.Intermediates.AddCert(cert)
+	}
+
+	if _, err := leaf.Verify(opts); err != nil {
+		return fmt.Errorf("can't verify server certificate: %v", err)
+	}
+	return nil
+}
+
+// oidSCTList identifies the embedded signed certificate timestamp list extension
+var oidSCTList = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 11129, 2, 4, 2}
+
+// requireSCT rejects certificates without embedded signed certificate timestamps
+func requireSCT(leaf *x509.Certificate) error {
+	for _, ext := range leaf.Extensions {
+		if ext.Id.Equal(oidSCTList) && len(ext.Value) > 0 {
+			return nil
+		}
+	}
+	return fmt.Errorf("server certificate %q has no signed certificate timestamps", leaf.Subject)
+}
+
+// requireServerAuth rejects certificates not issued for server authentication
+func requireServerAuth(leaf *x509.Certificate) error {
+	for _, usage := range leaf.ExtKeyUsage {
+		if usage == x509.ExtKeyUsageServerAuth {
+			return nil
+		}
+	}
+	return fmt.Errorf("server certificate %q lacks the serverAuth extended key usage", leaf.Subject)
+}
+
+// checkLeafExpiry rejects certificates outside their validity period
+func checkLeafExpiry(leaf *x509.Certificate) error {
+	now := time.Now()
+	if now.Before(leaf.NotBefore) {
+		return fmt.Errorf("server certificate %q is not valid until %s", leaf.Subject, leaf.NotBefore.Format(time.RFC3339))
+	}
+	if now.After(leaf.NotAfter) {
+		return fmt.Errorf("server certificate %q expired on %s", leaf.Subject, leaf.NotAfter.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// pinSPKI returns a check rejecting certificates whose public key
+// doesn't hash to one of pins, base64 encoded sha256 digests of
+// the DER encoded SubjectPublicKeyInfo
+func pinSPKI(pins []string) leafCheck {
+	return func(leaf *x509.Certificate) error {
+		sum := sha256.Sum256(leaf.RawSubjectPublicKeyInfo)
+		digest := base64.StdEncoding.EncodeToString(sum[:])
+		for _, pin := range pins {
+			if pin == digest {
+				return nil
+			}
+		}
+		return fmt.Errorf("server certificate %q public key %s matches no pinned key", leaf.Subject, digest)
+	}
+}
+
+// pinFingerprint returns a check rejecting certificates whose sha256
+// fingerprint isn't one of pins, hex encoded in either case, with
+// or without the colons between bytes openssl prints
+func pinFingerprint(pins []string) leafCheck {
+	allowed := make(map[string]bool, len(pins))
+	for _, pin := range pins {
+		allowed[strings.ToLower(strings.ReplaceAll(pin, ":", ""))] = true
+	}
+
+	return func(leaf *x509.Certificate) error {
+		sum := sha256.Sum256(leaf.Raw)
+		fingerprint := hex.EncodeToString(sum[:])
+		if allowed[fingerprint] {
+			return nil
+		}
+		return fmt.Errorf("server certificate %q fingerprint %s matches no pinned fingerprint", leaf.Subject, fingerprint)
+	}
+}
+
+// expectSerial returns a check rejecting certificates whose serial isn't
+// serial, hex encoded as openssl x509 -serial prints it, colons optional
+func expectSerial(serial string) leafCheck {
+	want, _ := new(big.Int).SetString(strings.ReplaceAll(serial, ":", ""), 16)
+	return func(leaf *x509.Certificate) error {
+		if want == nil || leaf.SerialNumber.Cmp(want) != 0 {
+			return fmt.Errorf("server certificate %q has serial %X, expected %s", leaf.Subject, leaf.SerialNumber, serial)
+		}
+		return nil
+	}
+}
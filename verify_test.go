@@ -0,0 +1,329 @@
+package pgxtls
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/danvixent/pgxtls/config"
+)
+
+// parseCert returns the first certificate in certPEM
+func parseCert(t *testing.T, certPEM []byte) *x509.Certificate {
+	t.Helper()
+	block, _ := pem.Decode(certPEM)
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
+
+func TestPinSPKI(t *testing.T) {
+	ca := newTestCA(t)
+	leafPEM, _ := ca.issue(t, serverTemplate("db.example.com"))
+	leaf := parseCert(t, leafPEM)
+	sum := sha256.Sum256(leaf.RawSubjectPublicKeyInfo)
+	pin := base64.StdEncoding.EncodeToString(sum[:])
+	caSum := sha256.Sum256(ca.cert.RawSubjectPublicKeyInfo)
+	caPin := base64.StdEncoding.EncodeToString(caSum[:])
+
+	tests := []struct {
+		name    string
+		pins    []string
+		wantErr bool
+	}{
+		{name: "pinned", pins: []string{pin}},
+		{name: "one of several", pins: []string{caPin, pin}},
+		{name: "ca key only", pins: []string{caPin}, wantErr: true},
+		{name: "hex digest", pins: []string{hex.EncodeToString(sum[:])}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := pinSPKI(tt.pins)(leaf); (err != nil) != tt.wantErr {
+				t.Fatalf("pinSPKI() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestPinFingerprint(t *testing.T) {
+	leafPEM, _ := newTestCA(t).issue(t, serverTemplate("db.example.com"))
+	leaf := parseCert(t, leafPEM)
+	sum := sha256.Sum256(leaf.Raw)
+	fingerprint := hex.EncodeToString(sum[:])
+
+	var colons []string
+	for _, b := range sum {
+		colons = append(colons, fmt.Sprintf("%02X", b))
+	}
+
+	tests := []struct {
+		name    string
+		pin     string
+		wantErr bool
+	}{
+		{name: "lower case", pin: fingerprint},
+		{name: "openssl", pin: strings.Join(colons, ":")},
+		{name: "other", pin: strings.Repeat("00", sha256.Size), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := pinFingerprint([]string{tt.pin})(leaf); (err != nil) != tt.wantErr {
+				t.Fatalf("pinFingerprint() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestExpectSerial(t *testing.T) {
+	leaf := &x509.Certificate{SerialNumber: big.NewInt(0x1a2b)}
+	tests := []struct {
+		serial  string
+		wantErr bool
+	}{
+		{serial: "1A2B"},
+		{serial: "1a2b"},
+		{serial: "1A:2B"},
+		{serial: "1A2C", wantErr: true},
+		{serial: "not hex", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.serial, func(t *testing.T) {
+			if err := expectSerial(tt.serial)(leaf); (err != nil) != tt.wantErr {
+				t.Fatalf("expectSerial() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLeafProperties(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name    string
+		check   leafCheck
+		leaf    *x509.Certificate
+		wantErr string
+	}{
+		{"valid", checkLeafExpiry, &x509.Certificate{NotBefore: now.Add(-time.Hour), NotAfter: now.Add(time.Hour)}, ""},
+		{"not yet valid", checkLeafExpiry, &x509.Certificate{NotBefore: now.Add(time.Hour), NotAfter: now.Add(2 * time.Hour)}, "not valid until"},
+		{"expired", checkLeafExpiry, &x509.Certificate{NotBefore: now.Add(-2 * time.Hour), NotAfter: now.Add(-time.Hour)}, "expired"},
+		{"server auth", requireServerAuth, &x509.Certificate{ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth, x509.ExtKeyUsageServerAuth}}, ""},
+		{"client auth only", requireServerAuth, &x509.Certificate{ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}}, "serverAuth"},
+		{"no sct", requireSCT, &x509.Certificate{}, "signed certificate timestamps"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.check(tt.leaf)
+			if tt.wantErr == "" && err != nil || tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("check() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestVerifyLeaf(t *testing.T) {
+	ca, otherCA := newTestCA(t), newTestCA(t)
+	leafPEM, _ := ca.issue(t, serverTemplate("db.example.com"))
+	leaf := parseCert(t, leafPEM)
+	roots, otherRoots := x509.NewCertPool(), x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	otherRoots.AddCert(otherCA.cert)
+
+	reject := func(*x509.Certificate) error { return fmt.Errorf("rejected") }
+
+	tests := []struct {
+		name     string
+		roots    *x509.CertPool
+		checks   []leafCheck
+		rawCerts [][]byte
+		wantErr  string
+	}{
+		{name: "chain", roots: roots, rawCerts: [][]byte{leaf.Raw}},
+		{name: "checks only", checks: []leafCheck{checkLeafExpiry}, rawCerts: [][]byte{leaf.Raw}},
+		{name: "other ca", roots: otherRoots, rawCerts: [][]byte{leaf.Raw}, wantErr: "can't verify server certificate"},
+		{name: "check rejects", roots: roots, checks: []leafCheck{reject}, rawCerts: [][]byte{leaf.Raw}, wantErr: "rejected"},
+		{name: "no certificate", roots: roots, wantErr: "no certificate"},
+		{name: "garbage", roots: roots, rawCerts: [][]byte{[]byte("garbage")}, wantErr: "can't parse server certificate"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := verifyLeaf(tt.roots, tt.checks)(tt.rawCerts, nil)
+			if tt.wantErr == "" && err != nil || tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("verifyLeaf() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestInsecureSkipVerifyKeepsPins(t *testing.T) {
+	ca, otherCA := newTestCA(t), newTestCA(t)
+	certPEM, keyPEM := otherCA.issue(t, serverTemplate("replica.example.com"))
+	sum := sha256.Sum256(parseCert(t, certPEM).RawSubjectPublicKeyInfo)
+	certSum := sha256.Sum256(parseCert(t, certPEM).Raw)
+	clientCertPEM, clientKeyPEM := ca.issue(t, clientTemplate("user"))
+
+	dir := t.TempDir()
+	c := dsnConfig()
+	c.SSLMode, c.SSLInsecureSkipVerify = config.SSLModeRequire, true
+	c.SSLCertFile = writeFile(t, dir, "client.crt", clientCertPEM)
+	c.SSLKeyFile = writeFile(t, dir, "client.key", clientKeyPEM)
+	c.SSLCAFile = writeFile(t, dir, "ca.crt", ca.pem)
+
+	tests := []struct {
+		name         string
+		pins         []string
+		fingerprints []string
+		wantErr      string
+	}{
+		{name: "no pins", pins: nil},
+		{name: "pinned", pins: []string{base64.StdEncoding.EncodeToString(sum[:])}},
+		{name: "other pin", pins: []string{base64.StdEncoding.EncodeToString(make([]byte, sha256.Size))}, wantErr: "matches no pinned key"},
+		{name: "pinned fingerprint", fingerprints: []string{strings.Repeat("00", sha256.Size), hex.EncodeToString(certSum[:])}},
+		{name: "other fingerprint", fingerprints: []string{strings.Repeat("00", sha256.Size)}, wantErr: "matches no pinned fingerprint"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c.SSLPinnedSPKI, c.SSLPinnedCertFingerprints = tt.pins, tt.fingerprints
+			tlsCfg, err := newTLSConfig(context.Background(), c, newOptions(nil))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// the server's ca isn't trusted and its name isn't DbHost
+			err = handshake(t, forHost(tlsCfg, "db.example.com"), certPEM, keyPEM)
+			if tt.wantErr == "" && err != nil || tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("handshake() = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// verifyServer connects to a server presenting a certificate for
+// db.example.com, issued by the trusted ca from tmpl, with the
+// tls.Config built from c, returning the handshake error
+func verifyServer(t *testing.T, c *config.ConfigMap, tmpl *x509.Certificate) error {
+	t.Helper()
+	ca := newTestCA(t)
+	clientCertPEM, clientKeyPEM := ca.issue(t, clientTemplate("user"))
+	dir := t.TempDir()
+	c.SSLCAFile = writeFile(t, dir, "ca.crt", ca.pem)
+	c.SSLCertFile = writeFile(t, dir, "client.crt", clientCertPEM)
+	c.SSLKeyFile = writeFile(t, dir, "client.key", clientKeyPEM)
+
+	tlsCfg, err := newTLSConfig(context.Background(), c, newOptions(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	certPEM, keyPEM := ca.issue(t, tmpl)
+	return handshake(t, forHost(tlsCfg, "db.example.com"), certPEM, keyPEM)
+}
+
+func TestRequireSCT(t *testing.T) {
+	withSCT := func(value []byte) *x509.Certificate {
+		tmpl := serverTemplate("db.example.com")
+		tmpl.ExtraExtensions = []pkix.Extension{{Id: oidSCTList, Value: value}}
+		return tmpl
+	}
+
+	tests := []struct {
+		name    string
+		require bool
+		tmpl    *x509.Certificate
+		wantErr bool
+	}{
+		{name: "not required", tmpl: serverTemplate("db.example.com")},
+		{name: "embedded", require: true, tmpl: withSCT([]byte{0x04, 0x02, 0x00, 0x00})},
+		{name: "missing", require: true, tmpl: serverTemplate("db.example.com"), wantErr: true},
+		{name: "empty list", require: true, tmpl: withSCT(nil), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := dsnConfig()
+			c.SSLRequireSCT = tt.require
+			err := verifyServer(t, c, tt.tmpl)
+			if tt.wantErr {
+				if err == nil || !strings.Contains(err.Error(), "signed certificate timestamps") {
+					t.Fatalf("handshake error = %v, want a missing sct error", err)
+				}
+			} else if err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestRequireServerAuthEKU(t *testing.T) {
+	withUsage := func(usage ...x509.ExtKeyUsage) *x509.Certificate {
+		tmpl := serverTemplate("db.example.com")
+		tmpl.ExtKeyUsage = usage
+		return tmpl
+	}
+
+	tests := []struct {
+		name    string
+		require bool
+		tmpl    *x509.Certificate
+		wantErr bool
+	}{
+		{name: "server auth", require: true, tmpl: withUsage(x509.ExtKeyUsageServerAuth)},
+		{name: "server and client auth", require: true, tmpl: withUsage(x509.ExtKeyUsageClientAuth, x509.ExtKeyUsageServerAuth)},
+		{name: "client auth", require: true, tmpl: withUsage(x509.ExtKeyUsageClientAuth), wantErr: true},
+		{name: "no extended usage", require: true, tmpl: withUsage(), wantErr: true},
+		{name: "client auth not required", tmpl: withUsage(x509.ExtKeyUsageClientAuth)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// the chain goes unverified, as it would check the usage itself
+			c := dsnConfig()
+			c.SSLMode, c.SSLInsecureSkipVerify = config.SSLModeRequire, true
+			c.SSLRequireServerAuthEKU = tt.require
+			err := verifyServer(t, c, tt.tmpl)
+			if tt.wantErr {
+				if err == nil || !strings.Contains(err.Error(), "lacks the serverAuth extended key usage") {
+					t.Fatalf("handshake error = %v, want a missing serverAuth error", err)
+				}
+			} else if err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestExpectedServerSerial(t *testing.T) {
+	tmpl := serverTemplate("db.example.com")
+	serial := fmt.Sprintf("%X", tmpl.SerialNumber)
+
+	tests := []struct {
+		name    string
+		serial  string
+		wantErr bool
+	}{
+		{name: "unset"},
+		{name: "matching", serial: serial},
+		{name: "other", serial: serial + "00", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := dsnConfig()
+			c.SSLExpectedServerSerial = tt.serial
+			err := verifyServer(t, c, tmpl)
+			if tt.wantErr {
+				if err == nil || !strings.Contains(err.Error(), "expected "+tt.serial) {
+					t.Fatalf("handshake error = %v, want an unexpected serial error", err)
+				}
+			} else if err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
@@ -0,0 +1,42 @@
+package pgxtls
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/danvixent/pgxtls/config"
+	"github.com/jackc/pgx/v4"
+)
+
+// WaitForDB blocks until the database config describes accepts a
+// connection and answers a ping, trying every pollInterval, for init
+// containers and startup scripts. Unlike a pool, no connection is kept.
+// When ctx is done first the last connection error is returned, errors
+// retrying won't fix, such as a wrong password, are returned at once
+func WaitForDB(ctx context.Context, config *config.ConfigMap, pollInterval time.Duration, opts ...Option) error {
+	o := newOptions(opts)
+	cfg, err := newPoolConfig(ctx, config, nil, o)
+	if err != nil {
+		return err
+	}
+
+	for {
+		conn, err := connect(ctx, cfg)
+		if err != nil && !retryConnect(err) {
+			return err
+		}
+		if err == nil {
+			err = conn.Ping(ctx)
+			conn.Close(context.Background())
+			if err == nil {
+				return nil
+			}
+		}
+
+		o.logger.Log(ctx, pgx.LogLevelInfo, "database not available yet", map[string]interface{}{"err": err})
+		if serr := sleep(ctx, pollInterval); serr != nil {
+			return fmt.Errorf("database not available: %v", err)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package pgxtls
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWaitForDB(t *testing.T) {
+	// reserve a port nothing listens on until the server starts
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	c := newFakeServer(t).configMap(t)
+	host, port, _ := net.SplitHostPort(addr)
+	n, _ := strconv.Atoi(port)
+	c.DbHost, c.DbPort = host, uint16(n)
+
+	tests := []struct {
+		name    string
+		startIn time.Duration
+		timeout time.Duration
+		wantErr string
+	}{
+		{name: "never starts", timeout: 50 * time.Millisecond, wantErr: "database not available"},
+		{name: "starts late", startIn: 50 * time.Millisecond, timeout: 5 * time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.startIn > 0 {
+				time.AfterFunc(tt.startIn, func() {
+					ln, err := net.Listen("tcp", addr)
+					if err != nil {
+						t.Error(err)
+						return
+					}
+					serveFake(t, ln)
+				})
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), tt.timeout)
+			defer cancel()
+			logs := &logRecorder{}
+			err := WaitForDB(ctx, c, 10*time.Millisecond, WithLogger(logs))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("WaitForDB() = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(logs.find("database not available yet")) == 0 {
+				t.Fatal("the failed attempts weren't logged")
+			}
+		})
+	}
+}